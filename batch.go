@@ -0,0 +1,143 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Predicts many test vectors in parallel
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const batchChunk = 16 // number of vectors a worker takes at a time
+
+/**
+ * Runs predict on the vectors [0,n) with Parameter.NumCPU workers (GOMAXPROCS if less than 1), as training does.
+ * Each worker has its own scratch buffers, and the vectors are handed out in chunks to balance the load.
+ */
+func (model Model) runBatch(n int, predict func(i int, scratch *predictScratch)) {
+	workers := model.param.NumCPU
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if maxWorkers := (n + batchChunk - 1) / batchChunk; workers > maxWorkers {
+		workers = maxWorkers
+	}
+
+	var next int64 = 0 // next chunk to predict
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scratch := new(predictScratch)
+			for {
+				start := int(atomic.AddInt64(&next, batchChunk)) - batchChunk
+				if start >= n {
+					return
+				}
+				end := mini(start+batchChunk, n)
+				for i := start; i < end; i++ {
+					predict(i, scratch)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+/**
+ * Returns the vectors of the problem set, without copying them
+ */
+func (problem *Problem) snodes() [][]snode {
+	xs := make([][]snode, problem.l)
+	for i := 0; i < problem.l; i++ {
+		xs[i] = problem.xSpace[problem.x[i]:]
+	}
+	return xs
+}
+
+func mapsToSnodes(xs []map[int]float64) [][]snode {
+	pxs := make([][]snode, len(xs))
+	for i, x := range xs {
+		pxs[i] = MapToSnode(x)
+	}
+	return pxs
+}
+
+func (model Model) predictValuesBatch(pxs [][]snode) (returnValues []float64, decisionValues [][]float64) {
+	returnValues = make([]float64, len(pxs))
+	decisionValues = make([][]float64, len(pxs))
+	model.runBatch(len(pxs), func(i int, scratch *predictScratch) {
+		returnValues[i], decisionValues[i] = model.predictValues(pxs[i], scratch)
+	})
+	return // returnValues, decisionValues
+}
+
+func (model Model) predictProbabilityBatch(pxs [][]snode) (returnValues []float64, probabilityEstimates [][]float64) {
+	returnValues = make([]float64, len(pxs))
+	probabilityEstimates = make([][]float64, len(pxs))
+	model.runBatch(len(pxs), func(i int, scratch *predictScratch) {
+		returnValues[i], probabilityEstimates[i] = model.predictProbability(pxs[i], scratch)
+	})
+	return // returnValues, probabilityEstimates
+}
+
+/**
+ * Same as Predict for each of the test vectors xs, in parallel.  The results are in the order of xs.
+ */
+func (model Model) PredictBatch(xs []map[int]float64) []float64 {
+	returnValues, _ := model.predictValuesBatch(mapsToSnodes(xs))
+	return returnValues
+}
+
+/**
+ * Same as PredictValues for each of the test vectors xs, in parallel.  The results are in the order of xs.
+ */
+func (model Model) PredictValuesBatch(xs []map[int]float64) (returnValues []float64, decisionValues [][]float64) {
+	return model.predictValuesBatch(mapsToSnodes(xs))
+}
+
+/**
+ * Same as PredictProbability for each of the test vectors xs, in parallel.  The results are in the order of xs.
+ */
+func (model Model) PredictProbabilityBatch(xs []map[int]float64) (returnValues []float64, probabilityEstimates [][]float64) {
+	return model.predictProbabilityBatch(mapsToSnodes(xs))
+}
+
+/**
+ * Same as PredictBatch for the vectors of the problem set, which are not copied
+ */
+func (model Model) PredictProblem(prob *Problem) []float64 {
+	returnValues, _ := model.predictValuesBatch(prob.snodes())
+	return returnValues
+}
+
+/**
+ * Same as PredictValuesBatch for the vectors of the problem set
+ */
+func (model Model) PredictValuesProblem(prob *Problem) (returnValues []float64, decisionValues [][]float64) {
+	return model.predictValuesBatch(prob.snodes())
+}
+
+/**
+ * Same as PredictProbabilityBatch for the vectors of the problem set
+ */
+func (model Model) PredictProbabilityProblem(prob *Problem) (returnValues []float64, probabilityEstimates [][]float64) {
+	return model.predictProbabilityBatch(prob.snodes())
+}
@@ -0,0 +1,103 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the batch prediction
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+/**
+ * The batch predictions are those of the vectors predicted one at a time, in their order, whatever the
+ * number of workers
+ */
+func TestPredictBatch(t *testing.T) {
+	param := quietParameter(C_SVC)
+	param.Probability = true
+	text := blobsText(1, 150, 4, 3)
+	model := mustTrain(t, mustProblem(t, text, param), param)
+	test := mustProblem(t, blobsText(2, 100, 4, 3), nil)
+
+	var xs []map[int]float64
+	for test.Begin(); !test.Done(); test.Next() {
+		_, x := test.GetLine()
+		xs = append(xs, x)
+	}
+
+	for _, numCPU := range []int{-1, 1, 3, 200} {
+		model.param.NumCPU = numCPU
+		labels := model.PredictBatch(xs)
+		values, decisionValues := model.PredictValuesBatch(xs)
+		probLabels, estimates := model.PredictProbabilityBatch(xs)
+		problemLabels := model.PredictProblem(test)
+		problemValues, problemDecisionValues := model.PredictValuesProblem(test)
+		problemProbLabels, problemEstimates := model.PredictProbabilityProblem(test)
+
+		if len(labels) != len(xs) || len(problemLabels) != len(xs) {
+			t.Fatalf("NumCPU %d: %d and %d predictions of %d vectors", numCPU, len(labels), len(problemLabels), len(xs))
+		}
+		for i, x := range xs {
+			want, wantValues := model.PredictValues(x)
+			wantLabel, wantEstimates := model.PredictProbability(x)
+			if labels[i] != want || values[i] != want || problemLabels[i] != want {
+				t.Fatalf("NumCPU %d, vector %d: predicted %v %v %v, want %v", numCPU, i, labels[i], values[i], problemLabels[i], want)
+			}
+			if !nearSlices(decisionValues[i], wantValues, 1e-12) {
+				t.Fatalf("NumCPU %d, vector %d: decision values %v, want %v", numCPU, i, decisionValues[i], wantValues)
+			}
+			if probLabels[i] != wantLabel || !nearSlices(estimates[i], wantEstimates, 1e-12) {
+				t.Fatalf("NumCPU %d, vector %d: probabilities %v %v, want %v %v", numCPU, i, probLabels[i], estimates[i], wantLabel, wantEstimates)
+			}
+			if problemValues[i] != want || !nearSlices(problemDecisionValues[i], wantValues, 1e-12) {
+				t.Fatalf("NumCPU %d, vector %d: predicted %v %v from the problem, want %v %v", numCPU, i, problemValues[i], problemDecisionValues[i], want, wantValues)
+			}
+			if problemProbLabels[i] != wantLabel || !nearSlices(problemEstimates[i], wantEstimates, 1e-12) {
+				t.Fatalf("NumCPU %d, vector %d: probabilities %v %v from the problem, want %v %v", numCPU, i, problemProbLabels[i], problemEstimates[i], wantLabel, wantEstimates)
+			}
+		}
+	}
+
+	if labels := model.PredictBatch(nil); len(labels) != 0 {
+		t.Errorf("%d predictions of no vector", len(labels))
+	}
+}
+
+/**
+ * Every vector is predicted exactly once, whatever the number of vectors and workers
+ */
+func TestRunBatch(t *testing.T) {
+	for _, n := range []int{0, 1, batchChunk - 1, batchChunk, 5*batchChunk + 3} {
+		for _, numCPU := range []int{-1, 1, 2, 7} {
+			model := NewModel(NewParameter())
+			model.param.NumCPU = numCPU
+			counts := make([]int32, n)
+			model.runBatch(n, func(i int, scratch *predictScratch) {
+				if scratch == nil {
+					t.Errorf("no scratch buffers")
+				}
+				atomic.AddInt32(&counts[i], 1)
+			})
+			for i, count := range counts {
+				if count != 1 {
+					t.Fatalf("%d vectors and NumCPU %d: vector %d predicted %d times", n, numCPU, i, count)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Builds a problem, i.e. label/vector set, from vectors held in memory
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"fmt"
+	"sort"
+)
+
+/**
+ * Collects labels and vectors, and builds the same Problem that Problem.Read would build
+ * from the equivalent training file.  A vector that cannot be added (an invalid index, for
+ * instance) is left out, and the error of the first one is returned by Build.
+ */
+type ProblemBuilder struct {
+	prob Problem
+	err  error // first error encountered while adding vectors
+}
+
+func NewProblemBuilder() *ProblemBuilder {
+	return &ProblemBuilder{}
+}
+
+/**
+ * Appends one label and vector to the problem set
+ */
+func (b *ProblemBuilder) add(label float64, nodes []snode) {
+	b.prob.x = append(b.prob.x, len(b.prob.xSpace))
+	b.prob.y = append(b.prob.y, label)
+	for _, node := range nodes {
+		if node.index > b.prob.maxIdx {
+			b.prob.maxIdx = node.index
+		}
+	}
+	b.prob.xSpace = append(b.prob.xSpace, nodes...)
+	b.prob.xSpace = append(b.prob.xSpace, snode{index: -1})
+	b.prob.l++
+}
+
+func (b *ProblemBuilder) fail(format string, a ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("vector %d: %s", b.prob.l+1, fmt.Sprintf(format, a...))
+	}
+}
+
+/**
+ * Adds a label and its vector (map of dimension/value)
+ */
+func (b *ProblemBuilder) Add(label float64, x map[int]float64) {
+	nodes := MapToSnode(x)
+	nodes = nodes[:len(nodes)-1] // drop the end of SV marker
+	if len(nodes) > 0 && nodes[0].index < 0 {
+		b.fail("invalid index %d", nodes[0].index)
+		return
+	}
+	b.add(label, nodes)
+}
+
+/**
+ * Adds a label and its vector given as parallel slices of dimensions and values.
+ * The dimensions must be non-negative and in ascending order.
+ */
+func (b *ProblemBuilder) AddSparse(label float64, indices []int, values []float64) {
+	if len(indices) != len(values) {
+		b.fail("%d indices but %d values", len(indices), len(values))
+		return
+	}
+	if !sort.IntsAreSorted(indices) {
+		b.fail("indices are not in ascending order")
+		return
+	}
+
+	nodes := make([]snode, len(indices))
+	for k, index := range indices {
+		if index < 0 || (k > 0 && index == indices[k-1]) {
+			b.fail("invalid index %d", index)
+			return
+		}
+		nodes[k] = snode{index: index, value: values[k]}
+	}
+	b.add(label, nodes)
+}
+
+/**
+ * Adds a label and its dense vector.  Element x[k] is dimension k+1, and zero elements are omitted.
+ */
+func (b *ProblemBuilder) AddDense(label float64, x []float64) {
+	var nodes []snode
+	for k, value := range x {
+		if value != 0 {
+			nodes = append(nodes, snode{index: k + 1, value: value})
+		}
+	}
+	b.add(label, nodes)
+}
+
+/**
+ * Discards the vectors added so far, and the error of a vector that could not be added
+ */
+func (b *ProblemBuilder) Reset() {
+	*b = ProblemBuilder{}
+}
+
+/**
+ * Returns the problem set built so far, and resets the builder, even if a vector could not be added.
+ * As with NewProblem, the default gamma in param is set from the largest dimension if it has not been
+ * specified.  param may be nil, in which case Parameter.Validate sets the default gamma before training.
+ */
+func (b *ProblemBuilder) Build(param *Parameter) (*Problem, error) {
+	prob, err := b.prob, b.err
+	b.Reset()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prob.complete(param); err != nil {
+		return nil, err
+	}
+
+	return &prob, nil
+}
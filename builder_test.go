@@ -0,0 +1,116 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the in-memory construction of problem sets
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sameProblem(a, b *Problem) bool {
+	return a.l == b.l && a.maxIdx == b.maxIdx && reflect.DeepEqual(a.y, b.y) && reflect.DeepEqual(a.x, b.x) &&
+		reflect.DeepEqual(a.xSpace, b.xSpace) && reflect.DeepEqual(a.w, b.w)
+}
+
+/**
+ * The problem sets built with Add, AddSparse and AddDense are those read from the equivalent text,
+ * including the default gamma
+ */
+func TestProblemBuilder(t *testing.T) {
+	text := "1 1:0.5 3:-1\n-1 2:2\n1\n-1 1:1 2:1 3:1 7:0.25\n"
+	readParam := NewParameter()
+	want := mustProblem(t, text, readParam)
+	ys, xs := problemVectors(want)
+
+	adds := map[string]func(b *ProblemBuilder, y float64, x map[int]float64){
+		"Add": (*ProblemBuilder).Add,
+		"AddSparse": func(b *ProblemBuilder, y float64, x map[int]float64) {
+			var indices []int
+			var values []float64
+			for _, node := range MapToSnode(x) {
+				if node.index != -1 {
+					indices = append(indices, node.index)
+					values = append(values, node.value)
+				}
+			}
+			b.AddSparse(y, indices, values)
+		},
+		"AddDense": func(b *ProblemBuilder, y float64, x map[int]float64) {
+			dense := make([]float64, 7)
+			for index, value := range x {
+				dense[index-1] = value
+			}
+			b.AddDense(y, dense)
+		},
+	}
+
+	for name, add := range adds {
+		b := NewProblemBuilder()
+		for i := range ys {
+			add(b, ys[i], xs[i])
+		}
+		param := NewParameter()
+		prob, err := b.Build(param)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !sameProblem(prob, want) {
+			t.Errorf("%s built %+v, want %+v", name, *prob, *want)
+		}
+		if param.Gamma != readParam.Gamma || param.Gamma != 1.0/7 {
+			t.Errorf("%s: gamma %v, want %v", name, param.Gamma, readParam.Gamma)
+		}
+
+		if prob, err := b.Build(nil); err != nil {
+			t.Errorf("%s: %v after Build", name, err)
+		} else if prob.l != 0 {
+			t.Errorf("%s: %d vectors after Build", name, prob.l)
+		}
+	}
+}
+
+/**
+ * A vector that cannot be added is left out, its error is returned by Build, and the builder is reset
+ */
+func TestProblemBuilderErrors(t *testing.T) {
+	tests := map[string]func(b *ProblemBuilder){
+		"negative index":     func(b *ProblemBuilder) { b.Add(1, map[int]float64{-1: 1, 2: 1}) },
+		"lengths":            func(b *ProblemBuilder) { b.AddSparse(1, []int{1, 2}, []float64{1}) },
+		"unsorted":           func(b *ProblemBuilder) { b.AddSparse(1, []int{2, 1}, []float64{1, 1}) },
+		"duplicate":          func(b *ProblemBuilder) { b.AddSparse(1, []int{1, 1}, []float64{1, 1}) },
+		"negative AddSparse": func(b *ProblemBuilder) { b.AddSparse(1, []int{-2, 1}, []float64{1, 1}) },
+	}
+
+	for name, bad := range tests {
+		b := NewProblemBuilder()
+		b.AddDense(1, []float64{1, 2})
+		bad(b)
+		b.AddDense(-1, []float64{2, 1})
+		if _, err := b.Build(nil); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+
+		b.AddDense(-1, []float64{2, 1})
+		if prob, err := b.Build(nil); err != nil {
+			t.Errorf("%s: %v after a failed Build", name, err)
+		} else if prob.l != 1 {
+			t.Errorf("%s: %d vectors after a failed Build", name, prob.l)
+		}
+	}
+}
@@ -13,114 +13,343 @@
 ** See the License for the specific language governing permissions and
 ** limitations under the License.
 **
-** Description: Caches Q matrix rows.  The cache implements a LRU (Last Recently Used) eviction policy.
+** Description: Caches Q matrix rows.  The rows to evict are chosen by an eviction policy (see evictionPolicy).
 ** @author: Ed Walker
  */
 package libSvm
 
 import (
+	"container/heap"
 	"container/list"
-	"fmt"
+	"unsafe"
 )
 
-// To allow the LRU cache to store values of a different type, modify BOTH cacheDataType and sizeOfCacheDataType
-type cacheDataType float32
+const (
+	LRU_CACHE = iota // evicts the least recently used row of the Q matrix
+	LFU_CACHE = iota // evicts the least frequently used row of the Q matrix
+)
+
+const (
+	SINGLE_PRECISION = iota // caches the kernel values as float32
+	DOUBLE_PRECISION = iota // caches the kernel values as float64
+)
 
-const sizeOfCacheDataType = 4
+/**
+ * Type of the cached kernel values: float32 for SINGLE_PRECISION, float64 for DOUBLE_PRECISION
+ */
+type cacheValue interface {
+	float32 | float64
+}
+
+func sizeOfCacheValue[T cacheValue]() int {
+	var value T
+	return int(unsafe.Sizeof(value))
+}
 
 type cacheNode struct {
-	index    int             // row index for which this cacheNode is caching
-	refCount int             // number of times this row was referenced
-	data     []cacheDataType // valid slice from cache::cacheBuffer if in the cache
-	offset   int             // offset in cache::cacheBuffer data field is referring too
-	element  *list.Element   // list element (iterator) if in LRU list
+	index     int           // row index for which this cacheNode is caching
+	refCount  int           // number of times this row was referenced
+	offset    int           // offset of the row in cache::cacheBuffer if in the cache, -1 otherwise
+	length    int           // number of leading entries of the row that have been computed
+	element   *list.Element // list element (iterator) if in the LRU list
+	heapIndex int           // position in the LFU heap if in it
 }
 
-type cache struct {
-	head            []cacheNode     // all the possible cached rows
-	rowSize         int             // size of each row
-	cacheAvail      int             // number of additional rows we can store
-	cacheBuffer     []cacheDataType // pre-allocated buffer for cache
-	availableOffset int             // next available offset in cacheBuffer
-	hits, misses    int             // cache statistics
-	cacheList       *list.List      // LRU list
+/**
+ * Chooses the row to evict when the cache is full, from the rows it has been told are in the cache
+ */
+type evictionPolicy interface {
+	insert(h *cacheNode)               // h was put in the cache
+	touch(h *cacheNode)                // h, in the cache, was referenced again
+	remove(h *cacheNode)               // h was taken out of the cache
+	victim(keep *cacheNode) *cacheNode // returns the row to evict other than keep, which is then removed
+	each(visit func(*cacheNode))       // visits every row in the cache; visit may remove it
 }
 
-func (c *cache) getData(i int) ([]cacheDataType, bool) {
-	var newData bool = true
+type cache[T cacheValue] struct {
+	head            []cacheNode // all the possible cached rows
+	rowSize         int         // size of each row
+	cacheAvail      int         // number of additional rows we can store
+	cacheBuffer     []T         // pre-allocated buffer for cache
+	availableOffset int         // next available offset in cacheBuffer
+	freeOffsets     []int       // offsets in cacheBuffer released by swapIndex()
+	hits, misses    int         // cache statistics
+	evictions       int
+	policy          evictionPolicy
+	last            *cacheNode // row returned by the last getData, which the solver may still be using with the next one
+}
 
-	c.head[i].refCount++ // count reference to this index
+/**
+ * Returns the cache row for index i, and the number of leading entries that are already valid.
+ * The caller must compute the entries [start,length) of the returned row.
+ */
+func (c *cache[T]) getData(i, length int) ([]T, int) {
+	h := &(c.head[i])
 
-	if c.head[i].offset != -1 {
-		h := &(c.head[i])
-		c.cacheList.Remove(h.element) // Remove from LRU list so we can re-insert into the back
-		newData = false
-		c.hits++
-	}
+	h.refCount++ // count reference to this index
 
-	if newData {
+	if h.offset != -1 {
+		c.policy.touch(h)
+	} else {
 		var useOffset int = 0
 		// new data
 		if c.cacheAvail == 0 { // no more space in cache
 			// free a row
-
-			//Remove the front of the LRU list (the last used)
-			oldElement := c.cacheList.Front()
-			value := c.cacheList.Remove(oldElement)
-			old := value.(*cacheNode)
+			old := c.policy.victim(c.last)
+			c.policy.remove(old)
 
 			useOffset = old.offset // reuse its memory
 			old.offset = -1
+			old.length = 0
 
 			c.cacheAvail++
+			c.evictions++
+		} else if n := len(c.freeOffsets); n > 0 {
+			useOffset = c.freeOffsets[n-1]
+			c.freeOffsets = c.freeOffsets[:n-1]
 		} else {
 			useOffset = c.availableOffset
 			c.availableOffset += c.rowSize
 		}
 
-		c.head[i].data = c.cacheBuffer[useOffset : useOffset+c.rowSize]
-		c.head[i].offset = useOffset // remember which offsef this cache line has been assigned
+		h.offset = useOffset // remember which offsef this cache line has been assigned
+		h.length = 0
 
 		c.cacheAvail--
+		c.policy.insert(h)
+	}
 
+	start := h.length
+	if start < length {
+		h.length = length
 		c.misses++
+	} else {
+		c.hits++
 	}
 
-	h := &(c.head[i])
-	h.element = c.cacheList.PushBack(h) // Inserts it at the back of LRU circular list
-	return c.head[i].data, newData
+	c.last = h
+	return c.row(h), start
 }
 
-func (c cache) stats() {
-	fmt.Printf("Cache misses:     %d\n", c.misses)
-	fmt.Printf("Cache hits:       %d\n", c.hits)
-	fmt.Printf("Cache efficiency: %.6f%%\n", float32(c.hits)/float32(c.hits+c.misses)*100)
+/**
+ * Returns the row held by h, which is in the cache
+ */
+func (c *cache[T]) row(h *cacheNode) []T {
+	return c.cacheBuffer[h.offset : h.offset+c.rowSize]
 }
 
-func computeCacheSize(rowSize, cacheSize int) int {
+/**
+ * Releases the cache row held by h
+ */
+func (c *cache[T]) release(h *cacheNode) {
+	c.policy.remove(h)
+	c.freeOffsets = append(c.freeOffsets, h.offset)
+	h.offset = -1
+	h.length = 0
+	c.cacheAvail++
+}
 
-	cacheSizeBytes := cacheSize * (1 << 20)                     // multiply by 1 Mbytes
-	numCols := cacheSizeBytes / (rowSize * sizeOfCacheDataType) // num of rows we can store
-	numCols = maxi(2, numCols)                                  // we should be able to store at least 2
+/**
+ * Swaps the rows i and j, and the columns i and j of every cached row.  Used by the solver when shrinking.
+ */
+func (c *cache[T]) swapIndex(i, j int) {
+	if i == j {
+		return
+	}
+
+	hi := &(c.head[i])
+	hj := &(c.head[j])
+	if hi.offset != -1 {
+		c.policy.remove(hi)
+	}
+	if hj.offset != -1 {
+		c.policy.remove(hj)
+	}
+	hi.offset, hj.offset = hj.offset, hi.offset
+	hi.length, hj.length = hj.length, hi.length
+	hi.refCount, hj.refCount = hj.refCount, hi.refCount
+	if hi.offset != -1 {
+		c.policy.insert(hi)
+	}
+	if hj.offset != -1 {
+		c.policy.insert(hj)
+	}
+
+	if i > j {
+		i, j = j, i
+	}
+	c.policy.each(func(h *cacheNode) {
+		if h.length > i {
+			if h.length > j {
+				data := c.row(h)
+				data[i], data[j] = data[j], data[i]
+			} else {
+				c.release(h) // give up on this row
+			}
+		}
+	})
+}
+
+/********** LRU POLICY ***************/
+type lruPolicy struct {
+	rows *list.List // the last used at the back
+}
+
+func (p *lruPolicy) insert(h *cacheNode) {
+	h.element = p.rows.PushBack(h)
+}
+
+func (p *lruPolicy) touch(h *cacheNode) {
+	p.rows.MoveToBack(h.element)
+}
+
+func (p *lruPolicy) remove(h *cacheNode) {
+	p.rows.Remove(h.element)
+	h.element = nil
+}
+
+func (p *lruPolicy) victim(keep *cacheNode) *cacheNode {
+	e := p.rows.Front()
+	if e.Value.(*cacheNode) == keep {
+		e = e.Next()
+	}
+	return e.Value.(*cacheNode)
+}
+
+func (p *lruPolicy) each(visit func(*cacheNode)) {
+	for e := p.rows.Front(); e != nil; {
+		next := e.Next() // remember next, since visit may remove e from the list
+		visit(e.Value.(*cacheNode))
+		e = next
+	}
+}
+
+/********** LFU POLICY ***************/
+
+/**
+ * Keeps the rows in a min-heap of their reference counts, which include the references made while a row
+ * was out of the cache, so that a row used often is not evicted for one that was used once
+ */
+type lfuPolicy struct {
+	rows []*cacheNode
+}
+
+func (p *lfuPolicy) Len() int           { return len(p.rows) }
+func (p *lfuPolicy) Less(a, b int) bool { return p.rows[a].refCount < p.rows[b].refCount }
+
+func (p *lfuPolicy) Swap(a, b int) {
+	p.rows[a], p.rows[b] = p.rows[b], p.rows[a]
+	p.rows[a].heapIndex = a
+	p.rows[b].heapIndex = b
+}
+
+func (p *lfuPolicy) Push(x interface{}) {
+	h := x.(*cacheNode)
+	h.heapIndex = len(p.rows)
+	p.rows = append(p.rows, h)
+}
+
+func (p *lfuPolicy) Pop() interface{} {
+	n := len(p.rows) - 1
+	h := p.rows[n]
+	p.rows = p.rows[:n]
+	h.heapIndex = -1
+	return h
+}
+
+func (p *lfuPolicy) insert(h *cacheNode) {
+	heap.Push(p, h)
+}
+
+func (p *lfuPolicy) touch(h *cacheNode) {
+	heap.Fix(p, h.heapIndex)
+}
+
+func (p *lfuPolicy) remove(h *cacheNode) {
+	heap.Remove(p, h.heapIndex)
+}
+
+func (p *lfuPolicy) victim(keep *cacheNode) *cacheNode {
+	if p.rows[0] != keep {
+		return p.rows[0]
+	}
+	if len(p.rows) > 2 && p.Less(2, 1) { // the smallest count after the root is one of its children
+		return p.rows[2]
+	}
+	return p.rows[1]
+}
+
+func (p *lfuPolicy) each(visit func(*cacheNode)) {
+	rows := make([]*cacheNode, len(p.rows)) // visit may remove rows from the heap
+	copy(rows, p.rows)
+	for _, h := range rows {
+		visit(h)
+	}
+}
+
+/********** FULL MATRIX ***************/
+
+/**
+ * Used when the cache can hold every row of the Q matrix, so that no row is ever evicted and there is no
+ * order of the rows to maintain
+ */
+type fullPolicy struct {
+	head []cacheNode
+}
+
+func (p *fullPolicy) insert(h *cacheNode) {}
+func (p *fullPolicy) touch(h *cacheNode)  {}
+func (p *fullPolicy) remove(h *cacheNode) {}
+
+func (p *fullPolicy) victim(keep *cacheNode) *cacheNode {
+	panic("the full Q matrix cache has no row to evict")
+}
+
+func (p *fullPolicy) each(visit func(*cacheNode)) {
+	for i := range p.head {
+		if p.head[i].offset != -1 {
+			visit(&p.head[i])
+		}
+	}
+}
+
+func computeCacheSize(rowSize, valueSize, cacheSize int) int {
+
+	cacheSizeBytes := cacheSize * (1 << 20)           // multiply by 1 Mbytes
+	numCols := cacheSizeBytes / (rowSize * valueSize) // num of rows we can store
+	numCols = maxi(2, numCols)                        // we should be able to store at least 2
 
 	return numCols
 }
 
-func newCache(l, rowSize, cacheSize int) *cache {
+/**
+ * Returns the cache of the l rows of a Q matrix, holding at most cacheSize MB, which evicts rows with
+ * policy (LRU_CACHE or LFU_CACHE) unless it can hold all of them
+ */
+func newCache[T cacheValue](l, rowSize, cacheSize, policy int) *cache[T] {
 
-	rowCacheSize := computeCacheSize(rowSize, cacheSize) // number of rows we can cache
+	rowCacheSize := computeCacheSize(rowSize, sizeOfCacheValue[T](), cacheSize) // number of rows we can cache
+	rowCacheSize = mini(rowCacheSize, l)                                        // no more than all the rows
 
 	head := make([]cacheNode, l)
 	for i := 0; i < l; i++ {
 		head[i].index = i
 		head[i].refCount = 0
-		head[i].data = nil
 		head[i].offset = -1
+		head[i].heapIndex = -1
 	}
 
-	c := cache{head: head, rowSize: rowSize, cacheAvail: rowCacheSize, availableOffset: 0, hits: 0, misses: 0}
-	c.cacheBuffer = make([]cacheDataType, rowCacheSize*rowSize)
-	c.cacheList = list.New()
+	c := cache[T]{head: head, rowSize: rowSize, cacheAvail: rowCacheSize, availableOffset: 0, hits: 0, misses: 0}
+	c.cacheBuffer = make([]T, rowCacheSize*rowSize)
+
+	switch {
+	case rowCacheSize == l:
+		c.policy = &fullPolicy{head: head}
+	case policy == LFU_CACHE:
+		c.policy = &lfuPolicy{}
+	default:
+		c.policy = &lruPolicy{rows: list.New()}
+	}
 
 	return &c
 }
@@ -0,0 +1,165 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the kernel cache of the Q matrices and its eviction policies
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"reflect"
+	"testing"
+)
+
+/**
+ * Returns a cache of l rows that holds 3 of them
+ */
+func newSmallCache[T cacheValue](l, policy int) *cache[T] {
+	return newCache[T](l, (1<<20)/(3*sizeOfCacheValue[T]()), 1, policy)
+}
+
+/**
+ * Returns the rows of c that are in the cache
+ */
+func cachedRows[T cacheValue](c *cache[T]) []int {
+	var rows []int
+	for i := range c.head {
+		if c.head[i].offset != -1 {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+/**
+ * LRU evicts the least recently used row, and LFU the least referenced one, counting the references made
+ * while a row was out of the cache, other than the row returned last, which the solver may still be using
+ */
+func TestCacheEviction(t *testing.T) {
+	tests := []struct {
+		policy                  int
+		gets                    []int
+		cached                  []int
+		hits, misses, evictions int
+	}{
+		{LRU_CACHE, []int{0, 1, 2, 0, 3}, []int{0, 2, 3}, 1, 4, 1},
+		{LRU_CACHE, []int{0, 1, 2, 3, 4}, []int{2, 3, 4}, 0, 5, 2},
+		{LFU_CACHE, []int{0, 0, 0, 1, 2, 2, 0, 3}, []int{0, 2, 3}, 4, 4, 1},
+		{LFU_CACHE, []int{0, 0, 0, 1, 1, 2, 3}, []int{0, 2, 3}, 3, 4, 1},
+		{LFU_CACHE, []int{2, 0, 0, 0, 1, 1, 2, 3, 3, 3, 4}, []int{0, 3, 4}, 6, 5, 2},
+		{LFU_CACHE, []int{0, 0, 1, 2, 3, 1, 1, 4}, []int{0, 1, 4}, 2, 6, 3},
+	}
+	for _, test := range tests {
+		c := newSmallCache[float32](5, test.policy)
+		for _, i := range test.gets {
+			if data, start := c.getData(i, 1); start == 0 {
+				data[0] = float32(i)
+			} else if data[0] != float32(i) {
+				t.Errorf("policy %d, rows %v: row %d holds %v", test.policy, test.gets, i, data[0])
+			}
+		}
+		if got := cachedRows(c); !reflect.DeepEqual(got, test.cached) || c.hits != test.hits || c.misses != test.misses || c.evictions != test.evictions {
+			t.Errorf("policy %d, rows %v: cached %v with %d hits, %d misses and %d evictions, want %v, %d, %d and %d", test.policy, test.gets,
+				got, c.hits, c.misses, c.evictions, test.cached, test.hits, test.misses, test.evictions)
+		}
+	}
+}
+
+/**
+ * A cache that holds every row never evicts, and a row is computed again only for its missing entries
+ */
+func TestCacheFull(t *testing.T) {
+	for _, policy := range []int{LRU_CACHE, LFU_CACHE} {
+		c := newCache[float64](5, 5, 1, policy)
+		if _, full := c.policy.(*fullPolicy); !full {
+			t.Fatalf("policy %d: %T for a cache of every row", policy, c.policy)
+		}
+		for k := 0; k < 3; k++ {
+			for i := 0; i < 5; i++ {
+				c.getData(i, 2+k/2)
+			}
+		}
+		if _, start := c.getData(3, 5); start != 3 || c.evictions != 0 || c.hits != 5 || c.misses != 11 {
+			t.Errorf("policy %d: start %d, %d evictions, %d hits, %d misses", policy, start, c.evictions, c.hits, c.misses)
+		}
+	}
+}
+
+/**
+ * Swapping two rows swaps their columns in the rows long enough to have both, and releases the rows that
+ * only have the first one
+ */
+func TestCacheSwapIndex(t *testing.T) {
+	for _, policy := range []int{LRU_CACHE, LFU_CACHE} {
+		c := newSmallCache[float64](5, policy)
+		for i, length := range []int{5, 2, 4} {
+			data, _ := c.getData(i, length)
+			for j := 0; j < length; j++ {
+				data[j] = float64(10*i + j)
+			}
+		}
+
+		c.swapIndex(1, 3)
+		if got := cachedRows(c); !reflect.DeepEqual(got, []int{0, 2}) || c.cacheAvail != 1 {
+			t.Fatalf("policy %d: cached %v, and room for %d rows", policy, got, c.cacheAvail)
+		}
+		if data, start := c.getData(0, 5); start != 5 || !reflect.DeepEqual(data[:5], []float64{0, 3, 2, 1, 4}) {
+			t.Errorf("policy %d: row 0 %v of %d entries", policy, data[:5], start)
+		}
+		if data, start := c.getData(2, 4); start != 4 || !reflect.DeepEqual(data[:4], []float64{20, 23, 22, 21}) {
+			t.Errorf("policy %d: row 2 %v of %d entries", policy, data[:4], start)
+		}
+
+		c.getData(4, 1) // takes the released row, without evicting
+		if got := cachedRows(c); !reflect.DeepEqual(got, []int{0, 2, 4}) || c.evictions != 0 {
+			t.Errorf("policy %d: cached %v with %d evictions", policy, got, c.evictions)
+		}
+	}
+}
+
+/**
+ * The policies and the precisions of the cache give the same model, the precision up to float32 rounding,
+ * and report the hits, misses and evictions of a cache too small for the Q matrix
+ */
+func TestCachePolicies(t *testing.T) {
+	param := quietParameter(C_SVC)
+	param.CacheSize = 1 // 131 rows of 2000 float32
+	prob := mustProblem(t, blobsText(1, 2000, 4, 2), param)
+	want := mustTrain(t, prob, param)
+	text := modelText(t, want)
+	if report := want.Report(); report.CacheEvictions == 0 || report.CacheHits == 0 {
+		t.Fatalf("report %+v of a cache too small", *report)
+	}
+
+	param.CachePolicy = LFU_CACHE
+	lfu := mustTrain(t, prob, param)
+	if got := modelText(t, lfu); got != text || lfu.Report().CacheEvictions == 0 {
+		t.Errorf("LFU model with %d evictions:\n%s\nwant\n%s", lfu.Report().CacheEvictions, got, text)
+	}
+
+	param.CachePrecision = DOUBLE_PRECISION
+	double := mustTrain(t, prob, param)
+	if double.Report().CacheEvictions <= want.Report().CacheEvictions {
+		t.Errorf("%d evictions of float64 values, and %d of float32", double.Report().CacheEvictions, want.Report().CacheEvictions)
+	}
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		_, x := prob.GetLine()
+		_, got := double.PredictValues(x)
+		_, wantValues := want.PredictValues(x)
+		if !nearSlices(got, wantValues, 1e-3) {
+			t.Fatalf("decision values %v with float64 values, want %v", got, wantValues)
+		}
+	}
+}
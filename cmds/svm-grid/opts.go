@@ -0,0 +1,255 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** @author: Ed Walker
+ */
+package main
+
+import (
+	"fmt"
+	"github.com/ewalker544/libsvm-go"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func usage() {
+	fmt.Print(
+		"Usage: svm-grid [grid_options] [svm_options] dataset\n",
+		"grid_options :\n",
+		"-log2c {begin,end,step | \"null\"} : set the range of c (default -5,15,2)\n",
+		"    begin,end,step -- c_range = 2^{begin,...,begin+k*step,...,end}\n",
+		"    \"null\"         -- do not grid with c\n",
+		"-log2g {begin,end,step | \"null\"} : set the range of g (default 3,-15,-2)\n",
+		"    begin,end,step -- g_range = 2^{begin,...,begin+k*step,...,end}\n",
+		"    \"null\"         -- do not grid with g\n",
+		"-log2p {begin,end,step | \"null\"} : set the range of p for epsilon-SVR (default \"null\")\n",
+		"-nu {begin,end,step | \"null\"} : set the range of nu for nu-SVC, one-class SVM and nu-SVR (default \"null\")\n",
+		"-degree {begin,end,step | \"null\"} : set the range of the polynomial degree (default \"null\")\n",
+		"-v n : n-fold cross validation (default 5)\n",
+		"-workers n : number of grid points to cross validate concurrently (default: number of logical CPUs)\n",
+		"-out {pathname | \"null\"} : set output file path and name (default dataset.out)\n",
+		"-csv pathname : also write all grid results to a CSV file\n",
+		"-gnuplot, -png : accepted for compatibility with grid.py, and ignored\n",
+		"\n",
+		"Use this tool to search the best parameters of an SVM with cross validation.\n",
+		"The svm_options are the options of svm-train, e.g. -s, -t, -m, -e, -h, -b, -w and -q.\n")
+}
+
+func exitWithHelp() {
+	usage()
+	os.Exit(1)
+}
+
+type gridOptions struct {
+	dataset string
+	outFile string
+	csvFile string
+}
+
+/**
+ * Parses a range "begin,end,step", or "null" for no range
+ */
+func parseRange(option, value string) *libSvm.GridRange {
+	if value == "null" {
+		return nil
+	}
+	fields := strings.Split(value, ",")
+	if len(fields) != 3 {
+		fmt.Fprintf(os.Stderr, "Invalid range for %s: %s\n", option, value)
+		exitWithHelp()
+	}
+	var numbers [3]float64
+	for k, field := range fields {
+		var err error
+		if numbers[k], err = strconv.ParseFloat(field, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid range for %s: %s\n", option, value)
+			exitWithHelp()
+		}
+	}
+	return &libSvm.GridRange{Begin: numbers[0], End: numbers[1], Step: numbers[2]}
+}
+
+/**
+ * Parses the command line like grid.py: the grid options, and the svm-train options for the parameter
+ */
+func parseOptions(param *libSvm.Parameter) (*libSvm.GridOptions, gridOptions) {
+	args := os.Args[1:]
+	if len(args) < 1 {
+		exitWithHelp()
+	}
+
+	var opts gridOptions
+	opts.dataset = args[len(args)-1]
+	opts.outFile = filepath.Base(opts.dataset) + ".out"
+	args = args[:len(args)-1]
+
+	type rangeFlag struct {
+		value string
+		set   bool
+	}
+	ranges := map[string]*rangeFlag{"-log2c": {}, "-log2g": {}, "-log2p": {}, "-nu": {}, "-degree": {}}
+	var nrFold, workers int = 5, 0
+
+	value := func(k int) string {
+		if k >= len(args) {
+			exitWithHelp()
+		}
+		return args[k]
+	}
+	atoi := func(option, s string) int {
+		val, err := strconv.Atoi(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid value for %s: %s\n", option, s)
+			exitWithHelp()
+		}
+		return val
+	}
+	atof := func(option, s string) float64 {
+		val, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid value for %s: %s\n", option, s)
+			exitWithHelp()
+		}
+		return val
+	}
+
+	for k := 0; k < len(args); k++ {
+		option := args[k]
+		if r, ok := ranges[option]; ok {
+			k++
+			r.value, r.set = value(k), true
+			continue
+		}
+
+		switch option {
+		case "-v":
+			k++
+			nrFold = atoi(option, value(k))
+		case "-workers":
+			k++
+			workers = atoi(option, value(k))
+		case "-out":
+			k++
+			opts.outFile = value(k)
+		case "-csv":
+			k++
+			opts.csvFile = value(k)
+		case "-gnuplot", "-png":
+			k++
+			value(k)
+		case "-q":
+			param.QuietMode = true
+		case "-s":
+			k++
+			param.SvmType = atoi(option, value(k))
+		case "-t":
+			k++
+			param.KernelType = atoi(option, value(k))
+		case "-S":
+			k++
+			param.Solver = atoi(option, value(k))
+		case "-d":
+			k++
+			param.Degree = atoi(option, value(k))
+		case "-g":
+			k++
+			param.Gamma = atof(option, value(k))
+		case "-r":
+			k++
+			param.Coef0 = atof(option, value(k))
+		case "-c":
+			k++
+			param.C = atof(option, value(k))
+		case "-n":
+			k++
+			param.Nu = atof(option, value(k))
+		case "-p":
+			k++
+			param.P = atof(option, value(k))
+		case "-m":
+			k++
+			param.CacheSize = atoi(option, value(k))
+		case "-e":
+			k++
+			param.Eps = atof(option, value(k))
+		case "-h":
+			k++
+			param.Shrinking = atoi(option, value(k)) != 0
+		case "-b":
+			k++
+			param.Probability = atoi(option, value(k)) != 0
+		case "-N":
+			k++
+			param.NumCPU = atoi(option, value(k))
+		case "-P":
+			k++
+			param.SubproblemWorkers = atoi(option, value(k))
+		case "-x":
+			k++
+			strict := atoi(option, value(k))
+			param.StrictInput = strict > 0
+			param.SortIndices = strict == 2
+		case "-w": // -w i,weight
+			k++
+			fields := strings.Split(value(k), ",")
+			if len(fields) != 2 {
+				fmt.Fprintf(os.Stderr, "Invalid value for -w: %s\n", value(k))
+				exitWithHelp()
+			}
+			param.WeightLabel = append(param.WeightLabel, atoi(option, fields[0]))
+			param.Weight = append(param.Weight, atof(option, fields[1]))
+			param.NrWeight++
+		default:
+			if strings.HasPrefix(option, "-w") && len(option) > 2 { // LIBSVM's -wi weight
+				k++
+				param.WeightLabel = append(param.WeightLabel, atoi(option, option[2:]))
+				param.Weight = append(param.Weight, atof(option, value(k)))
+				param.NrWeight++
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Unknown option: %s\n", option)
+			exitWithHelp()
+		}
+	}
+
+	options := libSvm.NewGridOptions(param)
+	options.NrFold = nrFold
+	options.Workers = workers
+	for option, r := range ranges {
+		if !r.set {
+			continue
+		}
+		switch option {
+		case "-log2c":
+			options.Log2C = parseRange(option, r.value)
+		case "-log2g":
+			options.Log2Gamma = parseRange(option, r.value)
+		case "-log2p":
+			options.Log2P = parseRange(option, r.value)
+		case "-nu":
+			options.Nu = parseRange(option, r.value)
+		case "-degree":
+			options.Degree = parseRange(option, r.value)
+		}
+	}
+
+	if opts.outFile == "null" {
+		opts.outFile = ""
+	}
+
+	return options, opts
+}
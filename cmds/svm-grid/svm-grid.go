@@ -0,0 +1,180 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** @author: Ed Walker
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"github.com/ewalker544/libsvm-go"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Formats a float like Python's str(), as grid.py prints them
+ */
+func pyFloat(f float64) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strings.ToLower(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	if exp := math.Floor(math.Log10(math.Abs(f))); f != 0 && (exp < -4 || exp >= 16) {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		return s
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+/**
+ * Returns the rate as svm-train prints it, which is what grid.py reads
+ */
+func printedRate(rate float64) float64 {
+	val, _ := strconv.ParseFloat(fmt.Sprintf("%.6g", rate), 64)
+	return val
+}
+
+/**
+ * A searched dimension of the grid
+ */
+type dimension struct {
+	name  string                         // name in the output file, e.g. log2c
+	short string                         // name of the value in the best parameters, e.g. c
+	value func(libSvm.GridPoint) float64 // value of the grid point, as searched
+	best  func(libSvm.GridPoint) float64 // value of the parameter
+}
+
+func searchedDimensions(options *libSvm.GridOptions) []dimension {
+	var dims []dimension
+	if options.Log2C != nil {
+		dims = append(dims, dimension{"log2c", "c", func(p libSvm.GridPoint) float64 { return p.Log2C },
+			func(p libSvm.GridPoint) float64 { return math.Pow(2, p.Log2C) }})
+	}
+	if options.Log2Gamma != nil {
+		dims = append(dims, dimension{"log2g", "g", func(p libSvm.GridPoint) float64 { return p.Log2Gamma },
+			func(p libSvm.GridPoint) float64 { return math.Pow(2, p.Log2Gamma) }})
+	}
+	if options.Log2P != nil {
+		dims = append(dims, dimension{"log2p", "p", func(p libSvm.GridPoint) float64 { return p.Log2P },
+			func(p libSvm.GridPoint) float64 { return math.Pow(2, p.Log2P) }})
+	}
+	if options.Nu != nil {
+		dims = append(dims, dimension{"nu", "nu", func(p libSvm.GridPoint) float64 { return p.Nu },
+			func(p libSvm.GridPoint) float64 { return p.Nu }})
+	}
+	if options.Degree != nil {
+		dims = append(dims, dimension{"degree", "degree", func(p libSvm.GridPoint) float64 { return float64(p.Degree) },
+			func(p libSvm.GridPoint) float64 { return float64(p.Degree) }})
+	}
+	return dims
+}
+
+func main() {
+	param := libSvm.NewParameter()       // create a parameter type
+	options, opts := parseOptions(param) // parse the grid options and the svm-train options
+	dims := searchedDimensions(options)
+
+	prob, err := libSvm.NewProblem(opts.dataset, param) // create a problem type from the dataset and the parameter
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fail to create a libSvm.Problem: %v\n", err)
+		os.Exit(1)
+	}
+
+	var outFp *bufio.Writer
+	if opts.outFile != "" {
+		f, err := os.Create(opts.outFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fail to create the output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		outFp = bufio.NewWriter(f)
+		defer outFp.Flush()
+	}
+
+	options.Progress = func(point, best libSvm.GridPoint) { // print each grid point like grid.py
+		var values, bests, output []string
+		for _, dim := range dims {
+			values = append(values, pyFloat(dim.value(point)))
+			bests = append(bests, fmt.Sprintf("%s=%s, ", dim.short, pyFloat(dim.best(best))))
+			output = append(output, fmt.Sprintf("%s=%s ", dim.name, pyFloat(dim.value(point))))
+		}
+		rate := pyFloat(printedRate(point.Rate))
+		fmt.Printf("[local] %s %s (best %srate=%s)\n", strings.Join(values, " "), rate, strings.Join(bests, ""), pyFloat(printedRate(best.Rate)))
+		if outFp != nil {
+			fmt.Fprintf(outFp, "%srate=%s\n", strings.Join(output, ""), rate)
+			outFp.Flush()
+		}
+	}
+
+	result, err := libSvm.GridSearch(prob, param, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fail to search the grid: %v\n", err)
+		os.Exit(1)
+	}
+
+	var bests []string
+	for _, dim := range dims {
+		bests = append(bests, pyFloat(dim.best(result.Best)))
+	}
+	fmt.Printf("%s %s\n", strings.Join(bests, " "), pyFloat(printedRate(result.Best.Rate)))
+
+	if opts.csvFile != "" {
+		if err := writeCSV(opts.csvFile, dims, result.Points); err != nil {
+			fmt.Fprintf(os.Stderr, "Fail to write the CSV file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+/**
+ * Writes the searched values and the rate of every grid point
+ */
+func writeCSV(file string, dims []dimension, points []libSvm.GridPoint) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	var header []string
+	for _, dim := range dims {
+		header = append(header, dim.name)
+	}
+	w.Write(append(header, "rate"))
+
+	for _, point := range points {
+		var record []string
+		for _, dim := range dims {
+			record = append(record, strconv.FormatFloat(dim.value(point), 'g', -1, 64))
+		}
+		w.Write(append(record, strconv.FormatFloat(point.Rate, 'g', -1, 64)))
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
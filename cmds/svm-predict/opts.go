@@ -30,6 +30,7 @@ import (
 
 var outFP io.Writer = os.Stdout
 var gParam *libSvm.Parameter
+var gMetrics []string // metrics to print instead of the accuracy or mean squared error
 
 type probabilityType int
 
@@ -40,7 +41,7 @@ func (q *probabilityType) String() string {
 func (q *probabilityType) Set(value string) error {
 	val, err := strconv.Atoi(value)
 	if err != nil || val < 0 || val > 1 {
-		return fmt.Errorf("Invalid probability value (-b %d)\n", val)
+		return fmt.Errorf("Invalid probability value (-b %d)", val)
 	}
 	if val == 0 {
 		gParam.Probability = false
@@ -50,11 +51,44 @@ func (q *probabilityType) Set(value string) error {
 	return nil
 }
 
+type metricsType int
+
+func (q *metricsType) String() string {
+	return ("Metrics Type")
+}
+
+func (q *metricsType) Set(value string) error {
+	names, err := libSvm.ParseMetricNames(value)
+	if err != nil {
+		return err
+	}
+	gMetrics = names
+	return nil
+}
+
+type strictType int
+
+func (q *strictType) String() string {
+	return ("Strict Type")
+}
+
+func (q *strictType) Set(value string) error {
+	val, err := strconv.Atoi(value)
+	if err != nil || val < 0 || val > 2 {
+		return fmt.Errorf("Invalid strict value (-x %d)", val)
+	}
+	gParam.StrictInput = val > 0
+	gParam.SortIndices = val == 2
+	return nil
+}
+
 func usage() {
 	fmt.Print(
 		"Usage: svm-predict [options] test_file model_file [output_file]\n",
 		"options:\n",
 		"-b probability_estimates: whether to predict probability estimates, 0 or 1 (default 0); for one-class SVM only 0 is supported\n",
+		"-x strict : check the input data strictly, 0 no, 1 reject unsorted or duplicate indices and non-finite values, 2 like 1 but sort unsorted indices (default 0)\n",
+		"-M metrics : print the comma-separated metrics instead of the accuracy or mean squared error (all, accuracy, confusion, class, macro, micro, balanced, mcc, auc, prauc, logloss, mse, scc, mae, rmse, r2)\n",
 		"-q : quiet mode (no outputs)\n")
 }
 
@@ -63,9 +97,13 @@ func parseOptions(param *libSvm.Parameter) (testFile string, modelFile string, o
 	gParam = param
 
 	var probabilityTypeFlag probabilityType
+	var strictTypeFlag strictType
+	var metricsTypeFlag metricsType
 
 	flag.Var(&probabilityTypeFlag, "b", "")
 	flag.BoolVar(&param.QuietMode, "q", false, "")
+	flag.Var(&strictTypeFlag, "x", "")
+	flag.Var(&metricsTypeFlag, "M", "")
 
 	flag.Usage = usage
 	flag.Parse()
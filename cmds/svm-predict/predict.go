@@ -26,24 +26,28 @@ import (
 func runPrediction(prob *libSvm.Problem, param *libSvm.Parameter, model *libSvm.Model, outputFp io.Writer) {
 
 	squareErr := libSvm.NewSquareErrorComputer()
+	eval := libSvm.NewEvaluation(param.SvmType, model.Labels())
 	var total int = 0
 	var correct int = 0
 
 	for prob.Begin(); !prob.Done(); prob.Next() { // Iterate through the entire label/vector problem set
 
 		// read each vector in the problem file, one at a time
-		targetLabel, x := prob.GetLine() // get the target label and its vector
+		targetLabel, x := prob.GetSparseLine() // get the target label and its vector
 
 		var predictLabel float64
 		if param.Probability && (param.SvmType == libSvm.C_SVC || param.SvmType == libSvm.NU_SVC) {
-			label, probabilityEstimate := model.PredictProbability(x)
+			label, probabilityEstimate := model.PredictProbabilitySparse(x)
 			predictLabel = label
+			eval.AddProbabilities(probabilityEstimate, targetLabel)
 			for j := 0; j < model.NrClass(); j++ {
 				fmt.Fprintf(outputFp, " %g", probabilityEstimate[j])
 			}
 			fmt.Fprintln(outputFp, "")
 		} else {
-			predictLabel = model.Predict(x)
+			label, decisionValues := model.PredictValuesSparse(x)
+			predictLabel = label
+			eval.AddDecisionValues(decisionValues, targetLabel)
 			fmt.Fprintf(outputFp, " %g\n", predictLabel)
 		}
 
@@ -52,10 +56,13 @@ func runPrediction(prob *libSvm.Problem, param *libSvm.Parameter, model *libSvm.
 		}
 
 		squareErr.Sum(predictLabel, targetLabel)
+		eval.Add(predictLabel, targetLabel)
 		total++
 	}
 
-	if param.SvmType == libSvm.NU_SVR || param.SvmType == libSvm.EPSILON_SVR {
+	if len(gMetrics) > 0 {
+		eval.WriteMetrics(outFP, gMetrics)
+	} else if param.SvmType == libSvm.NU_SVR || param.SvmType == libSvm.EPSILON_SVR {
 		fmt.Fprintf(outFP, "Mean squared error = %.6g (regression)\n", squareErr.MeanSquareError())
 		fmt.Fprintf(outFP, "Squared correlation coefficient = %.6g (regression)\n", squareErr.SquareCorrelationCoeff())
 	} else {
@@ -29,19 +29,21 @@ func main() {
 	testFile, modelFile, outputFile := parseOptions(param) // parse command-line flags for SVM parameter
 	outputFp, err := os.Create(outputFile)                 // create output file
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Fail to create the output file: %v\n", err)
+		os.Exit(1)
 	}
+	defer outputFp.Close()
 
 	prob, err := libSvm.NewProblem(testFile, param) // create a problem type
 	if err != nil {
-		fmt.Fprint(os.Stderr, "Fail to create a problem type:", err)
+		fmt.Fprintf(os.Stderr, "Fail to create a problem type: %v\n", err)
 		os.Exit(1)
 	}
 
 	model := libSvm.NewModel(param) // create a model type
 
 	if err := model.ReadModel(modelFile); err != nil { // populate model with properties in model file
-		fmt.Fprint(os.Stderr, "Fail to read model file: ", err)
+		fmt.Fprintf(os.Stderr, "Fail to read model file: %v\n", err)
 		os.Exit(1)
 	}
 
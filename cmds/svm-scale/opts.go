@@ -0,0 +1,104 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** @author: Ed Walker
+ */
+package main
+
+import (
+	"fmt"
+	"github.com/ewalker544/libsvm-go"
+	"os"
+	"strconv"
+)
+
+func usage() {
+	fmt.Print(
+		"Usage: svm-scale [options] data_filename\n",
+		"options:\n",
+		"-l lower : x scaling lower limit (default -1)\n",
+		"-u upper : x scaling upper limit (default +1)\n",
+		"-y y_lower y_upper : y scaling limits (default: no y scaling)\n",
+		"-s save_filename : save scaling parameters to save_filename\n",
+		"-r restore_filename : restore scaling parameters from restore_filename\n")
+}
+
+func exitWithHelp() {
+	usage()
+	os.Exit(1)
+}
+
+/**
+ * Parses the command line like svm-scale, which the flag package cannot do because -y takes two values
+ */
+func parseOptions(scaler *libSvm.Scaler) (dataFile string, saveFile string, restoreFile string) {
+	args := os.Args[1:]
+
+	parseFloat := func(k int) float64 {
+		if k >= len(args) {
+			exitWithHelp()
+		}
+		val, err := strconv.ParseFloat(args[k], 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid number %s\n", args[k])
+			exitWithHelp()
+		}
+		return val
+	}
+
+	var k int
+	for k = 0; k < len(args) && len(args[k]) > 1 && args[k][0] == '-'; k++ {
+		switch args[k] {
+		case "-l":
+			k++
+			scaler.Lower = parseFloat(k)
+		case "-u":
+			k++
+			scaler.Upper = parseFloat(k)
+		case "-y":
+			scaler.ScaleY = true
+			scaler.YLower = parseFloat(k + 1)
+			scaler.YUpper = parseFloat(k + 2)
+			k += 2
+		case "-s":
+			k++
+			if k >= len(args) {
+				exitWithHelp()
+			}
+			saveFile = args[k]
+		case "-r":
+			k++
+			if k >= len(args) {
+				exitWithHelp()
+			}
+			restoreFile = args[k]
+		default:
+			fmt.Fprintf(os.Stderr, "unknown option %s\n", args[k])
+			exitWithHelp()
+		}
+	}
+
+	if k != len(args)-1 {
+		exitWithHelp()
+	}
+	dataFile = args[k]
+
+	if saveFile != "" && restoreFile != "" {
+		fmt.Fprint(os.Stderr, "cannot use -r and -s simultaneously\n")
+		os.Exit(1)
+	}
+
+	return // dataFile, saveFile, restoreFile
+}
@@ -0,0 +1,59 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** @author: Ed Walker
+ */
+package main
+
+import (
+	"fmt"
+	"github.com/ewalker544/libsvm-go"
+	"os"
+)
+
+func main() {
+	scaler := libSvm.NewScaler()                            // scale to [-1,1] unless -l or -u are given
+	dataFile, saveFile, restoreFile := parseOptions(scaler) // parse command-line flags for the scaling limits
+
+	prob, err := libSvm.NewProblem(dataFile, libSvm.NewParameter()) // read the data to scale
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fail to create a libSvm.Problem: %v\n", err)
+		os.Exit(1)
+	}
+
+	if restoreFile != "" {
+		if scaler, err = libSvm.NewScalerFromFile(restoreFile); err != nil { // scaling parameters from a previous run
+			fmt.Fprintf(os.Stderr, "Fail to restore the scaling parameters: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		if err := scaler.Fit(prob); err != nil { // learn the scaling parameters from the data
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if saveFile != "" {
+		if err := scaler.Dump(saveFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Fail to save the scaling parameters: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if _, err := scaler.ScaleProblem(prob).WriteTo(os.Stdout); err != nil { // write the scaled data to stdout
+		fmt.Fprintf(os.Stderr, "Fail to write the scaled data: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -20,11 +20,27 @@ package main
 import (
 	"fmt"
 	"github.com/ewalker544/libsvm-go"
+	"os"
 )
 
 func doCrossValidation(prob *libSvm.Problem, param *libSvm.Parameter, nrFold int) {
 
-	targets := libSvm.CrossValidation(prob, param, nrFold)
+	if len(gMetrics) > 0 {
+		eval, err := libSvm.EvaluateCrossValidation(prob, param, nrFold)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fail to cross validate: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprint(outFP, "Cross Validation:\n")
+		eval.WriteMetrics(outFP, gMetrics)
+		return
+	}
+
+	targets, err := libSvm.CrossValidation(prob, param, nrFold)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fail to cross validate: %v\n", err)
+		os.Exit(1)
+	}
 
 	if param.SvmType == libSvm.EPSILON_SVR || param.SvmType == libSvm.NU_SVR {
 
@@ -30,6 +30,7 @@ import (
 
 var outFP io.Writer = os.Stdout
 var gParam *libSvm.Parameter
+var gMetrics []string // metrics to print instead of the accuracy or mean squared error
 
 type probabilityType int
 
@@ -40,7 +41,7 @@ func (q *probabilityType) String() string {
 func (q *probabilityType) Set(value string) error {
 	val, err := strconv.Atoi(value)
 	if err != nil || val < 0 || val > 1 {
-		return fmt.Errorf("Invalid probability value (-b %d)\n", val)
+		return fmt.Errorf("Invalid probability value (-b %d)", val)
 	}
 	if val == 0 {
 		gParam.Probability = false
@@ -50,6 +51,56 @@ func (q *probabilityType) Set(value string) error {
 	return nil
 }
 
+type shrinkingType int
+
+func (q *shrinkingType) String() string {
+	return ("Shrinking Type")
+}
+
+func (q *shrinkingType) Set(value string) error {
+	val, err := strconv.Atoi(value)
+	if err != nil || val < 0 || val > 1 {
+		return fmt.Errorf("Invalid shrinking value (-h %d)", val)
+	}
+	if val == 0 {
+		gParam.Shrinking = false
+	} else {
+		gParam.Shrinking = true
+	}
+	return nil
+}
+
+type metricsType int
+
+func (q *metricsType) String() string {
+	return ("Metrics Type")
+}
+
+func (q *metricsType) Set(value string) error {
+	names, err := libSvm.ParseMetricNames(value)
+	if err != nil {
+		return err
+	}
+	gMetrics = names
+	return nil
+}
+
+type strictType int
+
+func (q *strictType) String() string {
+	return ("Strict Type")
+}
+
+func (q *strictType) Set(value string) error {
+	val, err := strconv.Atoi(value)
+	if err != nil || val < 0 || val > 2 {
+		return fmt.Errorf("Invalid strict value (-x %d)", val)
+	}
+	gParam.StrictInput = val > 0
+	gParam.SortIndices = val == 2
+	return nil
+}
+
 type svmType int
 
 func (q *svmType) String() string {
@@ -59,7 +110,7 @@ func (q *svmType) String() string {
 func (q *svmType) Set(value string) error {
 	val, err := strconv.Atoi(value)
 	if err != nil || val < 0 || val > 4 {
-		return fmt.Errorf("Invalid svm type (-s %d)\n", val)
+		return fmt.Errorf("Invalid svm type (-s %d)", val)
 	}
 	gParam.SvmType = val
 	return nil
@@ -74,12 +125,27 @@ func (q *kernelType) String() string {
 func (q *kernelType) Set(value string) error {
 	val, err := strconv.Atoi(value)
 	if err != nil || val < 0 || val > 4 {
-		return fmt.Errorf("Invalid kernel type (-t %d)\n", val)
+		return fmt.Errorf("Invalid kernel type (-t %d)", val)
 	}
 	gParam.KernelType = val
 	return nil
 }
 
+type solverType int
+
+func (q *solverType) String() string {
+	return string("Solver Type")
+}
+
+func (q *solverType) Set(value string) error {
+	val, err := strconv.Atoi(value)
+	if err != nil || val < 0 || val > 4 {
+		return fmt.Errorf("Invalid solver (-S %d)", val)
+	}
+	gParam.Solver = val
+	return nil
+}
+
 type weightType int
 
 func (q *weightType) String() string {
@@ -102,6 +168,7 @@ func (q *weightType) Set(value string) error {
 
 	gParam.WeightLabel = append(gParam.WeightLabel, weightLabel)
 	gParam.Weight = append(gParam.Weight, weight)
+	gParam.NrWeight++
 
 	return nil
 }
@@ -122,6 +189,12 @@ func usage() {
 		"	2 -- radial basis function: exp(-gamma*|u-v|^2)\n",
 		"	3 -- sigmoid: tanh(gamma*u'*v + coef0)\n",
 		"	4 -- precomputed kernel (kernel values in training_set_file)\n",
+		"-S solver : set the solver of a linear kernel C-SVC or epsilon-SVR (default 0)\n",
+		"	0 -- SMO (any svm and kernel type)\n",
+		"	1 -- L2-loss C-SVC dual coordinate descent\n",
+		"	2 -- L1-loss C-SVC dual coordinate descent\n",
+		"	3 -- L2-loss epsilon-SVR dual coordinate descent\n",
+		"	4 -- L1-loss epsilon-SVR dual coordinate descent\n",
 		"-d degree : set degree in kernel function (default 3)\n",
 		"-g gamma : set gamma in kernel function (default 1/num_features)\n",
 		"-r coef0 : set coef0 in kernel function (default 0)\n",
@@ -130,11 +203,15 @@ func usage() {
 		"-p epsilon : set the epsilon in loss function of epsilon-SVR (default 0.1)\n",
 		"-m cachesize : set cache memory size in MB (default 100)\n",
 		"-e epsilon : set tolerance of termination criterion (default 0.001)\n",
+		"-h shrinking : whether to use the shrinking heuristics, 0 or 1 (default 1)\n",
 		"-b probability_estimates : whether to train a SVC or SVR model for probability estimates, 0 or 1 (default 0)\n",
 		"-w i,weight : set the parameter C of class i to weight*C, for C-SVC (default 1)\n",
 		"-v n: n-fold cross validation mode\n",
+		"-x strict : check the input data strictly, 0 no, 1 reject unsorted or duplicate indices and non-finite values, 2 like 1 but sort unsorted indices (default 0)\n",
+		"-M metrics : print the comma-separated metrics of the cross validation instead of its accuracy or mean squared error (all, accuracy, confusion, class, macro, micro, balanced, mcc, auc, prauc, logloss, mse, scc, mae, rmse, r2)\n",
 		"-q : quiet mode (no outputs)\n",
-		"-N n: number of CPUs to use (default -1 uses all available logical CPUs)\n")
+		"-N n: number of CPUs to use (default -1 uses all available logical CPUs)\n",
+		"-P n: number of one-vs-one subproblems trained concurrently, sharing the cache size (default 1)\n")
 }
 
 func parseOptions(param *libSvm.Parameter) (nrFold int, trainFile string, modelFile string) {
@@ -144,9 +221,14 @@ func parseOptions(param *libSvm.Parameter) (nrFold int, trainFile string, modelF
 	var kernelTypeFlag kernelType
 	var weightTypeFlag weightType
 	var probabilityTypeFlag probabilityType
+	var shrinkingTypeFlag shrinkingType
+	var strictTypeFlag strictType
+	var metricsTypeFlag metricsType
+	var solverTypeFlag solverType
 
 	flag.Var(&svmTypeFlag, "s", "")
 	flag.Var(&kernelTypeFlag, "t", "")
+	flag.Var(&solverTypeFlag, "S", "")
 	flag.IntVar(&param.Degree, "d", 3, "")
 	flag.Float64Var(&param.Gamma, "g", 0, "")
 	flag.Float64Var(&param.C, "r", 0, "")
@@ -155,11 +237,15 @@ func parseOptions(param *libSvm.Parameter) (nrFold int, trainFile string, modelF
 	flag.Float64Var(&param.P, "p", 0.1, "")
 	flag.IntVar(&param.CacheSize, "m", 100, "")
 	flag.Float64Var(&param.Eps, "e", 0.001, "")
+	flag.Var(&shrinkingTypeFlag, "h", "")
 	flag.Var(&weightTypeFlag, "w", "")
 	flag.IntVar(&nrFold, "v", 0, "")
 	flag.Var(&probabilityTypeFlag, "b", "")
 	flag.BoolVar(&param.QuietMode, "q", false, "")
 	flag.IntVar(&param.NumCPU, "N", -1, "")
+	flag.IntVar(&param.SubproblemWorkers, "P", 1, "")
+	flag.Var(&strictTypeFlag, "x", "")
+	flag.Var(&metricsTypeFlag, "M", "")
 
 	flag.Usage = usage
 	flag.Parse()
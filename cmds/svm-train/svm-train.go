@@ -29,15 +29,26 @@ func main() {
 
 	prob, err := libSvm.NewProblem(trainFile, param) // create a problem type from the train file and the parameter
 	if err != nil {
-		fmt.Fprint(os.Stderr, "Fail to create a libSvm.Problem: ", err)
+		fmt.Fprintf(os.Stderr, "Fail to create a libSvm.Problem: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := param.Validate(prob); err != nil { // check the parameters before training
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 
 	if nrFold > 0 {
 		doCrossValidation(prob, param, nrFold)
 	} else {
-		model := libSvm.NewModel(param) // create a model from specified parameter
-		model.Train(prob)               // use model to train on the problem data
-		model.Dump(modelFile)           // dump model into the user-specified file
+		model := libSvm.NewModel(param)           // create a model from specified parameter
+		if err := model.Train(prob); err != nil { // use model to train on the problem data
+			fmt.Fprintf(os.Stderr, "Fail to train the model: %v\n", err)
+			os.Exit(1)
+		}
+		if err := model.Dump(modelFile); err != nil { // dump model into the user-specified file
+			fmt.Fprintf(os.Stderr, "Fail to save the model: %v\n", err)
+			os.Exit(1)
+		}
 	}
 }
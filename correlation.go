@@ -13,14 +13,17 @@
 ** See the License for the specific language governing permissions and
 ** limitations under the License.
 **
-** Description: Calculate the mean square error, and the square correlation coefficient
+** Description: Calculate the mean square error, the square correlation coefficient, and other regression errors
 ** @author: Ed Walker
  */
 
 package libSvm
 
+import "math"
+
 type SquareErrorComputer struct {
 	err   float64
+	abs   float64
 	sump  float64
 	sumt  float64
 	sumpp float64
@@ -31,6 +34,7 @@ type SquareErrorComputer struct {
 
 func (s *SquareErrorComputer) Sum(predict, target float64) {
 	s.err += (predict - target) * (predict - target)
+	s.abs += math.Abs(predict - target)
 	s.sump += predict
 	s.sumt += target
 	s.sumpp += predict * predict
@@ -50,6 +54,25 @@ func (s *SquareErrorComputer) SquareCorrelationCoeff() (coeff float64) {
 	return
 }
 
+func (s *SquareErrorComputer) MeanAbsoluteError() float64 {
+	return s.abs / float64(s.total)
+}
+
+func (s *SquareErrorComputer) RootMeanSquareError() float64 {
+	return math.Sqrt(s.MeanSquareError())
+}
+
+/**
+ * Returns the coefficient of determination R^2 = 1 - SSres/SStot, which is NaN if the targets are constant
+ */
+func (s *SquareErrorComputer) RSquared() float64 {
+	sstot := s.sumtt - s.sumt*s.sumt/float64(s.total)
+	if sstot == 0 {
+		return math.NaN()
+	}
+	return 1 - s.err/sstot
+}
+
 func NewSquareErrorComputer() SquareErrorComputer {
-	return SquareErrorComputer{err: 0, sump: 0, sumt: 0, sumpp: 0, sumtt: 0, sumpt: 0, total: 0}
+	return SquareErrorComputer{err: 0, abs: 0, sump: 0, sumt: 0, sumpp: 0, sumtt: 0, sumpt: 0, total: 0}
 }
@@ -0,0 +1,96 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Errors returned by the library
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidParameter = errors.New("invalid parameter") // the parameters fail Parameter.Validate
+	ErrParse            = errors.New("parse error")       // a problem or model file is malformed
+	ErrTrainingFailed   = errors.New("training failed")   // a subproblem could not be trained
+)
+
+/**
+ * Describes where a problem or model file is malformed.  errors.Is(err, ErrParse) holds for every ParseError.
+ */
+type ParseError struct {
+	File   string // name of the file, if known
+	Line   int    // line number, starting from 1
+	Column int    // column of the offending token, starting from 1 (0 when the whole line is at fault)
+	Msg    string
+	Err    error // underlying error, e.g. from strconv (may be nil)
+}
+
+func (e *ParseError) Error() string {
+	var pos string
+	if e.File != "" {
+		pos = e.File + ":"
+	}
+	pos += fmt.Sprintf("%d:", e.Line)
+	if e.Column > 0 {
+		pos += fmt.Sprintf("%d:", e.Column)
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s: %v", pos, e.Msg, e.Err)
+	}
+	return fmt.Sprintf("%s %s", pos, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+func newParseError(line, column int, err error, format string, a ...interface{}) *ParseError {
+	return &ParseError{Line: line, Column: column, Msg: fmt.Sprintf(format, a...), Err: err}
+}
+
+/**
+ * Sets the file name of a ParseError returned while reading file
+ */
+func withFileName(err error, file string) error {
+	var pe *ParseError
+	if errors.As(err, &pe) && pe.File == "" {
+		pe.File = file
+	}
+	return err
+}
+
+func invalidParameter(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParameter, fmt.Sprintf(format, a...))
+}
+
+/**
+ * Wraps the error of a failed subproblem so that errors.Is(err, ErrTrainingFailed) holds,
+ * while the cause can still be matched.  A TrainingStoppedError, or an error that is already
+ * wrapped, is returned as it is.
+ */
+func trainingFailed(err error) error {
+	if _, stopped := err.(*TrainingStoppedError); stopped || errors.Is(err, ErrTrainingFailed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTrainingFailed, err)
+}
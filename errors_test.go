@@ -0,0 +1,155 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the errors returned by the public API
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+ * The errors of the files that cannot be opened or created keep the error of the os package
+ */
+func TestFileErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing", "file")
+
+	_, err := NewProblem(missing, NewParameter())
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), missing) {
+		t.Errorf("NewProblem: %v", err)
+	}
+	if _, err := NewModelFromFile(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("NewModelFromFile: %v", err)
+	}
+	if _, err := NewScalerFromFile(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("NewScalerFromFile: %v", err)
+	}
+
+	param := quietParameter(C_SVC)
+	model := mustTrain(t, mustProblem(t, blobsText(1, 20, 2, 2), param), param)
+	if err := model.Dump(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Model.Dump: %v", err)
+	}
+	if err := NewScaler().Dump(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Scaler.Dump: %v", err)
+	}
+}
+
+func isParseError(err error, line, column int) bool {
+	var pe *ParseError
+	return errors.Is(err, ErrParse) && errors.As(err, &pe) && pe.Line == line && pe.Column == column
+}
+
+/**
+ * A malformed problem set is a ParseError at the line and column of the offending token, and the name of
+ * the file is set when it is read from a file
+ */
+func TestProblemParseErrors(t *testing.T) {
+	tests := []struct {
+		text         string
+		line, column int
+	}{
+		{"a 1:1\n", 1, 1},
+		{"1 1:0.5\n\n-1  2:x\n", 3, 7},
+		{"1 1:1 foo\n", 1, 7},
+		{"1 1:1\n# comment\n1 x:1\n", 3, 3},
+		{"1 -1:1\n", 1, 3},
+		{"1 0 1:1\n", 1, 3}, // weight
+	}
+	for _, test := range tests {
+		_, err := NewProblemFrom(strings.NewReader(test.text), nil)
+		if !isParseError(err, test.line, test.column) {
+			t.Errorf("%q: %v, want a parse error at %d:%d", test.text, err, test.line, test.column)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "train")
+	if err := os.WriteFile(file, []byte("1 1:1\n1 1:y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewProblem(file, nil)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.File != file || !strings.HasPrefix(err.Error(), file+":2:5:") {
+		t.Errorf("NewProblem: %v", err)
+	}
+}
+
+/**
+ * A malformed model is a ParseError at the offending line
+ */
+func TestModelParseErrors(t *testing.T) {
+	param := quietParameter(C_SVC)
+	model := mustTrain(t, mustProblem(t, blobsText(1, 30, 2, 2), param), param)
+	var buf bytes.Buffer
+	if _, err := model.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	tests := []struct {
+		text         string
+		line, column int
+	}{
+		{strings.Replace(text, "svm_type c_svc", "svm_type foo", 1), 1, 10},
+		{strings.Replace(text, "kernel_type rbf", "kernel_type foo", 1), 2, 13},
+		{strings.Replace(text, "rho ", "rho 1 ", 1), 6, 0},
+		{strings.Replace(text, "\nSV\n", "\nfoo 1\nSV\n", 1), 9, 1},
+		{strings.Join(lines[:len(lines)-1], "\n"), len(lines) - 1, 0}, // missing SV
+		{strings.Join(lines[:5], "\n"), 5, 0},                         // missing header
+	}
+	for _, test := range tests {
+		_, err := ReadModelFrom(strings.NewReader(test.text))
+		if !isParseError(err, test.line, test.column) {
+			t.Errorf("%v, want a parse error at %d:%d of\n%s", err, test.line, test.column, test.text)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "model")
+	if err := os.WriteFile(file, []byte(tests[0].text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var pe *ParseError
+	if _, err := NewModelFromFile(file); !errors.As(err, &pe) || pe.File != file {
+		t.Errorf("NewModelFromFile: %v", err)
+	}
+}
+
+/**
+ * The errors of subproblems are wrapped once with ErrTrainingFailed, and stopped trainings are not
+ */
+func TestTrainingFailed(t *testing.T) {
+	cause := errors.New("cause")
+	err := trainingFailed(cause)
+	if !errors.Is(err, ErrTrainingFailed) || !errors.Is(err, cause) {
+		t.Errorf("trainingFailed(cause): %v", err)
+	}
+	if again := trainingFailed(err); again != err {
+		t.Errorf("trainingFailed wrapped again: %v", again)
+	}
+	stopped := &TrainingStoppedError{Err: context.Canceled}
+	if err := trainingFailed(stopped); err != stopped || errors.Is(err, ErrTrainingFailed) {
+		t.Errorf("trainingFailed(stopped): %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Parameter selection by cross-validation over a grid, like LIBSVM's grid.py
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+)
+
+/**
+ * Values from Begin to End (inclusive) in steps of Step, which may be negative
+ */
+type GridRange struct {
+	Begin, End, Step float64
+}
+
+func (r *GridRange) values() []float64 {
+	if r == nil {
+		return nil
+	}
+	var seq []float64
+	for v := r.Begin; ; v += r.Step {
+		if (r.Step > 0 && v > r.End) || (r.Step < 0 && v < r.End) {
+			break
+		}
+		seq = append(seq, v)
+		if r.Step == 0 {
+			break
+		}
+	}
+	return seq
+}
+
+/**
+ * Describes the grid to search.  A nil range is not searched, and the value in the parameter is used instead.
+ */
+type GridOptions struct {
+	NrFold    int        // number of cross-validation folds
+	Log2C     *GridRange // log2 of C
+	Log2Gamma *GridRange // log2 of gamma
+	Log2P     *GridRange // log2 of the epsilon in the loss function of epsilon-SVR
+	Nu        *GridRange // nu of nu-SVC, one-class SVM and nu-SVR
+	Degree    *GridRange // degree of the polynomial kernel
+	Workers   int        // number of grid points cross-validated concurrently (0 uses all logical CPUs)
+
+	// Called for each grid point, in the order the points finish, with the best point finished so far
+	Progress func(point, best GridPoint)
+}
+
+/**
+ * Returns the grid.py defaults: 5 folds, log2(C) from -5 to 15 in steps of 2, and log2(gamma) from 3 to -15
+ * in steps of -2 for the kernels that use gamma
+ */
+func NewGridOptions(param *Parameter) *GridOptions {
+	options := &GridOptions{NrFold: 5, Log2C: &GridRange{-5, 15, 2}}
+	if param.KernelType == POLY || param.KernelType == RBF || param.KernelType == SIGMOID {
+		options.Log2Gamma = &GridRange{3, -15, -2}
+	}
+	return options
+}
+
+/**
+ * A grid point and its cross-validation rate: the accuracy in percent for classification and one-class SVM,
+ * and the mean squared error for regression.  The rate of a point whose parameters are invalid (e.g. an
+ * infeasible nu) is NaN, and Err holds the reason.
+ */
+type GridPoint struct {
+	Log2C, Log2Gamma, Log2P float64
+	Nu                      float64
+	Degree                  int
+	Rate                    float64
+	Err                     error // the parameters of the point fail Parameter.Validate (nil if it was cross-validated)
+}
+
+type GridResult struct {
+	Best   GridPoint
+	Points []GridPoint // every grid point, in the order they were scheduled
+	Param  *Parameter  // a copy of the parameter with the values of the best grid point
+}
+
+/**
+ * Sets the values of the searched ranges of the grid point in param
+ */
+func (options *GridOptions) apply(point GridPoint, param *Parameter) {
+	if options.Log2C != nil {
+		param.C = math.Pow(2, point.Log2C)
+	}
+	if options.Log2Gamma != nil {
+		param.Gamma = math.Pow(2, point.Log2Gamma)
+	}
+	if options.Log2P != nil {
+		param.P = math.Pow(2, point.Log2P)
+	}
+	if options.Nu != nil {
+		param.Nu = point.Nu
+	}
+	if options.Degree != nil {
+		param.Degree = point.Degree
+	}
+}
+
+/**
+ * Orders the sequence from the middle out, so that the early grid points cover the whole range (grid.py's permute_sequence)
+ */
+func permuteSequence(seq []float64) []float64 {
+	n := len(seq)
+	if n <= 1 {
+		return seq
+	}
+	mid := n / 2
+	left := permuteSequence(seq[:mid])
+	right := permuteSequence(seq[mid+1:])
+
+	ret := []float64{seq[mid]}
+	for k := 0; k < len(left) || k < len(right); k++ {
+		if k < len(left) {
+			ret = append(ret, left[k])
+		}
+		if k < len(right) {
+			ret = append(ret, right[k])
+		}
+	}
+	return ret
+}
+
+/**
+ * Schedules the grid points in the same order as grid.py, which refines C and gamma alternately.  The
+ * other ranges are swept in outer loops.
+ */
+func (options *GridOptions) points(param *Parameter) []GridPoint {
+	orDefault := func(seq []float64, value float64) []float64 {
+		if len(seq) == 0 {
+			return []float64{value}
+		}
+		return seq
+	}
+
+	cSeq := permuteSequence(orDefault(options.Log2C.values(), math.Log2(param.C)))
+	gSeq := permuteSequence(orDefault(options.Log2Gamma.values(), math.Log2(param.Gamma)))
+	pSeq := orDefault(options.Log2P.values(), math.Log2(param.P))
+	nuSeq := orDefault(options.Nu.values(), param.Nu)
+	degreeSeq := orDefault(options.Degree.values(), float64(param.Degree))
+
+	type cg struct{ c, g float64 }
+	var jobs []cg
+	nrC, nrG := float64(len(cSeq)), float64(len(gSeq))
+	for i, j := 0, 0; i < len(cSeq) || j < len(gSeq); {
+		if float64(i)/nrC < float64(j)/nrG { // increase C resolution
+			for k := 0; k < j; k++ {
+				jobs = append(jobs, cg{cSeq[i], gSeq[k]})
+			}
+			i++
+		} else { // increase gamma resolution
+			for k := 0; k < i; k++ {
+				jobs = append(jobs, cg{cSeq[k], gSeq[j]})
+			}
+			j++
+		}
+	}
+
+	var points []GridPoint
+	for _, degree := range degreeSeq {
+		for _, nu := range nuSeq {
+			for _, p := range pSeq {
+				for _, job := range jobs {
+					points = append(points, GridPoint{Log2C: job.c, Log2Gamma: job.g, Log2P: p, Nu: nu, Degree: int(degree)})
+				}
+			}
+		}
+	}
+	return points
+}
+
+/**
+ * Returns the cross-validation rate of the predicted targets
+ */
+func crossValidationRate(prob *Problem, param *Parameter, target []float64) float64 {
+	if param.SvmType == EPSILON_SVR || param.SvmType == NU_SVR {
+		var sumSq float64 = 0
+		for i := 0; i < prob.l; i++ {
+			sumSq += (target[i] - prob.y[i]) * (target[i] - prob.y[i])
+		}
+		return sumSq / float64(prob.l)
+	}
+
+	var correct int = 0
+	for i := 0; i < prob.l; i++ {
+		if target[i] == prob.y[i] {
+			correct++
+		}
+	}
+	return 100 * float64(correct) / float64(prob.l)
+}
+
+/**
+ * Returns whether the grid point is better than the best one so far, as in grid.py: its rate is better (higher
+ * accuracy, or lower mean squared error for regression), or the same with the same gamma and a smaller C.
+ * A failed point, whose rate is NaN, is worse than any other.
+ */
+func betterGridPoint(point, best GridPoint, regression bool) bool {
+	if math.IsNaN(point.Rate) || math.IsNaN(best.Rate) {
+		return !math.IsNaN(point.Rate)
+	}
+	if point.Rate == best.Rate {
+		return point.Log2Gamma == best.Log2Gamma && point.Log2C < best.Log2C
+	}
+	return (point.Rate < best.Rate) == regression
+}
+
+/**
+ * Returns the best of the grid points, compared in the order they were scheduled so that the ties between
+ * points of different gammas are won by the earlier one.  The rate is NaN if every point failed.
+ */
+func bestGridPoint(points []GridPoint, regression bool) GridPoint {
+	best := GridPoint{Rate: math.NaN()}
+	for _, point := range points {
+		if betterGridPoint(point, best, regression) {
+			best = point
+		}
+	}
+	return best
+}
+
+/**
+ * Searches the grid for the parameter values with the best cross-validation rate (see betterGridPoint).  The
+ * points are compared in the order they are scheduled, so the best point does not depend on the order the
+ * workers finish them.  A point whose parameters fail Parameter.Validate is skipped (see GridPoint.Err), and
+ * the search fails only if every point does.  Each concurrently cross-validated grid point trains on a single
+ * CPU, and has its own kernel cache of param.CacheSize.
+ */
+func GridSearch(prob *Problem, param *Parameter, options *GridOptions) (*GridResult, error) {
+	if options.NrFold < 2 {
+		return nil, invalidParameter("n-fold cross validation: n must >= 2")
+	}
+	if err := param.Validate(prob); err != nil {
+		return nil, err
+	}
+
+	points := options.points(param)
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(points) {
+		workers = len(points)
+	}
+
+	regression := param.SvmType == EPSILON_SVR || param.SvmType == NU_SVR
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		k   int
+		err error
+	}
+	jobs := make(chan int)
+	results := make(chan result)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for k := range jobs {
+				if ctx.Err() != nil { // another grid point has failed
+					results <- result{k, ctx.Err()}
+					continue
+				}
+
+				subParam := *param
+				subParam.Observer = NopObserver{}
+				if workers > 1 {
+					subParam.NumCPU = 1
+				}
+				options.apply(points[k], &subParam)
+
+				err := subParam.Validate(prob)
+				if err == nil {
+					var target []float64
+					if target, err = crossValidation(ctx, prob, &subParam, options.NrFold, nil, nil); err == nil {
+						points[k].Rate = crossValidationRate(prob, &subParam, target)
+					}
+				}
+				results <- result{k, err}
+			}
+		}()
+	}
+
+	go func() {
+		for k := range points {
+			jobs <- k
+		}
+		close(jobs)
+	}()
+
+	best := GridPoint{Rate: math.NaN()} // best point finished so far
+	var firstErr error
+	for done := 0; done < len(points); done++ {
+		res := <-results
+		if firstErr != nil {
+			continue // drain the remaining grid points
+		}
+		if res.err != nil && !errors.Is(res.err, ErrInvalidParameter) {
+			firstErr = res.err
+			cancel() // stop the grid points being trained
+			continue
+		}
+
+		if res.err != nil { // skip the point
+			points[res.k].Rate = math.NaN()
+			points[res.k].Err = res.err
+		}
+		point := points[res.k]
+		if betterGridPoint(point, best, regression) {
+			best = point
+		}
+		if options.Progress != nil {
+			options.Progress(point, best)
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	best = bestGridPoint(points, regression)
+	if math.IsNaN(best.Rate) { // every point failed
+		return nil, points[0].Err
+	}
+
+	bestParam := *param
+	options.apply(best, &bestParam)
+
+	return &GridResult{Best: best, Points: points, Param: &bestParam}, nil
+}
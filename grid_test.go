@@ -0,0 +1,240 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the grid search
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/**
+ * Returns a two-class problem set with three times as many instances of class 1 as of class 2, for which
+ * nu-SVC is infeasible when nu > 0.5
+ */
+func imbalancedText() string {
+	var lines []string
+	for k, line := range strings.Split(strings.TrimSpace(blobsText(2, 120, 3, 2)), "\n") {
+		if strings.HasPrefix(line, "1 ") || k%6 == 1 {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestGridSearchSkipsInvalidPoints(t *testing.T) {
+	param := quietParameter(NU_SVC)
+	prob := mustProblem(t, imbalancedText(), param)
+
+	options := NewGridOptions(param)
+	options.Log2C = nil
+	options.Log2Gamma = &GridRange{-3, -1, 2}
+	options.Nu = &GridRange{0.25, 0.75, 0.25}
+	options.Workers = 3
+
+	result, err := GridSearch(prob, param, options)
+	if err != nil {
+		t.Fatalf("GridSearch: %v", err)
+	}
+	if len(result.Points) != 6 {
+		t.Fatalf("%d grid points, want 6", len(result.Points))
+	}
+	for _, point := range result.Points {
+		infeasible := point.Nu > 0.5
+		if infeasible != math.IsNaN(point.Rate) || infeasible != errors.Is(point.Err, ErrInvalidParameter) {
+			t.Errorf("nu %v: rate %v and error %v", point.Nu, point.Rate, point.Err)
+		}
+	}
+	if result.Best.Nu > 0.5 || math.IsNaN(result.Best.Rate) || result.Param.Nu != result.Best.Nu {
+		t.Errorf("best point %+v with nu %v", result.Best, result.Param.Nu)
+	}
+
+	options.Nu = &GridRange{0.75, 1, 0.25}
+	if _, err := GridSearch(prob, param, options); !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("GridSearch of infeasible points only: %v, want an invalid parameter", err)
+	}
+}
+
+/**
+ * Ties between points of different gammas are won by the point scheduled first, whichever finished first
+ */
+func TestBestGridPoint(t *testing.T) {
+	points := []GridPoint{
+		{Log2C: 1, Log2Gamma: -1, Rate: 90},
+		{Log2C: 1, Log2Gamma: -3, Rate: 90},
+		{Log2C: -1, Log2Gamma: -3, Rate: 90},
+		{Log2C: 3, Log2Gamma: -1, Rate: 90},
+		{Log2C: -1, Log2Gamma: -1, Rate: math.NaN()},
+	}
+	if best := bestGridPoint(points, false); !reflect.DeepEqual(best, points[0]) {
+		t.Errorf("best point %+v, want %+v", best, points[0])
+	}
+	if best := bestGridPoint(points[1:], false); !reflect.DeepEqual(best, points[2]) {
+		t.Errorf("best point %+v, want the smaller C of the same gamma %+v", best, points[2])
+	}
+
+	points[3].Rate = 80
+	if best := bestGridPoint(points, true); !reflect.DeepEqual(best, points[3]) {
+		t.Errorf("best regression point %+v, want the lowest error %+v", best, points[3])
+	}
+	if best := bestGridPoint(points[4:], false); !math.IsNaN(best.Rate) {
+		t.Errorf("best point %+v of failed points, want a NaN rate", best)
+	}
+}
+
+/**
+ * The best point is the one grid.py finds by comparing the points in the order they are scheduled, whatever
+ * the number of workers
+ */
+func TestGridSearchDeterministic(t *testing.T) {
+	param := quietParameter(C_SVC)
+	var b strings.Builder // two classes far apart, which every grid point separates whatever the folds
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&b, "%d 1:%d 2:%.2f\n", 2*(i%2)-1, 4*(i%2)-2, float64(i%7)/7)
+	}
+	prob := mustProblem(t, b.String(), param)
+
+	options := NewGridOptions(param)
+	options.Log2C = &GridRange{-1, 9, 2}
+	options.Log2Gamma = &GridRange{1, -7, -2}
+	options.NrFold = 3
+
+	options.Workers = 1
+	want, err := GridSearch(prob, param, options)
+	if err != nil {
+		t.Fatalf("GridSearch: %v", err)
+	}
+
+	for _, point := range want.Points {
+		if point.Rate != 100 {
+			t.Fatalf("grid point %+v does not separate the classes", point)
+		}
+	}
+	if first := want.Points[0]; want.Best.Log2Gamma != first.Log2Gamma || want.Best.Log2C != -1 {
+		t.Errorf("best point %+v, want the smallest C with the gamma of the first point %+v", want.Best, first)
+	}
+
+	for run := 0; run < 5; run++ {
+		options.Workers = 8
+		got, err := GridSearch(prob, param, options)
+		if err != nil {
+			t.Fatalf("GridSearch: %v", err)
+		}
+		if !reflect.DeepEqual(got.Best, want.Best) || !reflect.DeepEqual(got.Points, want.Points) {
+			t.Fatalf("best point %+v with 8 workers, want %+v", got.Best, want.Best)
+		}
+	}
+}
+
+/**
+ * A range holds the values from Begin to End in steps of Step, in the direction of Step
+ */
+func TestGridRange(t *testing.T) {
+	tests := []struct {
+		r    *GridRange
+		want []float64
+	}{
+		{&GridRange{-5, 15, 2}, []float64{-5, -3, -1, 1, 3, 5, 7, 9, 11, 13, 15}},
+		{&GridRange{3, -3, -2}, []float64{3, 1, -1, -3}},
+		{&GridRange{0.25, 1, 0.5}, []float64{0.25, 0.75}},
+		{&GridRange{2, 2, 0}, []float64{2}},
+		{&GridRange{2, 1, 1}, nil},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		if got := test.r.values(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: %v, want %v", test.r, got, test.want)
+		}
+	}
+}
+
+/**
+ * The grid points are scheduled in the order of grid.py
+ */
+func TestGridPoints(t *testing.T) {
+	seq := []float64{-5, -3, -1, 1, 3, 5, 7, 9, 11, 13, 15}
+	if got, want := permuteSequence(seq), []float64{5, -1, 11, -3, 9, 3, 15, -5, 7, 1, 13}; !reflect.DeepEqual(got, want) {
+		t.Errorf("permuted sequence %v, want %v", got, want)
+	}
+
+	param := quietParameter(NU_SVC)
+	options := &GridOptions{NrFold: 5, Log2C: &GridRange{1, 3, 2}, Log2Gamma: &GridRange{-1, -3, -2}, Nu: &GridRange{0.1, 0.2, 0.1}}
+	var got [][3]float64
+	for _, point := range options.points(param) {
+		got = append(got, [3]float64{point.Nu, point.Log2C, point.Log2Gamma})
+	}
+	want := [][3]float64{ // the middle C and gamma first, then (C,gamma) pairs in grid.py order
+		{0.1, 3, -3}, {0.1, 3, -1}, {0.1, 1, -3}, {0.1, 1, -1},
+		{0.2, 3, -3}, {0.2, 3, -1}, {0.2, 1, -3}, {0.2, 1, -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("grid points %v, want %v", got, want)
+	}
+
+	options = NewGridOptions(param)
+	if points := options.points(param); len(points) != 11*10 {
+		t.Errorf("%d grid points by default, want %d", len(points), 11*10)
+	}
+	param.KernelType = LINEAR
+	if options = NewGridOptions(param); options.Log2Gamma != nil || len(options.points(param)) != 11 {
+		t.Errorf("%d grid points for the linear kernel, want 11", len(options.points(param)))
+	}
+}
+
+/**
+ * The best regression point has the lowest mean squared error, every point is reported to Progress, and
+ * the parameter of the result holds the values of the best point
+ */
+func TestGridSearchRegression(t *testing.T) {
+	param := quietParameter(EPSILON_SVR)
+	prob := mustProblem(t, regressionText(1, 60, 2), param)
+
+	options := &GridOptions{NrFold: 3, Log2C: &GridRange{-1, 3, 2}, Log2P: &GridRange{-6, -2, 2}, Workers: 2}
+	var progress int
+	options.Progress = func(point, best GridPoint) {
+		progress++
+		if best.Rate > point.Rate {
+			t.Errorf("best point %+v is worse than %+v", best, point)
+		}
+	}
+	result, err := GridSearch(prob, param, options)
+	if err != nil {
+		t.Fatalf("GridSearch: %v", err)
+	}
+	if len(result.Points) != 9 || progress != 9 {
+		t.Fatalf("%d grid points and %d progress calls, want 9", len(result.Points), progress)
+	}
+	for _, point := range result.Points {
+		if point.Rate < result.Best.Rate || point.Log2Gamma != math.Log2(param.Gamma) {
+			t.Errorf("grid point %+v, best %+v", point, result.Best)
+		}
+	}
+	if result.Param.C != math.Pow(2, result.Best.Log2C) || result.Param.P != math.Pow(2, result.Best.Log2P) ||
+		result.Param.Gamma != param.Gamma || param.C != 1 {
+		t.Errorf("parameter C %v p %v gamma %v of the best point %+v", result.Param.C, result.Param.P, result.Param.Gamma, result.Best)
+	}
+
+	options.NrFold = 1
+	if _, err := GridSearch(prob, param, options); !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("GridSearch with 1 fold: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Problem sets and helpers shared by the tests
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+/**
+ * Returns n instances of nrClass overlapping Gaussian classes (labels 1 ... nrClass) in dim dimensions,
+ * in the LIBSVM data format
+ */
+func blobsText(seed int64, n, dim, nrClass int) string {
+	r := rand.New(rand.NewSource(seed))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		label := i%nrClass + 1
+		fmt.Fprintf(&b, "%d", label)
+		for k := 1; k <= dim; k++ {
+			center := 0.0
+			if (k+label)%nrClass == 0 {
+				center = 1
+			}
+			fmt.Fprintf(&b, " %d:%.4f", k, center+0.6*r.NormFloat64())
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+/**
+ * Returns n instances of a noisy non-linear function of dim dimensions in the LIBSVM data format
+ */
+func regressionText(seed int64, n, dim int) string {
+	r := rand.New(rand.NewSource(seed))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		x := make([]float64, dim)
+		var y float64 = 0.1 * r.NormFloat64()
+		for k := range x {
+			x[k] = 2*r.Float64() - 1
+			y += float64(k+1) * x[k] / float64(dim)
+		}
+		y += math.Sin(3 * x[0])
+		fmt.Fprintf(&b, "%.4f", y)
+		for k, value := range x {
+			fmt.Fprintf(&b, " %d:%.4f", k+1, value)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+/**
+ * Returns the text of the problem set for the svm type: 3 classes for classification, and a regression
+ * problem otherwise
+ */
+func problemText(svmType int) string {
+	switch svmType {
+	case EPSILON_SVR, NU_SVR:
+		return regressionText(1, 120, 4)
+	case ONE_CLASS:
+		return blobsText(1, 120, 4, 2)
+	}
+	return blobsText(1, 150, 4, 3)
+}
+
+/**
+ * Returns a parameter of the svm type that does not print the training progress
+ */
+func quietParameter(svmType int) *Parameter {
+	param := NewParameter()
+	param.SvmType = svmType
+	param.QuietMode = true
+	return param
+}
+
+func mustProblem(t testing.TB, text string, param *Parameter) *Problem {
+	t.Helper()
+	prob, err := NewProblemFrom(strings.NewReader(text), param)
+	if err != nil {
+		t.Fatalf("reading the problem set: %v", err)
+	}
+	return prob
+}
+
+func mustTrain(t testing.TB, prob *Problem, param *Parameter) *Model {
+	t.Helper()
+	model := NewModel(param)
+	if err := model.Train(prob); err != nil {
+		t.Fatalf("training: %v", err)
+	}
+	return model
+}
+
+/**
+ * Returns whether a and b are equal within tol, relative to their magnitude when it is above 1
+ */
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func nearSlices(a, b []float64, tol float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !near(a[i], b[i], tol) {
+			return false
+		}
+	}
+	return true
+}
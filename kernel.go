@@ -28,6 +28,7 @@ Interface for all kernel functions
 */
 type kernelFunction interface {
 	compute(i, j int) float64
+	swapIndex(i, j int) // swaps SVs i and j, used by the solver when shrinking
 }
 
 /**
@@ -65,6 +66,10 @@ func (k linear) compute(i, j int) float64 {
 	return dot(k.xSpace[idx_i:], k.xSpace[idx_j:])
 }
 
+func (k linear) swapIndex(i, j int) {
+	k.x[i], k.x[j] = k.x[j], k.x[i]
+}
+
 func newLinear(x []int, xSpace []snode) linear {
 	return linear{x: x, xSpace: xSpace}
 }
@@ -84,6 +89,11 @@ func (k rbf) compute(i, j int) float64 {
 	return math.Exp(-k.gamma * q)
 }
 
+func (k rbf) swapIndex(i, j int) {
+	k.x[i], k.x[j] = k.x[j], k.x[i]
+	k.x_square[i], k.x_square[j] = k.x_square[j], k.x_square[i]
+}
+
 func newRBF(x []int, xSpace []snode, l int, gamma float64) rbf {
 	x_square := make([]float64, l)
 
@@ -111,6 +121,10 @@ func (k poly) compute(i, j int) float64 {
 	return math.Pow(q, float64(k.degree))
 }
 
+func (k poly) swapIndex(i, j int) {
+	k.x[i], k.x[j] = k.x[j], k.x[i]
+}
+
 func newPoly(x []int, xSpace []snode, gamma, coef0 float64, degree int) poly {
 	return poly{x: x, xSpace: xSpace, gamma: gamma, coef0: coef0, degree: degree}
 }
@@ -130,21 +144,54 @@ func (k sigmoid) compute(i, j int) float64 {
 	return math.Tanh(q)
 }
 
+func (k sigmoid) swapIndex(i, j int) {
+	k.x[i], k.x[j] = k.x[j], k.x[i]
+}
+
 func newSigmoid(x []int, xSpace []snode, gamma, coef0 float64) sigmoid {
 	return sigmoid{x: x, xSpace: xSpace, gamma: gamma, coef0: coef0}
 }
 
+/************** PRECOMPUTED KERNEL ***************/
+type precomputed struct {
+	x      []int
+	xSpace []snode
+}
+
+/**
+ * Each SV is of the form 0:serial 1:K(x,x_1) ... l:K(x,x_l), so the kernel value
+ * is found at the offset given by the serial number of the other SV
+ */
+func (k precomputed) compute(i, j int) float64 {
+	var idx_i int = k.x[i]
+	var idx_j int = k.x[j]
+	return k.xSpace[idx_i+int(k.xSpace[idx_j].value)].value
+}
+
+func (k precomputed) swapIndex(i, j int) {
+	k.x[i], k.x[j] = k.x[j], k.x[i]
+}
+
+func newPrecomputed(x []int, xSpace []snode) precomputed {
+	return precomputed{x: x, xSpace: xSpace}
+}
+
 /************** Factory ***************/
 func newKernel(prob *Problem, param *Parameter) (kernelFunction, error) {
+	x := make([]int, prob.l) // the kernel gets its own copy since swapIndex() reorders it
+	copy(x, prob.x)
+
 	switch param.KernelType {
 	case LINEAR:
-		return newLinear(prob.x, prob.xSpace), nil
+		return newLinear(x, prob.xSpace), nil
 	case POLY:
-		return newPoly(prob.x, prob.xSpace, param.Gamma, param.Coef0, param.Degree), nil
+		return newPoly(x, prob.xSpace, param.Gamma, param.Coef0, param.Degree), nil
 	case RBF:
-		return newRBF(prob.x, prob.xSpace, prob.l, param.Gamma), nil
+		return newRBF(x, prob.xSpace, prob.l, param.Gamma), nil
 	case SIGMOID:
-		return newSigmoid(prob.x, prob.xSpace, param.Gamma, param.Coef0), nil
+		return newSigmoid(x, prob.xSpace, param.Gamma, param.Coef0), nil
+	case PRECOMPUTED:
+		return newPrecomputed(x, prob.xSpace), nil
 	}
 	return nil, errors.New("unsupported kernel")
 }
@@ -0,0 +1,221 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Caches kernel values by instance, for all the subproblems trained from one problem set.
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"container/list"
+	"math"
+	"sync"
+)
+
+/**
+ * Kernel rows of the instances of a problem set, shared by all the Q matrices built while training from it:
+ * the one-vs-one subproblems, the cross-validation folds and the probability calibration.  Unlike the cache
+ * of a Q matrix, a row is indexed by the instance, whatever subproblem it appears in, and only holds the
+ * kernel values that were computed (the others are NaN).  The rows are evicted in LRU order.  The cache
+ * may be used by concurrent subproblems.
+ */
+type kernelCache[T cacheValue] struct {
+	mu       sync.Mutex
+	instance map[int]int // offset of an instance in xSpace -> its index in the problem set
+	l        int         // number of instances, and length of each row
+	rows     map[int]*list.Element
+	lru      *list.List // kernelRow values, the last used at the back
+	maxRows  int
+}
+
+type kernelRow[T cacheValue] struct {
+	i    int
+	data []T
+	gen  int // incremented when the row is evicted, so that values computed for it are not stored in its successor
+}
+
+/**
+ * The kernel cache of a Parameter, whatever the precision of its values
+ */
+type sharedKernels interface {
+	indices(prob *Problem) []int
+}
+
+/**
+ * Returns the kernel cache for the problem set prob, holding at most cacheSize MB
+ */
+func newKernelCache[T cacheValue](prob *Problem, cacheSize int) *kernelCache[T] {
+	kc := &kernelCache[T]{instance: make(map[int]int, prob.l), l: prob.l, rows: make(map[int]*list.Element), lru: list.New()}
+	for i := 0; i < prob.l; i++ {
+		if _, dup := kc.instance[prob.x[i]]; !dup {
+			kc.instance[prob.x[i]] = i
+		}
+	}
+	kc.maxRows = mini(computeCacheSize(prob.l, sizeOfCacheValue[T](), cacheSize), prob.l)
+
+	return kc
+}
+
+/**
+ * Returns the index in the cache of each instance of prob (a subproblem of the problem set of the cache),
+ * or -1 for an instance that is not in it.  These indices are the keys of the kernel values of prob.
+ */
+func (kc *kernelCache[T]) indices(prob *Problem) []int {
+	if kc == nil {
+		return nil
+	}
+	ids := make([]int, prob.l)
+	for i := 0; i < prob.l; i++ {
+		if id, ok := kc.instance[prob.x[i]]; ok {
+			ids[i] = id
+		} else {
+			ids[i] = -1
+		}
+	}
+	return ids
+}
+
+/**
+ * Copies the cached K(i,ids[j]) of each j in [begin,end) into data, and returns the j whose values are
+ * missing, with the generation of row i to store them with.  Row i becomes the last used.
+ */
+func (kc *kernelCache[T]) lookup(i int, ids []int, begin, end int, data []T) (missing []int, row *kernelRow[T], gen int) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if e, ok := kc.rows[i]; ok {
+		kc.lru.MoveToBack(e)
+		row = e.Value.(*kernelRow[T])
+	} else {
+		if kc.lru.Len() < kc.maxRows {
+			row = &kernelRow[T]{data: make([]T, kc.l)}
+		} else {
+			e := kc.lru.Front() // evict the least recently used row
+			row = kc.lru.Remove(e).(*kernelRow[T])
+			delete(kc.rows, row.i)
+			row.gen++
+		}
+		row.i = i
+		nan := T(math.NaN())
+		for k := range row.data {
+			row.data[k] = nan
+		}
+		kc.rows[i] = kc.lru.PushBack(row)
+	}
+
+	for j := begin; j < end; j++ {
+		if id := ids[j]; id >= 0 && !math.IsNaN(float64(row.data[id])) {
+			data[j] = row.data[id]
+		} else {
+			missing = append(missing, j)
+		}
+	}
+	return missing, row, row.gen
+}
+
+/**
+ * Stores the computed K(i,ids[j]) of each missing j from data, unless row has been evicted since the lookup
+ */
+func (kc *kernelCache[T]) store(row *kernelRow[T], gen int, ids []int, missing []int, data []T) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if row.gen != gen {
+		return
+	}
+	for _, j := range missing {
+		if id := ids[j]; id >= 0 {
+			row.data[id] = data[j]
+		}
+	}
+}
+
+/**
+ * Fills data[j] with the kernel value of the variables i and j of a Q matrix, for j in [begin,end), taking
+ * them from the kernel cache kc if possible.  ids holds the index in kc of each variable (see indices).
+ * Returns the number of kernel evaluations, and of kernel values found in kc.
+ */
+func computeKernelRow[T cacheValue](kc *kernelCache[T], ids []int, kernel kernelFunction, pool *workerPool, i, begin, end int, data []T) (int, int) {
+	if kc == nil || ids[i] < 0 {
+		run := func(tid, start, end int) {
+			for j := start; j < end; j++ {
+				data[j] = T(kernel.compute(i, j))
+			}
+		}
+		pool.runRange(begin, end, run)
+		return end - begin, 0
+	}
+
+	missing, row, gen := kc.lookup(ids[i], ids, begin, end, data)
+	if len(missing) > 0 {
+		run := func(tid, start, end int) {
+			for k := start; k < end; k++ {
+				j := missing[k]
+				data[j] = T(kernel.compute(i, j))
+			}
+		}
+		pool.runRange(0, len(missing), run)
+		kc.store(row, gen, ids, missing, data)
+	}
+	return len(missing), end - begin - len(missing)
+}
+
+/**
+ * Returns the kernel cache of param if its values are of type T, or nil
+ */
+func kernelCacheOf[T cacheValue](param *Parameter) *kernelCache[T] {
+	kc, _ := param.kernels.(*kernelCache[T])
+	return kc
+}
+
+/**
+ * Returns whether training prob with param solves several subproblems with instances in common: the
+ * one-vs-one subproblems of more than two classes, or the folds of the probability calibration
+ */
+func sharesKernels(prob *Problem, param *Parameter) bool {
+	if param.Probability {
+		return true
+	}
+	if param.SvmType == C_SVC || param.SvmType == NU_SVC {
+		nrClass, _, _, _, _ := groupClasses(prob)
+		return nrClass > 2
+	}
+	return false
+}
+
+/**
+ * Returns a copy of param whose Q matrices share a new kernel cache for the problem set prob, or param itself
+ * if it already has a kernel cache or does not need one.  The kernel cache takes half of param.CacheSize,
+ * and the caches of the Q matrices the other half.
+ */
+func withKernelCache(prob *Problem, param *Parameter) *Parameter {
+	if param.kernels != nil {
+		return param
+	}
+	if param.Solver != SMO || param.KernelType == PRECOMPUTED || prob.l == 0 {
+		return param // the kernel values are cheap enough without it (precomputed kernel) or are not used (linear solvers)
+	}
+	half := maxi(1, param.CacheSize/2)
+	var kc sharedKernels
+	if param.CachePrecision == DOUBLE_PRECISION {
+		kc = newKernelCache[float64](prob, maxi(1, param.CacheSize-half))
+	} else {
+		kc = newKernelCache[float32](prob, maxi(1, param.CacheSize-half))
+	}
+	shared := *param
+	shared.CacheSize = half
+	shared.kernels = kc
+	return &shared
+}
@@ -0,0 +1,152 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the kernel cache shared by the subproblems of a training
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/**
+ * The kernel of the instances of a subproblem, K(a,b) = 10*a+b of their indices in the cache, which
+ * counts its evaluations
+ */
+type indexKernel struct {
+	ids   []int
+	calls int
+}
+
+func (k *indexKernel) compute(i, j int) float64 {
+	k.calls++
+	return float64(10*k.ids[i] + k.ids[j])
+}
+
+func (k *indexKernel) swapIndex(i, j int) {}
+
+/**
+ * Returns the problem set of the instances of prob at the indices
+ */
+func subproblemOf(prob *Problem, indices ...int) *Problem {
+	sub := &Problem{l: len(indices), xSpace: prob.xSpace, maxIdx: prob.maxIdx}
+	for _, i := range indices {
+		sub.x = append(sub.x, prob.x[i])
+		sub.y = append(sub.y, prob.y[i])
+	}
+	return sub
+}
+
+/**
+ * The kernel values computed for one subproblem are found by the others, by instance, and an instance
+ * foreign to the cache is computed every time
+ */
+func TestKernelCache(t *testing.T) {
+	prob := mustProblem(t, "1 1:1\n-1 1:2\n1 1:3\n-1 1:4\n", nil)
+	kc := newKernelCache[float64](prob, 1)
+	foreign := &Problem{l: 1, x: []int{prob.x[1] - 1}, y: []float64{1}} // at the end of instance 0
+
+	sub := subproblemOf(prob, 2, 0, 3)
+	ids := kc.indices(sub)
+	if !reflect.DeepEqual(ids, []int{2, 0, 3}) || !reflect.DeepEqual(kc.indices(foreign), []int{-1}) {
+		t.Fatalf("indices %v, and %v of a foreign instance", ids, kc.indices(foreign))
+	}
+
+	kernel := &indexKernel{ids: ids}
+	data := make([]float64, 3)
+	for k, want := range [][2]int{{3, 0}, {0, 3}} {
+		n, hits := computeKernelRow(kc, ids, kernel, nil, 0, 0, 3, data)
+		if n != want[0] || hits != want[1] || !nearSlices(data, []float64{22, 20, 23}, 0) {
+			t.Errorf("row %d: %d evaluations, %d hits, %v", k, n, hits, data)
+		}
+	}
+
+	ids2 := kc.indices(subproblemOf(prob, 0, 2))
+	kernel2 := &indexKernel{ids: ids2}
+	if n, hits := computeKernelRow(kc, ids2, kernel2, nil, 1, 0, 2, data); n != 0 || hits != 2 || data[0] != 20 || data[1] != 22 {
+		t.Errorf("row of another subproblem: %d evaluations, %d hits, %v", n, hits, data[:2])
+	}
+
+	ids3 := []int{-1, 0}
+	for k := 0; k < 2; k++ {
+		if n, hits := computeKernelRow(kc, ids3, &indexKernel{ids: ids3}, nil, 0, 0, 2, data); n != 2 || hits != 0 {
+			t.Errorf("row %d of a foreign instance: %d evaluations, %d hits", k, n, hits)
+		}
+	}
+	if kernel.calls != 3 || kernel2.calls != 0 {
+		t.Errorf("%d and %d kernel evaluations, want 3 and 0", kernel.calls, kernel2.calls)
+	}
+}
+
+/**
+ * An evicted row is computed again, and the values computed for it are not stored in the row that took
+ * its place
+ */
+func TestKernelCacheEviction(t *testing.T) {
+	prob := mustProblem(t, "1 1:1\n-1 1:2\n1 1:3\n", nil)
+	kc := newKernelCache[float32](prob, 1)
+	kc.maxRows = 1
+	ids := kc.indices(prob)
+	kernel := &indexKernel{ids: ids}
+	data := make([]float32, 3)
+
+	missing, row, gen := kc.lookup(0, ids, 0, 3, data)
+	computeKernelRow(kc, ids, kernel, nil, 1, 0, 3, data) // evicts row 0
+	kc.store(row, gen, ids, missing, []float32{-1, -1, -1})
+	if n, hits := computeKernelRow(kc, ids, kernel, nil, 1, 0, 3, data); n != 0 || hits != 3 || data[0] != 10 || data[2] != 12 {
+		t.Errorf("row 1: %d evaluations, %d hits, %v", n, hits, data)
+	}
+	if n, hits := computeKernelRow(kc, ids, kernel, nil, 0, 0, 3, data); n != 3 || hits != 0 {
+		t.Errorf("evicted row 0: %d evaluations, %d hits", n, hits)
+	}
+}
+
+/**
+ * The one-vs-one subproblems find kernel values in the shared cache, and solve as a two-class problem
+ * set of their instances does without it, whatever the precision of the cache
+ */
+func TestSharedKernelCache(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(blobsText(1, 160, 4, 4), "\n"), "\n")
+	for _, precision := range []int{SINGLE_PRECISION, DOUBLE_PRECISION} {
+		param := quietParameter(C_SVC)
+		param.CachePrecision = precision
+		model := mustTrain(t, mustProblem(t, strings.Join(lines, "\n"), param), param)
+		if model.Report().SharedCacheHits <= 0 {
+			t.Errorf("precision %d: %d shared cache hits", precision, model.Report().SharedCacheHits)
+		}
+
+		for _, info := range model.Report().Subproblems {
+			var pair []string // the instances of the pair, grouped by class as the subproblem has them
+			for _, label := range info.Labels {
+				for _, line := range lines {
+					if strings.HasPrefix(line, strconv.Itoa(label)+" ") {
+						pair = append(pair, line)
+					}
+				}
+			}
+			want := mustTrain(t, mustProblem(t, strings.Join(pair, "\n"), param), param).Report()
+			wantInfo := want.Subproblems[0]
+			if !near(info.Obj, wantInfo.Obj, 1e-9*math.Abs(wantInfo.Obj)) || !near(info.Rho, wantInfo.Rho, 1e-9) ||
+				info.NSV != wantInfo.NSV || want.SharedCacheHits != 0 {
+				t.Errorf("precision %d, pair %v: %+v, want %+v", precision, info.Labels, info, wantInfo)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Primal weight vectors of linear-kernel models
+** @author: Ed Walker
+ */
+package libSvm
+
+/**
+ * Returns the number of decision functions: one for each pair of classes, or one for one-class SVM and regression
+ */
+func (model Model) nrDecisionValues() int {
+	if model.param.SvmType == C_SVC || model.param.SvmType == NU_SVC {
+		return model.nrClass * (model.nrClass - 1) / 2
+	}
+	return 1
+}
+
+/**
+ * Sums the SVs weighted by their coefficients into the primal weight vector of each decision function.
+ * The weights are stored feature by feature: w[k*nrW+p] is the weight of dimension k in decision function p,
+ * for k from 0 to nrFeature, the largest dimension of the SVs.
+ */
+func (model Model) primalWeights() (w []float64, nrFeature int) {
+	for i := 0; i < model.l; i++ {
+		for idx := model.sV[i]; model.svSpace[idx].index != -1; idx++ {
+			nrFeature = maxi(nrFeature, model.svSpace[idx].index)
+		}
+	}
+
+	nrW := model.nrDecisionValues()
+	w = make([]float64, (nrFeature+1)*nrW)
+
+	// adds coef times SV i to the weights of decision function p
+	addSV := func(p, i int, coef float64) {
+		for idx := model.sV[i]; model.svSpace[idx].index != -1; idx++ {
+			node := model.svSpace[idx]
+			w[node.index*nrW+p] += coef * node.value
+		}
+	}
+
+	switch model.param.SvmType {
+	case ONE_CLASS, EPSILON_SVR, NU_SVR:
+		for i := 0; i < model.l; i++ {
+			addSV(0, i, model.svCoef[0][i])
+		}
+
+	case C_SVC, NU_SVC:
+		var nrClass int = model.nrClass
+
+		start := make([]int, nrClass)
+		start[0] = 0
+		for i := 1; i < nrClass; i++ {
+			start[i] = start[i-1] + model.nSV[i-1]
+		}
+
+		var p int = 0
+		for i := 0; i < nrClass; i++ {
+			for j := i + 1; j < nrClass; j++ {
+				for k := 0; k < model.nSV[i]; k++ {
+					addSV(p, start[i]+k, model.svCoef[j-1][start[i]+k])
+				}
+				for k := 0; k < model.nSV[j]; k++ {
+					addSV(p, start[j]+k, model.svCoef[i][start[j]+k])
+				}
+				p++
+			}
+		}
+	}
+
+	return // w, nrFeature
+}
+
+/**
+ * Precomputes the primal weights of a linear model once the SVs are known, so that a prediction costs
+ * O(nnz(x)) instead of a dot product with every SV.  The weights are not kept if they would take more
+ * space than the SVs, e.g. for few SVs of very high dimension, since predicting with the SVs is cheaper then.
+ */
+func (model *Model) computeLinearWeights() {
+	model.linearW = nil
+	model.nrFeature = 0
+	if model.param.KernelType != LINEAR {
+		return
+	}
+
+	var nrFeature int = 0
+	var nrNode int = 0 // nodes of the SVs, which share svSpace with the other instances of a trained model
+	for i := 0; i < model.l; i++ {
+		idx := model.sV[i]
+		for ; model.svSpace[idx].index != -1; idx++ {
+			nrFeature = maxi(nrFeature, model.svSpace[idx].index)
+		}
+		nrNode += idx - model.sV[i] + 1
+	}
+	if (nrFeature+1)*model.nrDecisionValues() > nrNode+model.l*len(model.svCoef) {
+		return
+	}
+
+	model.linearW, model.nrFeature = model.primalWeights()
+}
+
+/**
+ * Returns the primal weight vector of each decision function of a linear-kernel model, in the order of the
+ * decision values of PredictValues, or nil for the other kernels.  Element w[p][k] is the weight of dimension
+ * k, from dimension 0, and the decision value of x is the dot product of w[p] and x minus rho of p.
+ */
+func (model Model) LinearWeights() [][]float64 {
+	if model.param.KernelType != LINEAR {
+		return nil
+	}
+
+	flat, nrFeature := model.linearW, model.nrFeature
+	if flat == nil {
+		flat, nrFeature = model.primalWeights()
+	}
+
+	nrW := model.nrDecisionValues()
+	w := make([][]float64, nrW)
+	for p := 0; p < nrW; p++ {
+		w[p] = make([]float64, nrFeature+1)
+		for k := 0; k <= nrFeature; k++ {
+			w[p][k] = flat[k*nrW+p]
+		}
+	}
+	return w
+}
+
+/**
+ * Returns the decision values of px from the primal weights
+ */
+func (model Model) linearDecisionValues(px []snode) []float64 {
+	nrW := model.nrDecisionValues()
+	decisionValues := make([]float64, nrW)
+	for k := 0; px[k].index != -1; k++ {
+		index := px[k].index
+		if index <= model.nrFeature {
+			w := model.linearW[index*nrW : (index+1)*nrW]
+			for p := range decisionValues {
+				decisionValues[p] += w[p] * px[k].value
+			}
+		}
+	}
+	for p := range decisionValues {
+		decisionValues[p] -= model.rho[p]
+	}
+	return decisionValues
+}
+
+/**
+ * Same as linearDecisionValues, for the dense vector x whose element k is dimension k+1 (so the weights of
+ * dimension 0 do not contribute)
+ */
+func (model Model) linearDecisionValuesDense(x []float64) []float64 {
+	nrW := model.nrDecisionValues()
+	decisionValues := make([]float64, nrW)
+	for k := 0; k < len(x) && k < model.nrFeature; k++ {
+		if x[k] != 0 {
+			w := model.linearW[(k+1)*nrW : (k+2)*nrW]
+			for p := range decisionValues {
+				decisionValues[p] += w[p] * x[k]
+			}
+		}
+	}
+	for p := range decisionValues {
+		decisionValues[p] -= model.rho[p]
+	}
+	return decisionValues
+}
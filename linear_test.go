@@ -0,0 +1,189 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the primal weights of linear models
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+/**
+ * Returns a linear model of two SVs after an instance that is not a SV, as in the svSpace of a trained model
+ */
+func linearModelWithSVs(others, svs []snode) *Model {
+	param := NewParameter()
+	param.KernelType = LINEAR
+	model := NewModel(param)
+	model.l = 2
+	model.nrClass = 2
+	model.label = []int{1, -1}
+	model.nSV = []int{1, 1}
+	model.rho = []float64{0.5}
+	model.svCoef = [][]float64{{1, -1}}
+	model.svSpace = append(append([]snode(nil), others...), snode{index: -1})
+	model.sV = []int{len(model.svSpace)}
+	model.svSpace = append(model.svSpace, svs...)
+	model.sV = append(model.sV, len(model.svSpace)-2)
+	return model
+}
+
+/**
+ * The weights are kept only if they take less space than the SVs, whatever the size of the other instances
+ */
+func TestLinearWeightsSize(t *testing.T) {
+	var others []snode // an instance of 1000 features that is not a SV
+	for k := 1; k <= 1000; k++ {
+		others = append(others, snode{index: k, value: 1})
+	}
+
+	model := linearModelWithSVs(others, []snode{{1, 1}, {300, 2}, {-1, 0}, {2, 1}, {-1, 0}})
+	model.computeLinearWeights()
+	if model.linearW != nil {
+		t.Errorf("the %d weights of 2 SVs of dimension 300 are kept", len(model.linearW))
+	}
+
+	model = linearModelWithSVs(others, []snode{{1, 1}, {3, 2}, {-1, 0}, {2, 1}, {-1, 0}})
+	model.computeLinearWeights()
+	if model.linearW == nil || model.nrFeature != 3 {
+		t.Fatalf("the weights of 2 SVs of dimension 3 are not kept")
+	}
+	if w := model.LinearWeights(); !nearSlices(w[0], []float64{0, 1, -1, 2}, 0) {
+		t.Errorf("weights %v, want [0 1 -1 2]", w[0])
+	}
+}
+
+/**
+ * Predicting from the primal weights gives the decision values of the SVs, including dimension 0
+ */
+func TestLinearWeightsPredict(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.KernelType = LINEAR
+		// dimension 0 holds the first feature of each instance
+		text := strings.Replace(problemText(svmType), " 1:", " 0:", -1)
+		prob := mustProblem(t, text, param)
+		model := mustTrain(t, prob, param)
+		if model.linearW == nil {
+			t.Fatalf("svm type %d: the weights of %d SVs are not kept", svmType, model.l)
+		}
+		if w := model.LinearWeights(); len(w) != model.nrDecisionValues() || len(w[0]) != 5 {
+			t.Fatalf("svm type %d: %d weight vectors of %d dimensions", svmType, len(w), len(w[0]))
+		}
+
+		svs := *model
+		svs.linearW = nil
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetLine()
+			got, gotValues := model.PredictValues(x)
+			want, wantValues := svs.PredictValues(x)
+			if !near(got, want, 1e-9) || !nearSlices(gotValues, wantValues, 1e-9) {
+				t.Fatalf("svm type %d: predicted %v %v from the weights, want %v %v", svmType, got, gotValues, want, wantValues)
+			}
+		}
+	}
+}
+
+/**
+ * A compact linear model is read back with the same weights and predictions, without its SVs
+ */
+func TestCompactLinearModel(t *testing.T) {
+	for _, dimension0 := range []bool{false, true} {
+		param := quietParameter(C_SVC)
+		param.KernelType = LINEAR
+		param.CompactLinear = true
+		text := blobsText(1, 150, 4, 3)
+		if dimension0 {
+			text = strings.Replace(text, " 1:", " 0:", -1)
+		}
+		prob := mustProblem(t, text, param)
+		model := mustTrain(t, prob, param)
+
+		var buf bytes.Buffer
+		if _, err := model.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "\nw0 ") != dimension0 {
+			t.Errorf("dimension 0 %v, model:\n%s", dimension0, buf.String())
+		}
+		read, err := ReadModelFrom(&buf)
+		if err != nil {
+			t.Fatalf("ReadModelFrom: %v", err)
+		}
+		if read.l != 0 || read.NrClass() != 3 {
+			t.Fatalf("read %d SVs and %d classes", read.l, read.NrClass())
+		}
+
+		want := model.LinearWeights()
+		got := read.LinearWeights()
+		for p := range want {
+			if !nearSlices(got[p], want[p], 1e-12) {
+				t.Fatalf("weights %v, want %v", got[p], want[p])
+			}
+		}
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetLine()
+			if got, want := read.Predict(x), model.Predict(x); got != want {
+				t.Fatalf("predicted %v from the read model, want %v", got, want)
+			}
+		}
+	}
+}
+
+/**
+ * A malformed compact linear model is a ParseError at the line of its weights, and the other kernels ignore
+ * CompactLinear
+ */
+func TestCompactLinearModelErrors(t *testing.T) {
+	param := quietParameter(C_SVC)
+	param.KernelType = LINEAR
+	param.CompactLinear = true
+	model := mustTrain(t, mustProblem(t, blobsText(1, 150, 4, 3), param), param)
+	lines := strings.Split(strings.TrimSuffix(modelText(t, model), "\n"), "\n")
+	last := len(lines)
+
+	for _, test := range []struct {
+		weights      string // of the last feature, or "" to drop it
+		line, column int
+	}{
+		{"0.5 -1", last, 0},
+		{"0.5 -1 1 2", last, 0},
+		{"0.5 x -1", last, 5},
+		{"", last - 1, 0}, // at the last line read
+	} {
+		text := strings.Join(lines[:last-1], "\n") + "\n"
+		if test.weights != "" {
+			text += test.weights + "\n"
+		}
+		if _, err := ReadModelFrom(strings.NewReader(text)); !isParseError(err, test.line, test.column) {
+			t.Errorf("weights %q: %v, want a parse error at %d:%d", test.weights, err, test.line, test.column)
+		}
+	}
+
+	param.KernelType = RBF
+	model = mustTrain(t, mustProblem(t, blobsText(1, 150, 4, 3), param), param)
+	if model.LinearWeights() != nil {
+		t.Errorf("weights of an RBF model")
+	}
+	text := modelText(t, model)
+	model.param.CompactLinear = false
+	if want := modelText(t, model); text != want {
+		t.Errorf("RBF model written as\n%s\nwant\n%s", text, want)
+	}
+}
@@ -0,0 +1,389 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Dual coordinate descent solvers for linear SVC and SVR, as in LIBLINEAR
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+const linearBias = 1 // value of the constant feature appended to every instance, whose weight gives rho
+
+/**
+ * The instances of a problem set with the primal weight vector they define.  Every instance has an extra
+ * constant feature linearBias, so the bias is regularized as with LIBLINEAR's -B 1.
+ */
+type linearProblem struct {
+	l      int
+	x      [][]snode // instances, ending with index -1
+	w      []float64 // w[k] is the weight of dimension k
+	bias   float64   // weight of the bias feature
+	xSq    []float64 // squared norm of each instance, including the bias feature
+	random *rand.Rand
+}
+
+func newLinearProblem(prob *Problem) *linearProblem {
+	lp := &linearProblem{l: prob.l, x: make([][]snode, prob.l), xSq: make([]float64, prob.l)}
+
+	var maxIdx int = 0
+	for i := 0; i < prob.l; i++ {
+		lp.x[i] = prob.xSpace[prob.x[i]:]
+		lp.xSq[i] = linearBias * linearBias
+		for k := 0; lp.x[i][k].index != -1; k++ {
+			lp.xSq[i] += lp.x[i][k].value * lp.x[i][k].value
+			maxIdx = maxi(maxIdx, lp.x[i][k].index)
+		}
+	}
+	lp.w = make([]float64, maxIdx+1)
+	lp.random = rand.New(rand.NewSource(1)) // the same problem set always gives the same model
+
+	return lp
+}
+
+/**
+ * Returns w.x_i
+ */
+func (lp *linearProblem) dot(i int) float64 {
+	x := lp.x[i]
+	var sum float64 = lp.bias * linearBias
+	for k := 0; x[k].index != -1; k++ {
+		sum += lp.w[x[k].index] * x[k].value
+	}
+	return sum
+}
+
+/**
+ * Adds d*x_i to w
+ */
+func (lp *linearProblem) axpy(d float64, i int) {
+	x := lp.x[i]
+	lp.bias += d * linearBias
+	for k := 0; x[k].index != -1; k++ {
+		lp.w[x[k].index] += d * x[k].value
+	}
+}
+
+func (lp *linearProblem) shuffle(index []int, n int) {
+	for i := 0; i < n; i++ {
+		j := i + lp.random.Intn(n-i)
+		index[i], index[j] = index[j], index[i]
+	}
+}
+
+func (lp *linearProblem) wSquare() float64 {
+	var sum float64 = lp.bias * lp.bias
+	for _, w := range lp.w {
+		sum += w * w
+	}
+	return sum
+}
+
+func linearMaxIter(param *Parameter) int {
+	if param.MaxIter > 0 {
+		return param.MaxIter
+	}
+	return 1000 // LIBLINEAR's default
+}
+
+/**
+ * Solves the dual of L1-loss (hinge) or L2-loss (squared hinge) linear C-SVC by coordinate descent with
+ * shrinking, like LIBLINEAR's solve_l2r_l1l2_svc.  The returned alpha are the coefficients y_i*alpha_i.
+ */
+func solveLinearSVC(ctx context.Context, prob *Problem, param *Parameter, Cp, Cn float64, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
+	var l int = prob.l
+	lp := newLinearProblem(prob)
+	observer := param.observer()
+	done := ctx.Done()
+
+	alpha := make([]float64, l)
+	y := make([]float64, l)
+	diag := make([]float64, l)       // diagonal term added to Q by the L2 loss
+	upperBound := make([]float64, l) // upper bound of each alpha
+	QD := make([]float64, l)
+	index := make([]int, l)
+	for i := 0; i < l; i++ {
+		var C float64 = Cn
+		if prob.y[i] > 0 {
+			y[i], C = 1, Cp
+		} else {
+			y[i] = -1
+		}
+		C *= prob.weight(i)
+
+		if param.Solver == L2R_L2LOSS_SVC_DUAL {
+			diag[i], upperBound[i] = 0.5/C, math.Inf(1)
+		} else {
+			diag[i], upperBound[i] = 0, C
+		}
+		QD[i] = diag[i] + lp.xSq[i]
+		index[i] = i
+
+		if seed != nil { // warm start
+			alpha[i] = minf(math.Abs(seed[i]), upperBound[i])
+			lp.axpy(alpha[i]*y[i], i)
+		}
+	}
+
+	maxIter := linearMaxIter(param)
+	activeSize := l
+	PGmaxOld, PGminOld := math.Inf(1), math.Inf(-1)
+
+	var iter int = 0
+	for iter < maxIter {
+		select {
+		case <-done:
+			return solution{}, &TrainingStoppedError{Err: ctx.Err()}
+		default:
+		}
+
+		PGmaxNew, PGminNew := math.Inf(-1), math.Inf(1)
+		lp.shuffle(index, activeSize)
+
+		for s := 0; s < activeSize; s++ {
+			i := index[s]
+			G := y[i]*lp.dot(i) - 1 + alpha[i]*diag[i]
+			C := upperBound[i]
+
+			var PG float64 = 0
+			if alpha[i] == 0 {
+				if G > PGmaxOld {
+					activeSize--
+					index[s], index[activeSize] = index[activeSize], index[s]
+					s--
+					continue
+				} else if G < 0 {
+					PG = G
+				}
+			} else if alpha[i] == C {
+				if G < PGminOld {
+					activeSize--
+					index[s], index[activeSize] = index[activeSize], index[s]
+					s--
+					continue
+				} else if G > 0 {
+					PG = G
+				}
+			} else {
+				PG = G
+			}
+
+			PGmaxNew = maxf(PGmaxNew, PG)
+			PGminNew = minf(PGminNew, PG)
+
+			if math.Abs(PG) > 1.0e-12 {
+				alphaOld := alpha[i]
+				alpha[i] = minf(maxf(alpha[i]-G/QD[i], 0), C)
+				lp.axpy((alpha[i]-alphaOld)*y[i], i)
+			}
+		}
+
+		iter++
+		if iter%10 == 0 {
+			observer.Iteration(iter, PGmaxNew-PGminNew)
+		}
+
+		if PGmaxNew-PGminNew <= param.Eps {
+			if activeSize == l {
+				break
+			}
+			activeSize = l
+			observer.Unshrink(iter)
+			PGmaxOld, PGminOld = math.Inf(1), math.Inf(-1)
+			continue
+		}
+		PGmaxOld, PGminOld = PGmaxNew, PGminNew
+		if PGmaxOld <= 0 {
+			PGmaxOld = math.Inf(1)
+		}
+		if PGminOld >= 0 {
+			PGminOld = math.Inf(-1)
+		}
+	}
+
+	// objective value of the dual
+	var v float64 = lp.wSquare()
+	for i := 0; i < l; i++ {
+		v += alpha[i] * (alpha[i]*diag[i] - 2)
+	}
+
+	for i := 0; i < l; i++ {
+		alpha[i] *= y[i]
+	}
+
+	upperBoundP, upperBoundN := Cp, Cn
+	if param.Solver == L2R_L2LOSS_SVC_DUAL {
+		upperBoundP, upperBoundN = math.Inf(1), math.Inf(1)
+	}
+
+	return solution{obj: v / 2, rho: -lp.bias * linearBias, upper_bound_p: upperBoundP, upper_bound_n: upperBoundN,
+		alpha: alpha, iter: iter, maxIterReached: iter >= maxIter}, nil
+}
+
+/**
+ * Solves the dual of L1-loss or L2-loss linear epsilon-SVR by coordinate descent with shrinking, like
+ * LIBLINEAR's solve_l2r_l1l2_svr.  The returned alpha are the coefficients beta_i.
+ */
+func solveLinearSVR(ctx context.Context, prob *Problem, param *Parameter, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
+	var l int = prob.l
+	lp := newLinearProblem(prob)
+	observer := param.observer()
+	done := ctx.Done()
+
+	var p float64 = param.P
+
+	beta := make([]float64, l)
+	lambda := make([]float64, l)     // diagonal term added to Q by the L2 loss
+	upperBound := make([]float64, l) // bound of the absolute value of each beta
+	index := make([]int, l)
+	for i := 0; i < l; i++ {
+		C := param.C * prob.weight(i)
+		if param.Solver == L2R_L2LOSS_SVR_DUAL {
+			lambda[i], upperBound[i] = 0.5/C, math.Inf(1)
+		} else {
+			lambda[i], upperBound[i] = 0, C
+		}
+		index[i] = i
+
+		if seed != nil { // warm start
+			beta[i] = minf(maxf(seed[i], -upperBound[i]), upperBound[i])
+			lp.axpy(beta[i], i)
+		}
+	}
+
+	maxIter := linearMaxIter(param)
+	activeSize := l
+	GmaxOld := math.Inf(1)
+	var Gnorm1Init float64 = -1
+
+	var iter int = 0
+	for iter < maxIter {
+		select {
+		case <-done:
+			return solution{}, &TrainingStoppedError{Err: ctx.Err()}
+		default:
+		}
+
+		var GmaxNew, Gnorm1New float64 = 0, 0
+		lp.shuffle(index, activeSize)
+
+		for s := 0; s < activeSize; s++ {
+			i := index[s]
+			G := -prob.y[i] + lambda[i]*beta[i] + lp.dot(i)
+			H := lp.xSq[i] + lambda[i]
+			U := upperBound[i]
+
+			Gp := G + p
+			Gn := G - p
+			var violation float64 = 0
+			if beta[i] == 0 {
+				if Gp < 0 {
+					violation = -Gp
+				} else if Gn > 0 {
+					violation = Gn
+				} else if Gp > GmaxOld && Gn < -GmaxOld {
+					activeSize--
+					index[s], index[activeSize] = index[activeSize], index[s]
+					s--
+					continue
+				}
+			} else if beta[i] >= U {
+				if Gp > 0 {
+					violation = Gp
+				} else if Gp < -GmaxOld {
+					activeSize--
+					index[s], index[activeSize] = index[activeSize], index[s]
+					s--
+					continue
+				}
+			} else if beta[i] <= -U {
+				if Gn < 0 {
+					violation = -Gn
+				} else if Gn > GmaxOld {
+					activeSize--
+					index[s], index[activeSize] = index[activeSize], index[s]
+					s--
+					continue
+				}
+			} else if beta[i] > 0 {
+				violation = math.Abs(Gp)
+			} else {
+				violation = math.Abs(Gn)
+			}
+
+			GmaxNew = maxf(GmaxNew, violation)
+			Gnorm1New += violation
+
+			// obtain Newton direction d
+			var d float64
+			if Gp < H*beta[i] {
+				d = -Gp / H
+			} else if Gn > H*beta[i] {
+				d = -Gn / H
+			} else {
+				d = -beta[i]
+			}
+
+			if math.Abs(d) < 1.0e-12 {
+				continue
+			}
+
+			betaOld := beta[i]
+			beta[i] = minf(maxf(beta[i]+d, -U), U)
+			if d = beta[i] - betaOld; d != 0 {
+				lp.axpy(d, i)
+			}
+		}
+
+		if iter == 0 {
+			Gnorm1Init = Gnorm1New
+		}
+		iter++
+		if iter%10 == 0 {
+			observer.Iteration(iter, GmaxNew)
+		}
+
+		if Gnorm1New <= param.Eps*Gnorm1Init {
+			if activeSize == l {
+				break
+			}
+			activeSize = l
+			observer.Unshrink(iter)
+			GmaxOld = math.Inf(1)
+			continue
+		}
+		GmaxOld = GmaxNew
+	}
+
+	// objective value of the dual
+	var v float64 = lp.wSquare() / 2
+	for i := 0; i < l; i++ {
+		v += p*math.Abs(beta[i]) - prob.y[i]*beta[i] + 0.5*lambda[i]*beta[i]*beta[i]
+	}
+
+	var bound float64 = param.C
+	if param.Solver == L2R_L2LOSS_SVR_DUAL {
+		bound = math.Inf(1)
+	}
+
+	return solution{obj: v, rho: -lp.bias * linearBias, upper_bound_p: bound, upper_bound_n: bound,
+		alpha: beta, iter: iter, maxIterReached: iter >= maxIter}, nil
+}
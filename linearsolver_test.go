@@ -0,0 +1,174 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the dual coordinate descent solvers of linear SVMs
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+/**
+ * Returns the primal objective of a binary or regression model, whose bias is regularized as the weight
+ * of a constant feature, as the dual coordinate descent solvers do
+ */
+func linearPrimal(model *Model, prob *Problem) float64 {
+	param := model.param
+	w := model.LinearWeights()[0]
+	var obj float64 = model.rho[0] * model.rho[0] / 2
+	for _, wk := range w {
+		obj += wk * wk / 2
+	}
+
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		y, x := prob.GetLine()
+		var f float64 = -model.rho[0]
+		for index, value := range x {
+			f += w[index] * value
+		}
+
+		var loss float64
+		if param.SvmType == C_SVC {
+			if int(y) != model.label[0] {
+				f = -f
+			}
+			loss = math.Max(0, 1-f)
+		} else {
+			loss = math.Max(0, math.Abs(y-f)-param.P)
+		}
+		if param.Solver == L2R_L2LOSS_SVC_DUAL || param.Solver == L2R_L2LOSS_SVR_DUAL {
+			loss *= loss
+		}
+		obj += param.C * loss
+	}
+	return obj
+}
+
+/**
+ * The dual coordinate descent solvers reach the primal objective of the weights of the SMO solver at least,
+ * with a dual objective close to it, and their models predict from the primal weights only
+ */
+func TestLinearSolvers(t *testing.T) {
+	for _, solver := range []int{L2R_L2LOSS_SVC_DUAL, L2R_L1LOSS_SVC_DUAL, L2R_L2LOSS_SVR_DUAL, L2R_L1LOSS_SVR_DUAL} {
+		svmType := C_SVC
+		text := blobsText(1, 200, 4, 2)
+		if solver == L2R_L2LOSS_SVR_DUAL || solver == L2R_L1LOSS_SVR_DUAL {
+			svmType = EPSILON_SVR
+			text = regressionText(1, 200, 4)
+		}
+		param := quietParameter(svmType)
+		param.KernelType = LINEAR
+		param.Solver = solver
+		param.Eps = 1e-4
+		prob := mustProblem(t, text, param)
+		model := mustTrain(t, prob, param)
+
+		smoParam := *param
+		smoParam.Solver = SMO
+		smo := mustTrain(t, prob, &smoParam)
+		smo.param = param // evaluated with the loss of the solver
+
+		primal, smoPrimal := linearPrimal(model, prob), linearPrimal(smo, prob)
+		if primal > smoPrimal*(1+1e-3) {
+			t.Errorf("solver %d: primal objective %v, and %v with the weights of SMO", solver, primal, smoPrimal)
+		}
+		info := model.Report().Subproblems[0]
+		if !near(-info.Obj, primal, 1e-2*primal) {
+			t.Errorf("solver %d: dual objective %v, primal objective %v", solver, info.Obj, primal)
+		}
+		if info.Iterations <= 0 || info.MaxIterReached || info.KernelEvaluations != 0 {
+			t.Errorf("solver %d: %+v", solver, info)
+		}
+
+		if model.linearW == nil || model.l > prob.l {
+			t.Errorf("solver %d: %d SVs, weights %v", solver, model.l, model.linearW)
+		}
+		svs := *model
+		svs.linearW = nil
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetLine()
+			_, got := model.PredictValues(x)
+			_, want := svs.PredictValues(x)
+			if !nearSlices(got, want, 1e-9) {
+				t.Fatalf("solver %d: decision values %v from the weights, want %v", solver, got, want)
+			}
+		}
+	}
+}
+
+/**
+ * The SVC solvers train the one-vs-one pairs of a multi-class problem, and a maximum number of iterations
+ * stops them
+ */
+func TestLinearSolverMultiClass(t *testing.T) {
+	param := quietParameter(C_SVC)
+	param.KernelType = LINEAR
+	param.Solver = L2R_L1LOSS_SVC_DUAL
+	prob := mustProblem(t, blobsText(1, 150, 4, 3), param)
+	model := mustTrain(t, prob, param)
+	if report := model.Report(); len(report.Subproblems) != 3 || report.MaxIterReached {
+		t.Fatalf("report %+v", *report)
+	}
+	var correct int = 0
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		y, x := prob.GetLine()
+		if model.Predict(x) == y {
+			correct++
+		}
+	}
+	if correct < prob.l*3/4 {
+		t.Errorf("%d of %d training instances predicted", correct, prob.l)
+	}
+
+	param.MaxIter = 2
+	param.Eps = 1e-12
+	model = mustTrain(t, prob, param)
+	for _, info := range model.Report().Subproblems {
+		if info.Iterations != 2 || !info.MaxIterReached {
+			t.Errorf("subproblem %v: %d iterations (maximum reached %v)", info.Labels, info.Iterations, info.MaxIterReached)
+		}
+	}
+}
+
+/**
+ * The dual coordinate descent solvers need the linear kernel and their own svm type
+ */
+func TestLinearSolverValidate(t *testing.T) {
+	for _, test := range []struct {
+		solver, svmType, kernelType int
+		valid                       bool
+	}{
+		{L2R_L2LOSS_SVC_DUAL, C_SVC, LINEAR, true},
+		{L2R_L1LOSS_SVR_DUAL, EPSILON_SVR, LINEAR, true},
+		{L2R_L1LOSS_SVC_DUAL, C_SVC, RBF, false},
+		{L2R_L2LOSS_SVC_DUAL, NU_SVC, LINEAR, false},
+		{L2R_L1LOSS_SVC_DUAL, EPSILON_SVR, LINEAR, false},
+		{L2R_L2LOSS_SVR_DUAL, NU_SVR, LINEAR, false},
+		{L2R_L1LOSS_SVR_DUAL, EPSILON_SVR, POLY, false},
+		{L2R_L2LOSS_SVR_DUAL, ONE_CLASS, LINEAR, false},
+		{L2R_L1LOSS_SVR_DUAL + 1, C_SVC, LINEAR, false},
+	} {
+		param := quietParameter(test.svmType)
+		param.Solver = test.solver
+		param.KernelType = test.kernelType
+		if err := param.Validate(nil); (err == nil) != test.valid || err != nil && !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("solver %d, svm type %d, kernel %d: %v", test.solver, test.svmType, test.kernelType, err)
+		}
+	}
+}
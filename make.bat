@@ -4,4 +4,8 @@ cd cmds\svm-train
 go install
 cd ..\svm-predict
 go install
+cd ..\svm-scale
+go install
+cd ..\svm-grid
+go install
 cd ..\..
@@ -0,0 +1,636 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Evaluation metrics for classification, ranking and probability estimates
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+/**
+ * Counts the predicted labels against the target labels
+ */
+type ConfusionMatrix struct {
+	counts map[[2]float64]int // number of instances for each (target, predicted) pair
+	labels map[float64]bool
+	total  int
+}
+
+func NewConfusionMatrix() *ConfusionMatrix {
+	return &ConfusionMatrix{counts: make(map[[2]float64]int), labels: make(map[float64]bool)}
+}
+
+func (m *ConfusionMatrix) Add(predict, target float64) {
+	m.counts[[2]float64{target, predict}]++
+	m.labels[target] = true
+	m.labels[predict] = true
+	m.total++
+}
+
+/**
+ * Returns the target and predicted labels seen so far, in ascending order
+ */
+func (m *ConfusionMatrix) Labels() []float64 {
+	labels := make([]float64, 0, len(m.labels))
+	for label := range m.labels {
+		labels = append(labels, label)
+	}
+	sort.Float64s(labels)
+	return labels
+}
+
+/**
+ * Returns the number of instances of the target label that were predicted as the predicted label
+ */
+func (m *ConfusionMatrix) Count(target, predict float64) int {
+	return m.counts[[2]float64{target, predict}]
+}
+
+/**
+ * Returns the number of instances of each label as the target (support) and as the prediction
+ */
+func (m *ConfusionMatrix) marginals() (labels []float64, targets, predicts []int) {
+	labels = m.Labels()
+	targets = make([]int, len(labels))
+	predicts = make([]int, len(labels))
+	for i, target := range labels {
+		for j, predict := range labels {
+			n := m.Count(target, predict)
+			targets[i] += n
+			predicts[j] += n
+		}
+	}
+	return
+}
+
+func (m *ConfusionMatrix) correct() int {
+	var correct int = 0
+	for label := range m.labels {
+		correct += m.Count(label, label)
+	}
+	return correct
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+func (m *ConfusionMatrix) Accuracy() float64 {
+	return ratio(m.correct(), m.total)
+}
+
+/**
+ * Precision, recall and F1 score of one label, and its number of target instances
+ */
+type ClassMetrics struct {
+	Label     float64
+	Precision float64 // 0 if the label was never predicted
+	Recall    float64 // 0 if the label was never a target
+	F1        float64
+	Support   int
+}
+
+/**
+ * Returns the metrics of each label, in ascending order of the labels
+ */
+func (m *ConfusionMatrix) PerClass() []ClassMetrics {
+	labels, targets, predicts := m.marginals()
+	metrics := make([]ClassMetrics, len(labels))
+	for k, label := range labels {
+		tp := m.Count(label, label)
+		precision := ratio(tp, predicts[k])
+		recall := ratio(tp, targets[k])
+		metrics[k] = ClassMetrics{Label: label, Precision: precision, Recall: recall, F1: f1(precision, recall), Support: targets[k]}
+	}
+	return metrics
+}
+
+/**
+ * Returns the unweighted means of the per-label precision, recall and F1 score
+ */
+func (m *ConfusionMatrix) Macro() (precision, recall, f1Score float64) {
+	metrics := m.PerClass()
+	if len(metrics) == 0 {
+		return
+	}
+	for _, c := range metrics {
+		precision += c.Precision
+		recall += c.Recall
+		f1Score += c.F1
+	}
+	n := float64(len(metrics))
+	return precision / n, recall / n, f1Score / n
+}
+
+/**
+ * Returns the precision, recall and F1 score from the true and false positives summed over the labels.
+ * For single-label classification all three equal the accuracy.
+ */
+func (m *ConfusionMatrix) Micro() (precision, recall, f1Score float64) {
+	precision = ratio(m.correct(), m.total)
+	recall = precision
+	return precision, recall, f1(precision, recall)
+}
+
+/**
+ * Returns the mean recall of the labels that occur as targets
+ */
+func (m *ConfusionMatrix) BalancedAccuracy() float64 {
+	var sum float64 = 0
+	var n int = 0
+	for _, c := range m.PerClass() {
+		if c.Support > 0 {
+			sum += c.Recall
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+/**
+ * Returns the Matthews correlation coefficient, generalized to more than two labels (Gorodkin's R_K)
+ */
+func (m *ConfusionMatrix) MatthewsCorrelation() float64 {
+	_, targets, predicts := m.marginals()
+	s := float64(m.total)
+	c := float64(m.correct())
+
+	var sumPT, sumPP, sumTT float64 = 0, 0, 0
+	for k := range targets {
+		p, t := float64(predicts[k]), float64(targets[k])
+		sumPT += p * t
+		sumPP += p * p
+		sumTT += t * t
+	}
+
+	denominator := math.Sqrt((s*s - sumPP) * (s*s - sumTT))
+	if denominator == 0 {
+		return 0
+	}
+	return (c*s - sumPT) / denominator
+}
+
+/**
+ * Collects the scores of positive and negative instances for the binary ranking metrics.  The score may
+ * be a decision value or a probability estimate of the positive label; higher means more likely positive.
+ */
+type RankingMetrics struct {
+	scores   []float64
+	positive []bool
+}
+
+func NewRankingMetrics() *RankingMetrics {
+	return &RankingMetrics{}
+}
+
+func (r *RankingMetrics) Add(score float64, positive bool) {
+	r.scores = append(r.scores, score)
+	r.positive = append(r.positive, positive)
+}
+
+/**
+ * Calls f for each distinct score in descending order, with the number of positive and negative instances
+ * having a score at least as high
+ */
+func (r *RankingMetrics) thresholds(f func(tp, fp int)) (nrPositive, nrNegative int) {
+	order := make([]int, len(r.scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return r.scores[order[a]] > r.scores[order[b]] })
+
+	var tp, fp int = 0, 0
+	for k, i := range order {
+		if r.positive[i] {
+			tp++
+		} else {
+			fp++
+		}
+		if k+1 == len(order) || r.scores[order[k+1]] != r.scores[i] {
+			f(tp, fp)
+		}
+	}
+	return tp, fp
+}
+
+/**
+ * Returns the area under the ROC curve, or NaN unless there are both positive and negative instances
+ */
+func (r *RankingMetrics) ROCAUC() float64 {
+	var area float64 = 0
+	var lastTP, lastFP int = 0, 0
+	nrPositive, nrNegative := r.thresholds(func(tp, fp int) {
+		area += float64(fp-lastFP) * float64(tp+lastTP) / 2 // trapezoid, for tied scores
+		lastTP, lastFP = tp, fp
+	})
+	if nrPositive == 0 || nrNegative == 0 {
+		return math.NaN()
+	}
+	return area / (float64(nrPositive) * float64(nrNegative))
+}
+
+/**
+ * Returns the area under the precision-recall curve as the average precision, or NaN if there are no
+ * positive instances
+ */
+func (r *RankingMetrics) PRAUC() float64 {
+	var area float64 = 0
+	var lastTP int = 0
+	nrPositive, _ := r.thresholds(func(tp, fp int) {
+		area += float64(tp-lastTP) * float64(tp) / float64(tp+fp)
+		lastTP = tp
+	})
+	if nrPositive == 0 {
+		return math.NaN()
+	}
+	return area / float64(nrPositive)
+}
+
+/**
+ * Evaluates probability estimates, as returned by Model.PredictProbability, against the target labels
+ */
+type ProbabilityMetrics struct {
+	labels   []int // labels of the probability estimates, as returned by Model.Labels
+	ranking  []*RankingMetrics
+	logLoss  float64
+	total    int
+	minProba float64
+}
+
+func NewProbabilityMetrics(labels []int) *ProbabilityMetrics {
+	p := &ProbabilityMetrics{labels: labels, ranking: make([]*RankingMetrics, len(labels)), minProba: 1e-15}
+	for k := range labels {
+		p.ranking[k] = NewRankingMetrics()
+	}
+	return p
+}
+
+func (p *ProbabilityMetrics) Add(probabilityEstimate []float64, target float64) {
+	var proba float64 = 0 // estimate of the target label, which may be unknown to the model
+	for k, label := range p.labels {
+		positive := float64(label) == target
+		if positive {
+			proba = probabilityEstimate[k]
+		}
+		p.ranking[k].Add(probabilityEstimate[k], positive)
+	}
+	p.logLoss -= math.Log(math.Max(proba, p.minProba))
+	p.total++
+}
+
+/**
+ * Returns the mean negative log-likelihood of the target labels.  Estimates are clipped at 1e-15.
+ */
+func (p *ProbabilityMetrics) LogLoss() float64 {
+	if p.total == 0 {
+		return math.NaN()
+	}
+	return p.logLoss / float64(p.total)
+}
+
+/**
+ * Returns the ROC AUC of the first label for two labels, and otherwise the mean of the one-vs-rest
+ * ROC AUC of the labels with both positive and negative instances
+ */
+func (p *ProbabilityMetrics) ROCAUC() float64 {
+	return p.average(func(r *RankingMetrics) float64 { return r.ROCAUC() })
+}
+
+/**
+ * Returns the PR AUC of the first label for two labels, and otherwise the mean of the one-vs-rest PR AUC
+ */
+func (p *ProbabilityMetrics) PRAUC() float64 {
+	return p.average(func(r *RankingMetrics) float64 { return r.PRAUC() })
+}
+
+func (p *ProbabilityMetrics) average(metric func(*RankingMetrics) float64) float64 {
+	if len(p.ranking) == 2 {
+		return metric(p.ranking[0])
+	}
+	var sum float64 = 0
+	var n int = 0
+	for _, r := range p.ranking {
+		if v := metric(r); !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+/**
+ * Names of the metrics that Evaluation.WriteMetrics can write
+ */
+var MetricNames = []string{
+	"accuracy",  // accuracy of the predicted labels
+	"confusion", // confusion matrix
+	"class",     // precision, recall and F1 score of each label
+	"macro",     // macro-averaged precision, recall and F1 score
+	"micro",     // micro-averaged precision, recall and F1 score
+	"balanced",  // balanced accuracy
+	"mcc",       // Matthews correlation coefficient
+	"auc",       // ROC AUC
+	"prauc",     // PR AUC
+	"logloss",   // log loss of the probability estimates
+	"mse",       // mean squared error
+	"scc",       // squared correlation coefficient
+	"mae",       // mean absolute error
+	"rmse",      // root mean squared error
+	"r2",        // coefficient of determination
+}
+
+/**
+ * Parses a comma-separated list of metric names.  "all" selects every metric that applies to the
+ * evaluation.
+ */
+func ParseMetricNames(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			names = append(names, name)
+			continue
+		}
+		known := false
+		for _, metric := range MetricNames {
+			if name == metric {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, invalidParameter("unknown metric %q (one of all, %s)", name, strings.Join(MetricNames, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+/**
+ * Collects the predictions of a model, or of cross validation, for all the metrics that apply
+ */
+type Evaluation struct {
+	SvmType     int
+	Labels      []int               // class labels, in the order of the probability estimates (classification only)
+	Confusion   *ConfusionMatrix    // predicted against target labels
+	Errors      SquareErrorComputer // regression errors of the predicted values
+	Ranking     *RankingMetrics     // decision values of binary classification and one-class SVM, or nil
+	Probability *ProbabilityMetrics // probability estimates, or nil
+}
+
+/**
+ * Returns an empty evaluation.  labels are the labels of the classification model (see Model.Labels); the
+ * decision values of a binary model rank its first label as the positive class.
+ */
+func NewEvaluation(svmType int, labels []int) *Evaluation {
+	return &Evaluation{SvmType: svmType, Labels: labels, Confusion: NewConfusionMatrix(), Errors: NewSquareErrorComputer()}
+}
+
+func (e *Evaluation) regression() bool {
+	return e.SvmType == EPSILON_SVR || e.SvmType == NU_SVR
+}
+
+/**
+ * Adds a predicted label (or value) and its target
+ */
+func (e *Evaluation) Add(predict, target float64) {
+	e.Confusion.Add(predict, target)
+	e.Errors.Sum(predict, target)
+}
+
+/**
+ * Adds the decision values of a prediction, as returned by Model.PredictValues, for the ranking metrics.
+ * Only the decision values of binary classification and one-class SVM are ranked.
+ */
+func (e *Evaluation) AddDecisionValues(decisionValues []float64, target float64) {
+	var positive bool
+	switch {
+	case e.SvmType == ONE_CLASS && len(decisionValues) == 1:
+		positive = target > 0
+	case (e.SvmType == C_SVC || e.SvmType == NU_SVC) && len(decisionValues) == 1 && len(e.Labels) == 2:
+		positive = target == float64(e.Labels[0])
+	default:
+		return
+	}
+	if e.Ranking == nil {
+		e.Ranking = NewRankingMetrics()
+	}
+	e.Ranking.Add(decisionValues[0], positive)
+}
+
+/**
+ * Adds the probability estimates of a prediction, as returned by Model.PredictProbability
+ */
+func (e *Evaluation) AddProbabilities(probabilityEstimate []float64, target float64) {
+	if len(probabilityEstimate) != len(e.Labels) || len(e.Labels) == 0 {
+		return
+	}
+	if e.Probability == nil {
+		e.Probability = NewProbabilityMetrics(e.Labels)
+	}
+	e.Probability.Add(probabilityEstimate, target)
+}
+
+/**
+ * Predicts x with a model trained on a subset of the problem, whose labels may be ordered differently
+ * from the evaluation labels, and adds the prediction.  Returns the predicted label or value.
+ */
+func (e *Evaluation) addModelPrediction(model *Model, x map[int]float64, target float64, probability bool) float64 {
+	var predict float64
+	if probability {
+		var probabilityEstimate []float64
+		predict, probabilityEstimate = model.PredictProbability(x)
+		if probabilityEstimate != nil {
+			estimates := make([]float64, len(e.Labels))
+			for k, label := range model.label {
+				for j := range e.Labels {
+					if e.Labels[j] == label {
+						estimates[j] = probabilityEstimate[k]
+					}
+				}
+			}
+			e.AddProbabilities(estimates, target)
+		}
+	} else {
+		var decisionValues []float64
+		predict, decisionValues = model.PredictValues(x)
+		if model.nrClass == 2 && len(e.Labels) == 2 && model.label[0] != e.Labels[0] {
+			decisionValues = []float64{-decisionValues[0]} // the fold model ranks the other label as positive
+		}
+		e.AddDecisionValues(decisionValues, target)
+	}
+	e.Add(predict, target)
+	return predict
+}
+
+/**
+ * Writes the named metrics to w, one or more lines each.  A metric that cannot be computed from the
+ * collected predictions is reported as not available, unless it was selected by "all".
+ */
+func (e *Evaluation) WriteMetrics(w io.Writer, names []string) error {
+	var all bool = false
+	var selected []string
+	for _, name := range names {
+		if name == "all" {
+			all = true
+		} else {
+			selected = append(selected, name)
+		}
+	}
+	if all {
+		selected = nil
+		for _, name := range MetricNames {
+			if e.applies(name) {
+				selected = append(selected, name)
+			}
+		}
+	}
+
+	for _, name := range selected {
+		if err := e.writeMetric(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Does the metric apply to this kind of evaluation, with the predictions collected?
+ */
+func (e *Evaluation) applies(name string) bool {
+	switch name {
+	case "mse", "scc", "mae", "rmse", "r2":
+		return e.regression()
+	case "auc", "prauc":
+		return !e.regression() && (e.Ranking != nil || e.Probability != nil)
+	case "logloss":
+		return !e.regression() && e.Probability != nil
+	}
+	return !e.regression()
+}
+
+func (e *Evaluation) writeMetric(w io.Writer, name string) (err error) {
+	notAvailable := func(what string) error {
+		_, err := fmt.Fprintf(w, "%s is not available (%s)\n", name, what)
+		return err
+	}
+
+	switch name {
+	case "accuracy":
+		correct := e.Confusion.correct()
+		_, err = fmt.Fprintf(w, "Accuracy = %.6g%% (%d/%d)\n", 100*e.Confusion.Accuracy(), correct, e.Confusion.total)
+
+	case "confusion":
+		labels := e.Confusion.Labels()
+		_, err = fmt.Fprintf(w, "Confusion matrix (rows: target, columns: predicted)\n%10s", "")
+		for _, predict := range labels {
+			fmt.Fprintf(w, " %10g", predict)
+		}
+		for _, target := range labels {
+			fmt.Fprintf(w, "\n%10g", target)
+			for _, predict := range labels {
+				fmt.Fprintf(w, " %10d", e.Confusion.Count(target, predict))
+			}
+		}
+		_, err = fmt.Fprintln(w)
+
+	case "class":
+		for _, c := range e.Confusion.PerClass() {
+			_, err = fmt.Fprintf(w, "Class %g: precision = %.6g, recall = %.6g, F1 = %.6g, support = %d\n", c.Label, c.Precision, c.Recall, c.F1, c.Support)
+		}
+
+	case "macro":
+		precision, recall, f1Score := e.Confusion.Macro()
+		_, err = fmt.Fprintf(w, "Macro precision = %.6g, recall = %.6g, F1 = %.6g\n", precision, recall, f1Score)
+
+	case "micro":
+		precision, recall, f1Score := e.Confusion.Micro()
+		_, err = fmt.Fprintf(w, "Micro precision = %.6g, recall = %.6g, F1 = %.6g\n", precision, recall, f1Score)
+
+	case "balanced":
+		_, err = fmt.Fprintf(w, "Balanced accuracy = %.6g%%\n", 100*e.Confusion.BalancedAccuracy())
+
+	case "mcc":
+		_, err = fmt.Fprintf(w, "Matthews correlation coefficient = %.6g\n", e.Confusion.MatthewsCorrelation())
+
+	case "auc", "prauc":
+		var value float64
+		var source string
+		switch {
+		case e.Probability != nil:
+			source = "probability estimates"
+			if name == "auc" {
+				value = e.Probability.ROCAUC()
+			} else {
+				value = e.Probability.PRAUC()
+			}
+		case e.Ranking != nil:
+			source = "decision values"
+			if name == "auc" {
+				value = e.Ranking.ROCAUC()
+			} else {
+				value = e.Ranking.PRAUC()
+			}
+		default:
+			return notAvailable("needs the decision values of a binary or one-class model, or probability estimates")
+		}
+		title := "ROC AUC"
+		if name == "prauc" {
+			title = "PR AUC"
+		}
+		_, err = fmt.Fprintf(w, "%s = %.6g (%s)\n", title, value, source)
+
+	case "logloss":
+		if e.Probability == nil {
+			return notAvailable("needs probability estimates")
+		}
+		_, err = fmt.Fprintf(w, "Log loss = %.6g\n", e.Probability.LogLoss())
+
+	case "mse":
+		_, err = fmt.Fprintf(w, "Mean squared error = %.6g\n", e.Errors.MeanSquareError())
+	case "scc":
+		_, err = fmt.Fprintf(w, "Squared correlation coefficient = %.6g\n", e.Errors.SquareCorrelationCoeff())
+	case "mae":
+		_, err = fmt.Fprintf(w, "Mean absolute error = %.6g\n", e.Errors.MeanAbsoluteError())
+	case "rmse":
+		_, err = fmt.Fprintf(w, "Root mean squared error = %.6g\n", e.Errors.RootMeanSquareError())
+	case "r2":
+		_, err = fmt.Fprintf(w, "R-squared = %.6g\n", e.Errors.RSquared())
+	}
+	return
+}
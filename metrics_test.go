@@ -0,0 +1,228 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the evaluation metrics, against values computed by hand
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/**
+ * Returns the confusion matrix of targets (rows) against predictions (columns)
+ *     1  2  3
+ *  1  3  1  0
+ *  2  1  2  0
+ *  3  1  0  1
+ */
+func threeClassConfusion() *ConfusionMatrix {
+	m := NewConfusionMatrix()
+	counts := [3][3]int{{3, 1, 0}, {1, 2, 0}, {1, 0, 1}}
+	for target := range counts {
+		for predict, n := range counts[target] {
+			for k := 0; k < n; k++ {
+				m.Add(float64(predict+1), float64(target+1))
+			}
+		}
+	}
+	return m
+}
+
+func TestConfusionMatrix(t *testing.T) {
+	m := threeClassConfusion()
+	if labels := m.Labels(); !reflect.DeepEqual(labels, []float64{1, 2, 3}) {
+		t.Errorf("labels %v", labels)
+	}
+	if m.Count(1, 2) != 1 || m.Count(2, 1) != 1 || m.Count(3, 2) != 0 {
+		t.Errorf("counts %d %d %d", m.Count(1, 2), m.Count(2, 1), m.Count(3, 2))
+	}
+	if !near(m.Accuracy(), 6.0/9, 1e-15) {
+		t.Errorf("accuracy %v", m.Accuracy())
+	}
+
+	want := []ClassMetrics{
+		{Label: 1, Precision: 3.0 / 5, Recall: 3.0 / 4, F1: 2.0 / 3, Support: 4},
+		{Label: 2, Precision: 2.0 / 3, Recall: 2.0 / 3, F1: 2.0 / 3, Support: 3},
+		{Label: 3, Precision: 1, Recall: 1.0 / 2, F1: 2.0 / 3, Support: 2},
+	}
+	for k, c := range m.PerClass() {
+		if c.Label != want[k].Label || c.Support != want[k].Support || !nearSlices([]float64{c.Precision, c.Recall, c.F1},
+			[]float64{want[k].Precision, want[k].Recall, want[k].F1}, 1e-15) {
+			t.Errorf("class %v: %+v, want %+v", c.Label, c, want[k])
+		}
+	}
+
+	if p, r, f := m.Macro(); !nearSlices([]float64{p, r, f}, []float64{(0.6 + 2.0/3 + 1) / 3, (0.75 + 2.0/3 + 0.5) / 3, 2.0 / 3}, 1e-15) {
+		t.Errorf("macro %v %v %v", p, r, f)
+	}
+	if p, r, f := m.Micro(); !nearSlices([]float64{p, r, f}, []float64{6.0 / 9, 6.0 / 9, 6.0 / 9}, 1e-15) {
+		t.Errorf("micro %v %v %v", p, r, f)
+	}
+	if b := m.BalancedAccuracy(); !near(b, (0.75+2.0/3+0.5)/3, 1e-15) {
+		t.Errorf("balanced accuracy %v", b)
+	}
+	if mcc := m.MatthewsCorrelation(); !near(mcc, 23/math.Sqrt(46*52), 1e-15) {
+		t.Errorf("MCC %v", mcc)
+	}
+}
+
+/**
+ * For two labels, the generalized MCC is the usual (TP*TN - FP*FN)/sqrt(...), and it is 0 when a single
+ * label is predicted
+ */
+func TestMatthewsCorrelationBinary(t *testing.T) {
+	m := NewConfusionMatrix()
+	for _, pair := range [][2]float64{{1, 1}, {1, 1}, {1, 1}, {-1, 1}, {1, -1}, {-1, -1}, {-1, -1}} {
+		m.Add(pair[0], pair[1])
+	}
+	if mcc := m.MatthewsCorrelation(); !near(mcc, (3*2-1*1)/math.Sqrt(4*4*3*3), 1e-15) {
+		t.Errorf("MCC %v, want 5/12", mcc)
+	}
+
+	m = NewConfusionMatrix()
+	m.Add(1, 1)
+	m.Add(1, -1)
+	if mcc := m.MatthewsCorrelation(); mcc != 0 {
+		t.Errorf("MCC %v of a constant prediction", mcc)
+	}
+}
+
+/**
+ * The ROC AUC counts tied scores as half, and the PR AUC is the average precision
+ */
+func TestRankingMetrics(t *testing.T) {
+	r := NewRankingMetrics()
+	for _, score := range []float64{0.9, 0.8, 0.4} {
+		r.Add(score, true)
+	}
+	for _, score := range []float64{0.7, 0.4, 0.1} {
+		r.Add(score, false)
+	}
+	if auc := r.ROCAUC(); !near(auc, 7.5/9, 1e-15) {
+		t.Errorf("ROC AUC %v, want %v", auc, 7.5/9)
+	}
+	if auc := r.PRAUC(); !near(auc, (1+1+3.0/5)/3, 1e-15) {
+		t.Errorf("PR AUC %v, want %v", auc, (1+1+3.0/5)/3)
+	}
+
+	positives := NewRankingMetrics()
+	positives.Add(1, true)
+	if !math.IsNaN(positives.ROCAUC()) || positives.PRAUC() != 1 {
+		t.Errorf("ROC AUC %v and PR AUC %v of positives only", positives.ROCAUC(), positives.PRAUC())
+	}
+	negatives := NewRankingMetrics()
+	negatives.Add(1, false)
+	if !math.IsNaN(negatives.ROCAUC()) || !math.IsNaN(negatives.PRAUC()) {
+		t.Errorf("ROC AUC %v and PR AUC %v of negatives only", negatives.ROCAUC(), negatives.PRAUC())
+	}
+}
+
+/**
+ * The log loss clips the estimates at 1e-15, and the AUC of two labels ranks the first one
+ */
+func TestProbabilityMetrics(t *testing.T) {
+	p := NewProbabilityMetrics([]int{1, 2})
+	p.Add([]float64{0.8, 0.2}, 1)
+	p.Add([]float64{0.4, 0.6}, 2)
+	p.Add([]float64{1, 0}, 2)
+
+	want := -(math.Log(0.8) + math.Log(0.6) + math.Log(1e-15)) / 3
+	if logLoss := p.LogLoss(); !near(logLoss, want, 1e-15) {
+		t.Errorf("log loss %v, want %v", logLoss, want)
+	}
+	if auc := p.ROCAUC(); auc != 0.5 {
+		t.Errorf("ROC AUC %v, want 0.5", auc)
+	}
+	if logLoss := NewProbabilityMetrics([]int{1, 2}).LogLoss(); !math.IsNaN(logLoss) {
+		t.Errorf("log loss %v without estimates", logLoss)
+	}
+}
+
+func TestRegressionMetrics(t *testing.T) {
+	s := NewSquareErrorComputer()
+	for _, pair := range [][2]float64{{1, 2}, {2, 2}, {3, 5}} {
+		s.Sum(pair[0], pair[1])
+	}
+	got := []float64{s.MeanSquareError(), s.MeanAbsoluteError(), s.RootMeanSquareError(), s.RSquared(), s.SquareCorrelationCoeff()}
+	want := []float64{5.0 / 3, 1, math.Sqrt(5.0 / 3), 1 - 5.0/6, 81.0 / 108}
+	if !nearSlices(got, want, 1e-15) {
+		t.Errorf("MSE, MAE, RMSE, R2 and SCC %v, want %v", got, want)
+	}
+
+	constant := NewSquareErrorComputer()
+	constant.Sum(1, 2)
+	constant.Sum(3, 2)
+	if r2 := constant.RSquared(); !math.IsNaN(r2) {
+		t.Errorf("R2 %v of constant targets", r2)
+	}
+}
+
+func TestParseMetricNames(t *testing.T) {
+	names, err := ParseMetricNames(" Accuracy,mcc , all")
+	if err != nil || !reflect.DeepEqual(names, []string{"accuracy", "mcc", "all"}) {
+		t.Errorf("%v: %v", names, err)
+	}
+	if _, err := ParseMetricNames("accuracy,foo"); !errors.Is(err, ErrInvalidParameter) || !strings.Contains(err.Error(), "foo") {
+		t.Errorf("unknown metric: %v", err)
+	}
+}
+
+/**
+ * "all" writes the metrics that apply to the evaluation, and a selected metric that cannot be computed is
+ * reported as not available
+ */
+func TestWriteMetrics(t *testing.T) {
+	e := NewEvaluation(EPSILON_SVR, nil)
+	e.Add(1, 2)
+	e.Add(3, 5)
+	var buf bytes.Buffer
+	if err := e.WriteMetrics(&buf, []string{"all"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Mean squared error = 2.5\nSquared correlation coefficient = 1\nMean absolute error = 1.5\n" +
+		"Root mean squared error = 1.58114\nR-squared = -0.111111\n"
+	if buf.String() != want {
+		t.Errorf("regression metrics\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	e = NewEvaluation(C_SVC, []int{1, -1})
+	e.Add(1, 1)
+	e.Add(1, -1)
+	buf.Reset()
+	if err := e.WriteMetrics(&buf, []string{"accuracy", "logloss"}); err != nil {
+		t.Fatal(err)
+	}
+	want = "Accuracy = 50% (1/2)\nlogloss is not available (needs probability estimates)\n"
+	if buf.String() != want {
+		t.Errorf("classification metrics\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	e.AddDecisionValues([]float64{0.5}, 1)
+	e.AddDecisionValues([]float64{-0.5}, -1)
+	buf.Reset()
+	if err := e.WriteMetrics(&buf, []string{"all"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ROC AUC = 1 (decision values)\n") || strings.Contains(buf.String(), "logloss") {
+		t.Errorf("classification metrics\n%s", buf.String())
+	}
+}
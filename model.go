@@ -19,9 +19,10 @@
 package libSvm
 
 import (
+	"context"
 	"fmt"
 	"math"
-	"os"
+	"time"
 )
 
 type Model struct {
@@ -35,25 +36,57 @@ type Model struct {
 	svSpace   []snode
 	svIndices []int
 	svCoef    [][]float64
+	svSquare  []float64 // squared norms of the SVs (RBF kernel only)
+	linearW   []float64 // primal weights of a linear model, feature by feature (nil if predicting with the SVs)
+	nrFeature int       // largest dimension in linearW, which holds dimensions 0 to nrFeature
 	probA     []float64
 	probB     []float64
+	report    *TrainReport
 }
 
 func NewModel(param *Parameter) *Model {
 	return &Model{param: param}
 }
 
-func NewModelFromFile(file string) *Model {
+func NewModelFromFile(file string) (*Model, error) {
 	param := NewParameter()
 	model := NewModel(param)
-	model.ReadModel(file)	
-	return model
+	if err := model.ReadModel(file); err != nil {
+		return nil, err
+	}
+	return model, nil
 }
 
 func (model Model) NrClass() int {
 	return model.nrClass
 }
 
+/**
+ * Returns the class labels of a classification model, in the order of its decision values and
+ * probability estimates, or nil for one-class and regression models
+ */
+func (model Model) Labels() []int {
+	if model.label == nil {
+		return nil
+	}
+	labels := make([]int, len(model.label))
+	copy(labels, model.label)
+	return labels
+}
+
+/**
+ * Returns the index (from 1) of each SV in the problem set the model was trained from, or nil for a
+ * model read from a file
+ */
+func (model Model) SVIndices() []int {
+	if model.svIndices == nil {
+		return nil
+	}
+	indices := make([]int, len(model.svIndices))
+	copy(indices, model.svIndices)
+	return indices
+}
+
 func groupClasses(prob *Problem) (nrClass int, label []int, start []int, count []int, perm []int) {
 	var l int = prob.l
 
@@ -115,7 +148,7 @@ func groupClasses(prob *Problem) (nrClass int, label []int, start []int, count [
 	return // nrClass, label, start, count, perm
 }
 
-func (model *Model) classification(prob *Problem) {
+func (model *Model) classification(ctx context.Context, prob *Problem, warm *warmStart, kept *keptMatrices) error {
 
 	nrClass, label, start, count, perm := groupClasses(prob) // group SV with the same labels together
 
@@ -124,6 +157,13 @@ func (model *Model) classification(prob *Problem) {
 	for i := 0; i < l; i++ {
 		x[i] = prob.x[perm[i]] // this is the new x slice with the grouped SVs
 	}
+	var w []float64 // instance weights in the same order, if any
+	if prob.w != nil {
+		w = make([]float64, l)
+		for i := 0; i < l; i++ {
+			w[i] = prob.w[perm[i]]
+		}
+	}
 
 	weighted_C := make([]float64, nrClass)
 	for i := 0; i < nrClass; i++ {
@@ -137,7 +177,7 @@ func (model *Model) classification(prob *Problem) {
 			}
 		}
 		if j == nrClass {
-			fmt.Fprintf(os.Stderr, "WARNING: class label %d specified in weight is not found\n", model.param.WeightLabel[i])
+			model.param.observer().Warning(fmt.Sprintf("class label %d specified in weight is not found", model.param.WeightLabel[i]))
 		} else {
 			weighted_C[j] = weighted_C[j] * model.param.Weight[i] // multiple with user specified weight for label
 		}
@@ -156,56 +196,97 @@ func (model *Model) classification(prob *Problem) {
 		probB = make([]float64, totalCompares)
 	}
 
-	var p int = 0
+	if kept != nil && kept.q == nil {
+		kept.q = make([]matrixQ, totalCompares)
+	}
+
+	pairs := make([][2]int, 0, totalCompares) // the labels i and j of each subproblem p
 	for i := 0; i < nrClass; i++ {
 		for j := i + 1; j < nrClass; j++ {
-			var subProb Problem
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
 
-			si := start[i] // SV starting from x[si] are related to label i
-			sj := start[j] // SV starting from x[sj] are related to label j
+	// trains the subproblem p between the labels i and j
+	trainPair := func(ctx context.Context, param *Parameter, p int) error {
+		i, j := pairs[p][0], pairs[p][1]
 
-			ci := count[i] // number of SV from x[si] that are related to label i
-			cj := count[j] // number of SV from x[sj] that are related to label j
+		var subProb Problem
 
-			subProb.xSpace = prob.xSpace // inherits the space
-			subProb.l = ci + cj          // focus only on 2 labels
-			subProb.x = make([]int, subProb.l)
-			subProb.y = make([]float64, subProb.l)
-			for k := 0; k < ci; k++ {
-				subProb.x[k] = x[si+k] // starting indices for first label
-				subProb.y[k] = 1
-			}
+		si := start[i] // SV starting from x[si] are related to label i
+		sj := start[j] // SV starting from x[sj] are related to label j
 
-			for k := 0; k < cj; k++ {
-				subProb.x[ci+k] = x[sj+k] // starting indices for second label
-				subProb.y[ci+k] = -1
+		ci := count[i] // number of SV from x[si] that are related to label i
+		cj := count[j] // number of SV from x[sj] that are related to label j
+
+		subProb.xSpace = prob.xSpace // inherits the space
+		subProb.l = ci + cj          // focus only on 2 labels
+		subProb.x = make([]int, subProb.l)
+		subProb.y = make([]float64, subProb.l)
+		for k := 0; k < ci; k++ {
+			subProb.x[k] = x[si+k] // starting indices for first label
+			subProb.y[k] = 1
+		}
+
+		for k := 0; k < cj; k++ {
+			subProb.x[ci+k] = x[sj+k] // starting indices for second label
+			subProb.y[ci+k] = -1
+		}
+
+		if w != nil {
+			subProb.w = make([]float64, subProb.l)
+			copy(subProb.w, w[si:si+ci])
+			copy(subProb.w[ci:], w[sj:sj+cj])
+		}
+
+		if param.Probability {
+			var err error
+			if probA[p], probB[p], err = binarySvcProbability(ctx, &subProb, param, weighted_C[i], weighted_C[j], []int{label[i], label[j]}); err != nil {
+				return err
 			}
+		}
 
-			if model.param.Probability {
-				probA[p], probB[p] = binarySvcProbability(&subProb, model.param, weighted_C[i], weighted_C[j])
+		var seed []float64 // the alphas of prev, with the sign of y
+		if warm != nil {
+			seed = make([]float64, subProb.l)
+			for k := 0; k < ci; k++ {
+				seed[k] = warm.pairAlpha(perm[si+k], label[i], label[j])
 			}
+			for k := 0; k < cj; k++ {
+				seed[ci+k] = -warm.pairAlpha(perm[sj+k], label[j], label[i])
+			}
+		}
 
-			if decision_result, err := train_one(&subProb, model.param, weighted_C[i], weighted_C[j]); err == nil { // no error in training
+		subStart := kept.start(p, seed)
+		decision_result, err := train_one(ctx, &subProb, param, weighted_C[i], weighted_C[j], subStart)
+		if err != nil {
+			return err
+		}
+		kept.done(p, subStart)
+		decisions[p] = decision_result
+		decisions[p].info.Labels = []int{label[i], label[j]}
+		param.observer().SubproblemDone(decisions[p].info)
+		return nil
+	}
 
-				decisions[p] = decision_result
+	if err := runSubproblems(ctx, model.param, totalCompares, trainPair); err != nil {
+		return trainingFailed(err) // no point in continuing
+	}
 
-				for k := 0; k < ci; k++ {
-					if !nonzero[si+k] && math.Abs(decisions[p].alpha[k]) > 0 {
-						nonzero[si+k] = true
-					}
-				}
-				for k := 0; k < cj; k++ {
-					if !nonzero[sj+k] && math.Abs(decisions[p].alpha[ci+k]) > 0 {
-						nonzero[sj+k] = true
-					}
-				}
+	for p := 0; p < totalCompares; p++ {
+		i, j := pairs[p][0], pairs[p][1]
+		si, sj := start[i], start[j]
+		ci, cj := count[i], count[j]
 
-			} else {
-				fmt.Fprintln(os.Stderr, "WARNING: training failed: ", err)
-				return // no point in continuing
+		for k := 0; k < ci; k++ {
+			if !nonzero[si+k] && math.Abs(decisions[p].alpha[k]) > 0 {
+				nonzero[si+k] = true
+			}
+		}
+		for k := 0; k < cj; k++ {
+			if !nonzero[sj+k] && math.Abs(decisions[p].alpha[ci+k]) > 0 {
+				nonzero[sj+k] = true
 			}
-
-			p++
 		}
 	}
 
@@ -241,9 +322,13 @@ func (model *Model) classification(prob *Problem) {
 		nz_count[i] = nSV
 	}
 
-	if !model.param.QuietMode {
-		fmt.Printf("Total nSV = %d\n", totalSV)
+	model.param.observer().TrainingDone(totalSV)
+
+	infos := make([]SubproblemInfo, len(decisions))
+	for i := 0; i < len(decisions); i++ {
+		infos[i] = decisions[i].info
 	}
+	model.report = newTrainReport(infos, totalSV)
 
 	model.l = totalSV
 	model.svSpace = prob.xSpace
@@ -251,7 +336,7 @@ func (model *Model) classification(prob *Problem) {
 	model.sV = make([]int, totalSV)
 	model.svIndices = make([]int, totalSV)
 
-	p = 0
+	var p int = 0
 	for i := 0; i < l; i++ {
 		if nonzero[i] {
 			model.sV[p] = x[i]
@@ -303,20 +388,41 @@ func (model *Model) classification(prob *Problem) {
 		}
 	}
 
+	return nil
 }
 
-func (model *Model) regressionOneClass(prob *Problem) {
-
-	model.nrClass = 2
+func (model *Model) regressionOneClass(ctx context.Context, prob *Problem, warm *warmStart, kept *keptMatrices) error {
 
+	var probA []float64
 	if model.param.Probability &&
 		(model.param.SvmType == EPSILON_SVR || model.param.SvmType == NU_SVR) {
-		model.probA = make([]float64, 1)
-		model.probA[0] = svrProbability(prob, model.param)
+		probA = make([]float64, 1)
+		var err error
+		if probA[0], err = svrProbability(ctx, prob, model.param); err != nil {
+			return trainingFailed(err)
+		}
 	}
 
-	if decision_result, err := train_one(prob, model.param, 0, 0); err == nil { // no error in training
-		model.rho = append(model.rho, decision_result.rho)
+	var seed []float64 // the coefficients of prev
+	if warm != nil {
+		seed = make([]float64, prob.l)
+		for i := 0; i < prob.l; i++ {
+			seed[i] = warm.coef(i)
+		}
+	}
+
+	if kept != nil && kept.q == nil {
+		kept.q = make([]matrixQ, 1)
+	}
+
+	subStart := kept.start(0, seed)
+	if decision_result, err := train_one(ctx, prob, model.param, 0, 0, subStart); err == nil { // no error in training
+		kept.done(0, subStart)
+		model.param.observer().SubproblemDone(decision_result.info)
+
+		model.nrClass = 2
+		model.probA = probA
+		model.rho = []float64{decision_result.rho}
 
 		var nSV int = 0
 		for i := 0; i < prob.l; i++ {
@@ -341,18 +447,75 @@ func (model *Model) regressionOneClass(prob *Problem) {
 				j++
 			}
 		}
+
+		model.param.observer().TrainingDone(nSV)
+		model.report = newTrainReport([]SubproblemInfo{decision_result.info}, nSV)
 	} else {
-		fmt.Fprintln(os.Stderr, "WARNING: training failed: ", err)
+		return trainingFailed(err)
 	}
+
+	return nil
 }
 
+/**
+ * Trains the model from the problem set.  Same as TrainContext with a context that is never cancelled.
+ */
 func (model *Model) Train(prob *Problem) error {
+	return model.TrainContext(context.Background(), prob)
+}
+
+/**
+ * Trains the model from the problem set after checking the parameters with Parameter.Validate, and
+ * stops early with a TrainingStoppedError when ctx is cancelled or its deadline passes, or when
+ * Parameter.MaxDuration has elapsed.
+ */
+func (model *Model) TrainContext(ctx context.Context, prob *Problem) error {
+	if err := model.param.Validate(prob); err != nil {
+		return err
+	}
+	if model.param.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, model.param.MaxDuration)
+		defer cancel()
+	}
+	return model.train(ctx, prob)
+}
+
+func (model *Model) train(ctx context.Context, prob *Problem) error {
+	return model.trainFrom(ctx, prob, nil, nil)
+}
+
+/**
+ * Trains the model, warm starting the subproblems from warm if it is not nil, and reusing the Q matrices
+ * of kept if it is not nil (keeping those built by this training in it)
+ */
+func (model *Model) trainFrom(ctx context.Context, prob *Problem, warm *warmStart, kept *keptMatrices) error {
+	startTime := time.Now()
+	model.report = nil
+
+	userParam := model.param
+	defer func() { model.param = userParam }()
+	if model.param.kernels == nil && sharesKernels(prob, model.param) {
+		model.param = withKernelCache(prob, model.param)
+	}
+	var closePool func()
+	model.param, closePool = withWorkerPool(model.param)
+	defer closePool()
+
+	var err error
 	switch model.param.SvmType {
 	case C_SVC, NU_SVC:
-		model.classification(prob)
+		err = model.classification(ctx, prob, warm, kept)
 	case ONE_CLASS, EPSILON_SVR, NU_SVR:
-		model.regressionOneClass(prob)
+		err = model.regressionOneClass(ctx, prob, warm, kept)
 	}
-	return nil
-}
 
+	if err == nil {
+		model.computeSVSquare()
+		model.computeLinearWeights()
+	}
+	if model.report != nil {
+		model.report.Duration = time.Since(startTime)
+	}
+	return err
+}
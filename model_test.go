@@ -0,0 +1,151 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of stopping the training early
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/**
+ * Cancels the training at the first solver iteration tick, or at the first probability calibration fold
+ */
+type cancellingObserver struct {
+	NopObserver
+	cancel      context.CancelFunc
+	probability bool // cancel at the probability calibration instead
+}
+
+func (o *cancellingObserver) Iteration(iter int, maxViolation float64) {
+	if !o.probability {
+		o.cancel()
+	}
+}
+
+func (o *cancellingObserver) ProbabilityProgress(info ProbabilityInfo) {
+	if o.probability {
+		o.cancel()
+	}
+}
+
+func isStopped(err error, cause error) bool {
+	var stopped *TrainingStoppedError
+	return errors.As(err, &stopped) && errors.Is(err, cause)
+}
+
+/**
+ * The training stops with a TrainingStoppedError when its context is cancelled before it starts,
+ * in the solver loop, or in the probability calibration, for every svm type and solver
+ */
+func TestTrainContextCancelled(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		prob := mustProblem(t, problemText(svmType), param)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := NewModel(param).TrainContext(ctx, prob); !isStopped(err, context.Canceled) {
+			t.Errorf("svm type %d, cancelled before training: %v", svmType, err)
+		}
+
+		for _, probability := range []bool{false, true} {
+			if probability && svmType == ONE_CLASS {
+				continue // no probability model
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			param := quietParameter(svmType)
+			param.Probability = probability
+			param.Observer = &cancellingObserver{cancel: cancel, probability: probability}
+			param.C, param.Nu = 100, 0.1 // enough iterations for the solvers to tick
+			text := blobsText(1, 600, 4, 3)
+			switch svmType {
+			case ONE_CLASS:
+				param.Gamma = 10
+			case EPSILON_SVR, NU_SVR:
+				text = regressionText(1, 600, 4)
+			}
+			err := NewModel(param).TrainContext(ctx, mustProblem(t, text, param))
+			if !isStopped(err, context.Canceled) {
+				t.Errorf("svm type %d, cancelled during training (probability %v): %v", svmType, probability, err)
+			}
+			cancel()
+		}
+	}
+
+	for _, solver := range []int{L2R_L2LOSS_SVC_DUAL, L2R_L2LOSS_SVR_DUAL} {
+		param := quietParameter(C_SVC)
+		if solver == L2R_L2LOSS_SVR_DUAL {
+			param.SvmType = EPSILON_SVR
+		}
+		param.KernelType = LINEAR
+		param.Solver = solver
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := NewModel(param).TrainContext(ctx, mustProblem(t, problemText(param.SvmType), param)); !isStopped(err, context.Canceled) {
+			t.Errorf("solver %d cancelled: %v", solver, err)
+		}
+	}
+}
+
+/**
+ * The training stops with a TrainingStoppedError once Parameter.MaxDuration has elapsed
+ */
+func TestTrainMaxDuration(t *testing.T) {
+	param := quietParameter(C_SVC)
+	param.MaxDuration = time.Nanosecond
+	prob := mustProblem(t, problemText(C_SVC), param)
+	if err := NewModel(param).Train(prob); !isStopped(err, context.DeadlineExceeded) {
+		t.Errorf("MaxDuration %v: %v", param.MaxDuration, err)
+	}
+
+	param.MaxDuration = time.Hour
+	if err := NewModel(param).Train(prob); err != nil {
+		t.Errorf("MaxDuration %v: %v", param.MaxDuration, err)
+	}
+}
+
+/**
+ * The solver of each subproblem stops after Parameter.MaxIter iterations, and the model is still trained
+ */
+func TestTrainMaxIter(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.MaxIter = 5
+		model := mustTrain(t, mustProblem(t, problemText(svmType), param), param)
+		report := model.Report()
+		if !report.MaxIterReached {
+			t.Errorf("svm type %d: the maximum number of iterations is not reported", svmType)
+		}
+		for _, info := range report.Subproblems {
+			if !info.MaxIterReached || info.Iterations != param.MaxIter {
+				t.Errorf("svm type %d: %d iterations (max reached %v), want %d", svmType, info.Iterations, info.MaxIterReached, param.MaxIter)
+			}
+		}
+		if model.l == 0 {
+			t.Errorf("svm type %d: no SVs", svmType)
+		}
+
+		param.MaxIter = 0
+		if report := mustTrain(t, mustProblem(t, problemText(svmType), param), param).Report(); report.MaxIterReached {
+			t.Errorf("svm type %d: the maximum number of iterations is reached by default", svmType)
+		}
+	}
+}
@@ -21,122 +21,182 @@ package libSvm
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 )
 
+/**
+ * Writes the model to the specified file in the LIBSVM model format
+ */
 func (model *Model) Dump(file string) error {
 	f, err := os.Create(file)
 	if err != nil {
-		return fmt.Errorf("Fail to open file %s\n", file)
+		return fmt.Errorf("Fail to open file %s: %w", file, err)
 	}
 
-	defer f.Close() // close f on method return
+	if _, err = model.WriteTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Fail to write file %s: %w", file, err)
+	}
+
+	return f.Close()
+}
 
-	var output []string
+/**
+ * Writes the model to w in the LIBSVM model format, and returns the number of bytes written
+ */
+func (model *Model) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	output := bufio.NewWriter(cw) // keeps the first write error, which Flush returns
 
 	//svm_type_string := [5]string{"c_svc", "nu_svc", "one_class", "epsilon_svr", "nu_svr"}
-	output = append(output, fmt.Sprintf("svm_type %s\n", svm_type_string[model.param.SvmType]))
+	fmt.Fprintf(output, "svm_type %s\n", svm_type_string[model.param.SvmType])
 
-	output = append(output, fmt.Sprintf("kernel_type %s\n", kernel_type_string[model.param.KernelType]))
+	fmt.Fprintf(output, "kernel_type %s\n", kernel_type_string[model.param.KernelType])
 
 	if model.param.KernelType == POLY {
-		output = append(output, fmt.Sprintf("degree %d\n", model.param.Degree))
+		fmt.Fprintf(output, "degree %d\n", model.param.Degree)
 	}
 
 	if model.param.KernelType == POLY || model.param.KernelType == RBF || model.param.KernelType == SIGMOID {
-		output = append(output, fmt.Sprintf("gamma %.6g\n", model.param.Gamma))
+		fmt.Fprintf(output, "gamma %.6g\n", model.param.Gamma)
 	}
 
 	if model.param.KernelType == POLY || model.param.KernelType == SIGMOID {
-		output = append(output, fmt.Sprintf("coef0 %.6g\n", model.param.Coef0))
+		fmt.Fprintf(output, "coef0 %.6g\n", model.param.Coef0)
 	}
 
 	var nrClass int = model.nrClass
-	output = append(output, fmt.Sprintf("nr_class %d\n", nrClass))
+	fmt.Fprintf(output, "nr_class %d\n", nrClass)
+
+	// a compact linear model has only the primal weights, and no SVs
+	compact := model.param.KernelType == LINEAR && (model.param.CompactLinear || (model.l == 0 && model.linearW != nil))
 
 	var l int = model.l
-	output = append(output, fmt.Sprintf("total_sv %d\n", l))
+	if compact {
+		l = 0
+	}
+	fmt.Fprintf(output, "total_sv %d\n", l)
 
-	output = append(output, "rho")
+	output.WriteString("rho")
 	total_models := nrClass * (nrClass - 1) / 2
 	for i := 0; i < total_models; i++ {
-		output = append(output, fmt.Sprintf(" %.6g", model.rho[i]))
+		fmt.Fprintf(output, " %.6g", model.rho[i])
 	}
-	output = append(output, "\n")
+	output.WriteString("\n")
 
 	if len(model.label) > 0 {
-		output = append(output, "label")
+		output.WriteString("label")
 		for i := 0; i < nrClass; i++ {
-			output = append(output, fmt.Sprintf(" %d", model.label[i]))
+			fmt.Fprintf(output, " %d", model.label[i])
 		}
-		output = append(output, "\n")
+		output.WriteString("\n")
 	}
 
 	if len(model.probA) > 0 {
-		output = append(output, "probA")
+		output.WriteString("probA")
 		for i := 0; i < total_models; i++ {
-			output = append(output, fmt.Sprintf(" %.8g", model.probA[i]))
+			fmt.Fprintf(output, " %.8g", model.probA[i])
 		}
-		output = append(output, "\n")
+		output.WriteString("\n")
 	}
 
 	if len(model.probB) > 0 {
-		output = append(output, "probB")
+		output.WriteString("probB")
 		for i := 0; i < total_models; i++ {
-			output = append(output, fmt.Sprintf(" %.8g", model.probB[i]))
+			fmt.Fprintf(output, " %.8g", model.probB[i])
 		}
-		output = append(output, "\n")
+		output.WriteString("\n")
+	}
+
+	if compact {
+		w, nrFeature := model.linearW, model.nrFeature
+		if w == nil {
+			w, nrFeature = model.primalWeights()
+		}
+		nrW := model.nrDecisionValues()
+
+		fmt.Fprintf(output, "nr_feature %d\n", nrFeature)
+		var w0 bool = false // the weights of dimension 0 are only written when the SVs have it
+		for p := 0; p < nrW; p++ {
+			w0 = w0 || w[p] != 0
+		}
+		if w0 {
+			output.WriteString("w0")
+			for p := 0; p < nrW; p++ {
+				fmt.Fprintf(output, " %.16g", w[p])
+			}
+			output.WriteString("\n")
+		}
+		output.WriteString("W\n")
+		for k := 1; k <= nrFeature; k++ {
+			for p := 0; p < nrW; p++ {
+				fmt.Fprintf(output, "%.16g ", w[k*nrW+p])
+			}
+			output.WriteString("\n")
+		}
+
+		err := output.Flush()
+		return cw.n, err
 	}
 
 	if len(model.nSV) > 0 {
-		output = append(output, "nr_sv")
+		output.WriteString("nr_sv")
 		for i := 0; i < nrClass; i++ {
-			output = append(output, fmt.Sprintf(" %d", model.nSV[i]))
+			fmt.Fprintf(output, " %d", model.nSV[i])
 		}
-		output = append(output, "\n")
+		output.WriteString("\n")
 	}
 
-	output = append(output, "SV\n")
+	output.WriteString("SV\n")
 
 	for i := 0; i < l; i++ {
 		for j := 0; j < nrClass-1; j++ {
-			output = append(output, fmt.Sprintf("%.16g ", model.svCoef[j][i]))
+			fmt.Fprintf(output, "%.16g ", model.svCoef[j][i])
 		}
 
 		i_idx := model.sV[i]
 		if model.param.KernelType == PRECOMPUTED {
-			output = append(output, fmt.Sprintf("0:%d ", model.svSpace[i_idx]))
+			fmt.Fprintf(output, "0:%d ", int(model.svSpace[i_idx].value)) // only the serial number is needed
 		} else {
 			for model.svSpace[i_idx].index != -1 {
 				index := model.svSpace[i_idx].index
 				value := model.svSpace[i_idx].value
-				output = append(output, fmt.Sprintf("%d:%.8g ", index, value))
+				fmt.Fprintf(output, "%d:%.8g ", index, value)
 				i_idx++
 			}
-			output = append(output, "\n")
 		}
+		output.WriteString("\n")
 	}
 
-	f.WriteString(strings.Join(output, ""))
-
-	return nil
+	err := output.Flush()
+	return cw.n, err
 }
 
-func (model *Model) readHeader(reader *bufio.Reader) error {
+func (model *Model) readHeader(reader *lineReader) error {
+	var w0 []float64 // weights of dimension 0 of a compact linear model
 
 	for {
 		var i int = 0
 		var err error
 		var line string
 
-		line, err = readline(reader)
-		if err != nil { // We should not encounter an EOF.  If we do, it is an error.
+		line, err = reader.next()
+		if err == io.EOF { // We should not encounter an EOF.  If we do, it is an error.
+			return newParseError(reader.line, 0, nil, "Fail to completely read header")
+		} else if err != nil {
 			return err
 		}
 
-		tokens := strings.Fields(line)
+		tokens, columns := splitFields(line)
+		if len(tokens) == 0 {
+			return newParseError(reader.line, 0, nil, "Empty line in model header")
+		}
+		if len(tokens) < 2 && tokens[0] != "SV" && tokens[0] != "W" {
+			return newParseError(reader.line, columns[0], nil, "Missing value for %s", tokens[0])
+		}
 
 		switch tokens[0] {
 		case "svm_type":
@@ -149,7 +209,7 @@ func (model *Model) readHeader(reader *bufio.Reader) error {
 			}
 
 			if i == len(svm_type_string) {
-				return fmt.Errorf("fail to parse svm model %s\n", tokens[1])
+				return newParseError(reader.line, columns[1], nil, "fail to parse svm model %s", tokens[1])
 			}
 
 		case "kernel_type":
@@ -162,63 +222,63 @@ func (model *Model) readHeader(reader *bufio.Reader) error {
 			}
 
 			if i == len(kernel_type_string) {
-				return fmt.Errorf("fail to parse kernel type %s\n", tokens[1])
+				return newParseError(reader.line, columns[1], nil, "fail to parse kernel type %s", tokens[1])
 			}
 
 		case "degree":
 
 			if model.param.Degree, err = strconv.Atoi(tokens[1]); err != nil {
-				return err
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
 			}
 
 		case "gamma":
 
 			if model.param.Gamma, err = strconv.ParseFloat(tokens[1], 64); err != nil {
-				return err
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
 			}
 
 		case "coef0":
 
 			if model.param.Coef0, err = strconv.ParseFloat(tokens[1], 64); err != nil {
-				return err
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
 			}
 
 		case "nr_class":
 
 			if model.nrClass, err = strconv.Atoi(tokens[1]); err != nil {
-				return err
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
 			}
 
 		case "total_sv":
 
 			if model.l, err = strconv.Atoi(tokens[1]); err != nil {
-				return err
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
 			}
 
 		case "rho":
 
 			total_class_comparisons := model.nrClass * (model.nrClass - 1) / 2
 			if total_class_comparisons != len(tokens)-1 {
-				return fmt.Errorf("Number of rhos %d does not mactch the required number %d\n", len(tokens)-1, total_class_comparisons)
+				return newParseError(reader.line, 0, nil, "Number of rhos %d does not mactch the required number %d", len(tokens)-1, total_class_comparisons)
 			}
 
 			model.rho = make([]float64, total_class_comparisons)
 			for i = 0; i < total_class_comparisons; i++ {
 				if model.rho[i], err = strconv.ParseFloat(tokens[i+1], 64); err != nil {
-					return err
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
 				}
 			}
 
 		case "label":
 
 			if model.nrClass != len(tokens)-1 {
-				return fmt.Errorf("Number of labels %d does not appear in the file\n", model.nrClass)
+				return newParseError(reader.line, 0, nil, "Number of labels %d does not appear in the file", model.nrClass)
 			}
 
 			model.label = make([]int, model.nrClass)
 			for i = 0; i < model.nrClass; i++ {
 				if model.label[i], err = strconv.Atoi(tokens[i+1]); err != nil {
-					return err
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
 				}
 			}
 
@@ -226,13 +286,13 @@ func (model *Model) readHeader(reader *bufio.Reader) error {
 
 			total_class_comparisons := model.nrClass * (model.nrClass - 1) / 2
 			if total_class_comparisons != len(tokens)-1 {
-				return fmt.Errorf("Number of probA %d does not mactch the required number %d\n", len(tokens)-1, total_class_comparisons)
+				return newParseError(reader.line, 0, nil, "Number of probA %d does not mactch the required number %d", len(tokens)-1, total_class_comparisons)
 			}
 
 			model.probA = make([]float64, total_class_comparisons)
 			for i = 0; i < total_class_comparisons; i++ {
 				if model.probA[i], err = strconv.ParseFloat(tokens[i+1], 64); err != nil {
-					return err
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
 				}
 			}
 
@@ -240,54 +300,106 @@ func (model *Model) readHeader(reader *bufio.Reader) error {
 
 			total_class_comparisons := model.nrClass * (model.nrClass - 1) / 2
 			if total_class_comparisons != len(tokens)-1 {
-				return fmt.Errorf("Number of probB %d does not mactch the required number %d\n", len(tokens)-1, total_class_comparisons)
+				return newParseError(reader.line, 0, nil, "Number of probB %d does not mactch the required number %d", len(tokens)-1, total_class_comparisons)
 			}
 
 			model.probB = make([]float64, total_class_comparisons)
 			for i = 0; i < total_class_comparisons; i++ {
 				if model.probB[i], err = strconv.ParseFloat(tokens[i+1], 64); err != nil {
-					return err
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
 				}
 			}
 
 		case "nr_sv":
 
 			if model.nrClass != len(tokens)-1 {
-				return fmt.Errorf("Number of nSV %d does not appear in the file %v\n", model.nrClass, tokens)
+				return newParseError(reader.line, 0, nil, "Number of nSV %d does not appear in the file %v", model.nrClass, tokens)
 			}
 
 			model.nSV = make([]int, model.nrClass)
 			for i = 0; i < model.nrClass; i++ {
 				if model.nSV[i], err = strconv.Atoi(tokens[i+1]); err != nil {
-					return err
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
 				}
 			}
 
+		case "nr_feature":
+
+			if model.nrFeature, err = strconv.Atoi(tokens[1]); err != nil || model.nrFeature < 0 {
+				return newParseError(reader.line, columns[1], err, "Fail to parse %s", tokens[0])
+			}
+
+		case "w0":
+
+			w0 = make([]float64, len(tokens)-1)
+			for i = 0; i < len(w0); i++ {
+				if w0[i], err = strconv.ParseFloat(tokens[i+1], 64); err != nil {
+					return newParseError(reader.line, columns[i+1], err, "Fail to parse %s", tokens[0])
+				}
+			}
+
+		case "W":
+			if model.param.KernelType != LINEAR {
+				return newParseError(reader.line, columns[0], nil, "Primal weights in a model with a %s kernel", kernel_type_string[model.param.KernelType])
+			}
+			nrW := model.nrDecisionValues()
+			if w0 != nil && len(w0) != nrW {
+				return newParseError(reader.line, 0, nil, "Expected %d weights for dimension 0", nrW)
+			}
+			model.linearW = make([]float64, (model.nrFeature+1)*nrW)
+			copy(model.linearW, w0)
+			return nil // done reading the header of a compact linear model
+
 		case "SV":
 			return nil // done reading the header!
 		default:
-			return fmt.Errorf("unknown text in model file: [%s]\n", tokens[0])
+			return newParseError(reader.line, columns[0], nil, "unknown text in model file: [%s]", tokens[0])
 
 		}
 	}
-
-	return fmt.Errorf("Fail to completely read header")
 }
 
+/**
+ * Reads the model from the specified file in the LIBSVM model format
+ */
 func (model *Model) ReadModel(file string) error {
 	f, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("Fail to open file %s\n", file)
+		return fmt.Errorf("Fail to open file %s: %w", file, err)
 	}
 
 	defer f.Close() // close f on method return
 
-	reader := bufio.NewReader(f)
+	return withFileName(model.readFrom(f), file)
+}
+
+/**
+ * Reads a model in the LIBSVM model format from r.  Gzip compressed data is detected and decompressed.
+ */
+func ReadModelFrom(r io.Reader) (*Model, error) {
+	model := NewModel(NewParameter())
+	if err := model.readFrom(r); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
 
+func (model *Model) readFrom(r io.Reader) error {
+	reader, err := newLineReader(r)
+	if err != nil {
+		return err
+	}
+
+	model.linearW = nil
+	model.nrFeature = 0
 	if err := model.readHeader(reader); err != nil {
 		return err
 	}
 
+	if model.linearW != nil {
+		return model.readLinearWeights(reader)
+	}
+
 	var l int = model.l           // read l from header
 	var m int = model.nrClass - 1 // read nrClass from header
 	model.svCoef = make([][]float64, m)
@@ -296,40 +408,45 @@ func (model *Model) ReadModel(file string) error {
 	}
 
 	model.sV = make([]int, l)
+	model.svSpace = nil
 	var i int = 0
 	for {
-		line, err := readline(reader) // read a line
-		if err != nil {
+		line, err := reader.next() // read a line
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			return err
 		}
 
-		tokens := strings.Fields(line) // get all the word tokens (seperated by white spaces)
-		if len(tokens) < 2 {           // there should be at least 2 fields -- label + SV
+		tokens, columns := splitFields(line) // get all the word tokens (seperated by white spaces)
+		if len(tokens) < 2 {                 // there should be at least 2 fields -- label + SV
 			continue
 		}
 		if i >= l {
-			return fmt.Errorf("Error in reading support vectors.  i=%d and l=%d\n", i, l)
+			return newParseError(reader.line, 0, nil, "Error in reading support vectors.  i=%d and l=%d", i, l)
 		}
 
 		model.sV[i] = len(model.svSpace) // starting index into svSpace for this SV
 
 		var k int = 0
-		for _, token := range tokens {
+		for t, token := range tokens {
 			if k < m {
-				model.svCoef[k][i], err = strconv.ParseFloat(token, 64)
+				if model.svCoef[k][i], err = strconv.ParseFloat(token, 64); err != nil {
+					return newParseError(reader.line, columns[t], err, "Fail to parse svCoef from token %v", token)
+				}
 				k++
 			} else {
 				node := strings.Split(token, ":")
 				if len(node) < 2 {
-					return fmt.Errorf("Fail to parse svSpace from token %v\n", token)
+					return newParseError(reader.line, columns[t], nil, "Fail to parse svSpace from token %v", token)
 				}
 				var index int
 				var value float64
 				if index, err = strconv.Atoi(node[0]); err != nil {
-					return fmt.Errorf("Fail to parse index from token %v\n", token)
+					return newParseError(reader.line, columns[t], err, "Fail to parse index from token %v", token)
 				}
 				if value, err = strconv.ParseFloat(node[1], 64); err != nil {
-					return fmt.Errorf("Fail to parse value from token %v\n", token)
+					return newParseError(reader.line, columns[t]+len(node[0])+1, err, "Fail to parse value from token %v", token)
 				}
 				model.svSpace = append(model.svSpace, snode{index: index, value: value})
 			}
@@ -338,5 +455,46 @@ func (model *Model) ReadModel(file string) error {
 		i++
 	}
 
+	if i < l {
+		return newParseError(reader.line, 0, nil, "Only %d of %d support vectors in the model file", i, l)
+	}
+
+	model.computeSVSquare()
+	model.computeLinearWeights()
+	return nil
+}
+
+/**
+ * Reads the primal weights of a compact linear model: one line per feature from dimension 1, with the weight of
+ * each decision function (the weights of dimension 0 are in the header)
+ */
+func (model *Model) readLinearWeights(reader *lineReader) error {
+	nrW := model.nrDecisionValues()
+	model.l = 0
+	model.sV = nil
+	model.svSpace = nil
+	model.svCoef = nil
+	model.nSV = nil
+	model.svSquare = nil
+
+	for k := 1; k <= model.nrFeature; k++ {
+		line, err := reader.next()
+		if err == io.EOF {
+			return newParseError(reader.line, 0, nil, "Only %d of %d features in the model file", k-1, model.nrFeature)
+		} else if err != nil {
+			return err
+		}
+
+		tokens, columns := splitFields(line)
+		if len(tokens) != nrW {
+			return newParseError(reader.line, 0, nil, "Expected %d weights for feature %d", nrW, k)
+		}
+		for p, token := range tokens {
+			if model.linearW[k*nrW+p], err = strconv.ParseFloat(token, 64); err != nil {
+				return newParseError(reader.line, columns[p], err, "Fail to parse weight from token %v", token)
+			}
+		}
+	}
+
 	return nil
 }
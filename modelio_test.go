@@ -0,0 +1,175 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of reading and writing models and problem sets
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func modelText(t *testing.T, model *Model) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := model.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo wrote %d bytes of %d: %v", n, buf.Len(), err)
+	}
+	return buf.String()
+}
+
+func gzipText(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+/**
+ * A model written and read back, from a writer, a file or a gzip compressed file, is written the same
+ * and predicts the same, for every svm type
+ */
+func TestModelRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Probability = svmType != ONE_CLASS
+		prob := mustProblem(t, problemText(svmType), param)
+		model := mustTrain(t, prob, param)
+		text := modelText(t, model)
+
+		file := filepath.Join(dir, "model")
+		if err := model.Dump(file); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file+".gz", gzipText(t, text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var reads []*Model
+		read, err := ReadModelFrom(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("svm type %d: ReadModelFrom: %v", svmType, err)
+		}
+		reads = append(reads, read)
+		for _, name := range []string{file, file + ".gz"} {
+			read, err := NewModelFromFile(name)
+			if err != nil {
+				t.Fatalf("svm type %d: NewModelFromFile(%s): %v", svmType, name, err)
+			}
+			reads = append(reads, read)
+		}
+
+		for k, read := range reads {
+			if got := modelText(t, read); got != text {
+				t.Fatalf("svm type %d, read %d: written as\n%s\nwant\n%s", svmType, k, got, text)
+			}
+			for prob.Begin(); !prob.Done(); prob.Next() {
+				_, x := prob.GetLine()
+				_, wantValues := model.PredictValues(x)
+				_, gotValues := read.PredictValues(x)
+				if !nearSlices(gotValues, wantValues, 1e-4) { // rho is written with 6 significant digits
+					t.Fatalf("svm type %d, read %d: decision values %v, want %v", svmType, k, gotValues, wantValues)
+				}
+				if param.Probability {
+					_, wantEstimates := model.PredictProbability(x)
+					_, gotEstimates := read.PredictProbability(x)
+					if !nearSlices(gotEstimates, wantEstimates, 1e-4) {
+						t.Fatalf("svm type %d, read %d: estimates %v, want %v", svmType, k, gotEstimates, wantEstimates)
+					}
+				}
+			}
+		}
+	}
+}
+
+/**
+ * A problem set written and read back, from a reader or a gzip compressed file, is the same, with its
+ * instance weights
+ */
+func TestProblemRoundTrip(t *testing.T) {
+	for _, weighted := range []bool{false, true} {
+		prob := mustProblem(t, blobsText(1, 50, 4, 3)+"1\n-1 7:1e-05\n", nil)
+		if weighted {
+			weights := make([]float64, prob.l)
+			for i := range weights {
+				weights[i] = 1 / float64(i+1)
+			}
+			if err := prob.SetWeights(weights); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		n, err := prob.WriteTo(&buf)
+		if err != nil || n != int64(buf.Len()) {
+			t.Fatalf("WriteTo wrote %d bytes of %d: %v", n, buf.Len(), err)
+		}
+		text := buf.String()
+
+		var read Problem
+		if n, err := read.ReadFrom(strings.NewReader(text)); err != nil || n != int64(len(text)) {
+			t.Fatalf("ReadFrom read %d bytes of %d: %v", n, len(text), err)
+		}
+		if !sameProblem(&read, prob) {
+			t.Errorf("weighted %v: read back %+v, want %+v", weighted, read, *prob)
+		}
+
+		file := filepath.Join(t.TempDir(), "train.gz")
+		if err := os.WriteFile(file, gzipText(t, text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		param := NewParameter()
+		gz, err := NewProblem(file, param)
+		if err != nil {
+			t.Fatalf("NewProblem(%s): %v", file, err)
+		}
+		if !sameProblem(gz, prob) || param.Gamma != 1.0/7 {
+			t.Errorf("weighted %v: read back %+v from %s with gamma %v, want %+v", weighted, *gz, file, param.Gamma, *prob)
+		}
+	}
+}
+
+/**
+ * A problem set read without a parameter gets its default gamma and precomputed kernel check at
+ * validation time
+ */
+func TestProblemReadFromValidate(t *testing.T) {
+	var prob Problem
+	if _, err := prob.ReadFrom(strings.NewReader("1 1:1 4:2\n-1 2:1\n")); err != nil {
+		t.Fatal(err)
+	}
+	param := quietParameter(C_SVC)
+	if err := param.Validate(&prob); err != nil || param.Gamma != 0.25 {
+		t.Errorf("gamma %v after Validate: %v", param.Gamma, err)
+	}
+	param.KernelType = PRECOMPUTED
+	if err := param.Validate(&prob); !isParseError(err, 1, 0) {
+		t.Errorf("precomputed kernel: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Observers for the training progress
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+/**
+ * Describes a solved subproblem: a binary classification problem between a pair of labels,
+ * or the single regression or one-class problem.
+ */
+type SubproblemInfo struct {
+	SvmType        int
+	Labels         []int   // the pair of class labels (classification only)
+	Iterations     int     // number of solver iterations
+	MaxIterReached bool    // the solver stopped at the maximum number of iterations
+	Obj            float64 // objective value of the dual problem
+	Rho            float64
+	NSV            int     // number of support vectors
+	NBSV           int     // number of bounded support vectors
+	Cp, Cn         float64 // upper bounds of the positive and negative alphas
+	Nu             float64 // resulting nu for C-SVC (when Cp == Cn) and epsilon-SVR
+	C              float64 // resulting C for nu-SVC
+	Epsilon        float64 // resulting epsilon for nu-SVR
+
+	Duration          time.Duration // wall time spent solving
+	KernelEvaluations int           // number of kernel evaluations
+	CacheHits         int           // Q matrix rows found in the kernel cache
+	CacheMisses       int           // Q matrix rows (or parts of rows) that had to be computed
+	CacheEvictions    int           // Q matrix rows evicted from the kernel cache
+	SharedCacheHits   int           // kernel values found in the cache shared by the subproblems of the training
+}
+
+/**
+ * Describes the progress of the probability calibration, which trains NrFold cross-validation
+ * models before fitting the probability model.
+ */
+type ProbabilityInfo struct {
+	Labels []int   // the pair of class labels being calibrated (classification only)
+	Fold   int     // fold about to be trained, or NrFold once the probability model is fitted
+	NrFold int     // number of cross-validation folds
+	A, B   float64 // fitted sigmoid parameters (classification only)
+	Sigma  float64 // fitted Laplace distribution parameter (regression only)
+}
+
+/**
+ * Receives the training progress.  Subproblems that are trained for the probability calibration
+ * are reported too, following the ProbabilityProgress call for their fold.
+ */
+type TrainingObserver interface {
+	Iteration(iter int, maxViolation float64) // called every min(l,1000) solver iterations
+	Unshrink(iter int)                        // the solver checks all variables again after shrinking
+	SubproblemDone(info SubproblemInfo)       // a subproblem has been solved
+	ProbabilityProgress(info ProbabilityInfo) // a probability calibration fold is starting, or it is finished
+	TrainingDone(totalSV int)                 // all subproblems have been solved
+	Warning(msg string)
+}
+
+/**
+ * Ignores the training progress.  Embed it to implement only some of the TrainingObserver methods.
+ */
+type NopObserver struct{}
+
+func (NopObserver) Iteration(iter int, maxViolation float64) {}
+func (NopObserver) Unshrink(iter int)                        {}
+func (NopObserver) SubproblemDone(info SubproblemInfo)       {}
+func (NopObserver) ProbabilityProgress(info ProbabilityInfo) {}
+func (NopObserver) TrainingDone(totalSV int)                 {}
+func (NopObserver) Warning(msg string)                       {}
+
+/**
+ * Prints the training progress to standard output, like LIBSVM's svm-train.  This is the
+ * default observer when Parameter.Observer is nil and Parameter.QuietMode is not set.
+ */
+type ConsoleObserver struct{}
+
+func (ConsoleObserver) Iteration(iter int, maxViolation float64) {
+	fmt.Print(".")
+}
+
+func (ConsoleObserver) Unshrink(iter int) {
+	fmt.Print("*")
+}
+
+func (ConsoleObserver) SubproblemDone(info SubproblemInfo) {
+	if info.MaxIterReached {
+		fmt.Print("\nWARNING: reaching max number of iterations\n")
+	}
+	fmt.Printf("\noptimization finished, #iter = %d\n", info.Iterations)
+
+	switch info.SvmType {
+	case C_SVC:
+		if info.Cp == info.Cn {
+			fmt.Printf("nu = %f\n", info.Nu)
+		}
+	case NU_SVC:
+		fmt.Printf("C = %v\n", info.C)
+	case EPSILON_SVR:
+		fmt.Printf("nu = %v\n", info.Nu)
+	case NU_SVR:
+		fmt.Printf("epsilon = %f\n", info.Epsilon)
+	}
+
+	fmt.Printf("obj = %f, rho = %f\n", info.Obj, info.Rho)
+	fmt.Printf("nSV = %d, nBSV = %d\n", info.NSV, info.NBSV)
+}
+
+func (ConsoleObserver) ProbabilityProgress(info ProbabilityInfo) {
+	if info.Fold == info.NrFold && info.Labels == nil {
+		fmt.Printf("Prob. model for test data: target value = predicted value + z,\nz: Laplace distribution e^(-|z|/sigma)/(2sigma),sigma= %g\n", info.Sigma)
+	}
+}
+
+func (ConsoleObserver) TrainingDone(totalSV int) {
+	fmt.Printf("Total nSV = %d\n", totalSV)
+}
+
+func (ConsoleObserver) Warning(msg string) {
+	fmt.Printf("\nWARNING: %s\n", msg)
+}
+
+/**
+ * Returns the observer for the training progress
+ */
+func (param *Parameter) observer() TrainingObserver {
+	if param.Observer != nil {
+		return param.Observer
+	}
+	if param.QuietMode {
+		return NopObserver{}
+	}
+	return ConsoleObserver{}
+}
+
+/**
+ * Serializes the calls to the observer of subproblems that are trained concurrently
+ */
+type lockedObserver struct {
+	mu       sync.Mutex
+	observer TrainingObserver
+}
+
+func (o *lockedObserver) Iteration(iter int, maxViolation float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.Iteration(iter, maxViolation)
+}
+
+func (o *lockedObserver) Unshrink(iter int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.Unshrink(iter)
+}
+
+func (o *lockedObserver) SubproblemDone(info SubproblemInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.SubproblemDone(info)
+}
+
+func (o *lockedObserver) ProbabilityProgress(info ProbabilityInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.ProbabilityProgress(info)
+}
+
+func (o *lockedObserver) TrainingDone(totalSV int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.TrainingDone(totalSV)
+}
+
+func (o *lockedObserver) Warning(msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observer.Warning(msg)
+}
@@ -0,0 +1,177 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the training progress reported to the observer
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"reflect"
+	"testing"
+)
+
+/**
+ * Records the calls of the training, in order
+ */
+type recordingObserver struct {
+	NopObserver
+	events      []string // name of each call
+	iterations  []int
+	violations  []float64
+	subproblems []SubproblemInfo
+	probability []ProbabilityInfo
+	totalSV     []int
+}
+
+func (o *recordingObserver) Iteration(iter int, maxViolation float64) {
+	o.events = append(o.events, "Iteration")
+	o.iterations = append(o.iterations, iter)
+	o.violations = append(o.violations, maxViolation)
+}
+
+func (o *recordingObserver) SubproblemDone(info SubproblemInfo) {
+	o.events = append(o.events, "SubproblemDone")
+	o.subproblems = append(o.subproblems, info)
+}
+
+func (o *recordingObserver) ProbabilityProgress(info ProbabilityInfo) {
+	o.events = append(o.events, "ProbabilityProgress")
+	o.probability = append(o.probability, info)
+}
+
+func (o *recordingObserver) TrainingDone(totalSV int) {
+	o.events = append(o.events, "TrainingDone")
+	o.totalSV = append(o.totalSV, totalSV)
+}
+
+/**
+ * Each one-vs-one subproblem is reported with its pair of labels and the values of the report, after the
+ * iteration ticks of its solver, and the training ends with the total number of SVs
+ */
+func TestObserverSubproblems(t *testing.T) {
+	observer := new(recordingObserver)
+	param := quietParameter(C_SVC)
+	param.C = 100
+	param.Observer = observer
+	model := mustTrain(t, mustProblem(t, blobsText(1, 600, 4, 3), param), param)
+
+	wantLabels := [][]int{{1, 2}, {1, 3}, {2, 3}}
+	if len(observer.subproblems) != len(wantLabels) {
+		t.Fatalf("%d subproblems reported, want %d", len(observer.subproblems), len(wantLabels))
+	}
+	for p, info := range observer.subproblems {
+		if !reflect.DeepEqual(info.Labels, wantLabels[p]) {
+			t.Errorf("subproblem %d: labels %v, want %v", p, info.Labels, wantLabels[p])
+		}
+		if !reflect.DeepEqual(info, model.Report().Subproblems[p]) {
+			t.Errorf("subproblem %d: %+v, and %+v in the report", p, info, model.Report().Subproblems[p])
+		}
+	}
+
+	if len(observer.iterations) == 0 || observer.events[0] != "Iteration" {
+		t.Fatalf("no iteration ticks before the first subproblem: %v", observer.events)
+	}
+	for k, iter := range observer.iterations {
+		if iter <= 0 || !(observer.violations[k] > param.Eps) {
+			t.Errorf("tick %d: iteration %d with a maximum violation of %v", k, iter, observer.violations[k])
+		}
+	}
+
+	last := len(observer.events) - 1
+	if observer.events[last] != "TrainingDone" || !reflect.DeepEqual(observer.totalSV, []int{model.l}) {
+		t.Errorf("training done with %v SVs (last call %s), want %d", observer.totalSV, observer.events[last], model.l)
+	}
+}
+
+/**
+ * The probability calibration reports each fold before the subproblems trained for it, and its fitted
+ * parameters once it is finished
+ */
+func TestObserverProbability(t *testing.T) {
+	for _, svmType := range []int{C_SVC, EPSILON_SVR} {
+		observer := new(recordingObserver)
+		param := quietParameter(svmType)
+		param.Probability = true
+		param.Observer = observer
+		text := blobsText(1, 100, 4, 2)
+		if svmType == EPSILON_SVR {
+			text = regressionText(1, 100, 4)
+		}
+		model := mustTrain(t, mustProblem(t, text, param), param)
+
+		var wantLabels []int
+		if svmType == C_SVC {
+			wantLabels = []int{1, 2}
+		}
+		if len(observer.probability) != 6 {
+			t.Fatalf("svm type %d: %d probability calls, want 6", svmType, len(observer.probability))
+		}
+		for fold, info := range observer.probability {
+			if info.Fold != fold || info.NrFold != 5 || !reflect.DeepEqual(info.Labels, wantLabels) {
+				t.Errorf("svm type %d, call %d: %+v", svmType, fold, info)
+			}
+		}
+		final := observer.probability[5]
+		if svmType == C_SVC && (final.A != model.probA[0] || final.B != model.probB[0]) {
+			t.Errorf("fitted A %v B %v, want %v %v", final.A, final.B, model.probA[0], model.probB[0])
+		}
+		if svmType == EPSILON_SVR && (final.Sigma != model.probA[0] || !(final.Sigma > 0)) {
+			t.Errorf("fitted sigma %v, want %v", final.Sigma, model.probA[0])
+		}
+
+		// each fold is followed by the subproblem of its model, and the subproblem of the model comes last
+		var folds, done int
+		for k, event := range observer.events {
+			switch event {
+			case "ProbabilityProgress":
+				if folds < 5 && (k+1 == len(observer.events) || observer.events[k+1] != "Iteration" && observer.events[k+1] != "SubproblemDone") {
+					t.Errorf("svm type %d: fold %d is not followed by its subproblem: %v", svmType, folds, observer.events)
+				}
+				folds++
+			case "SubproblemDone":
+				done++
+				if done > folds {
+					t.Errorf("svm type %d: subproblem %d before its fold", svmType, done)
+				}
+			}
+		}
+		if done != 6 {
+			t.Errorf("svm type %d: %d subproblems reported, want 6", svmType, done)
+		}
+		if totalSV := observer.totalSV[len(observer.totalSV)-1]; totalSV != model.l {
+			t.Errorf("svm type %d: training done with %d SVs, want %d", svmType, totalSV, model.l)
+		}
+	}
+}
+
+/**
+ * The observer is Parameter.Observer if it is set, and prints to standard output unless QuietMode is set
+ */
+func TestDefaultObserver(t *testing.T) {
+	param := NewParameter()
+	if _, ok := param.observer().(ConsoleObserver); !ok {
+		t.Errorf("default observer %T", param.observer())
+	}
+	param.QuietMode = true
+	if _, ok := param.observer().(NopObserver); !ok {
+		t.Errorf("quiet observer %T", param.observer())
+	}
+	observer := new(recordingObserver)
+	param.Observer = observer
+	if param.observer() != observer {
+		t.Errorf("observer %T, want Parameter.Observer", param.observer())
+	}
+}
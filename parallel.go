@@ -19,79 +19,120 @@
 package libSvm
 
 import (
-	//"fmt"
 	"runtime"
+	"sync"
 )
 
-type parallelRunner struct {
-	N        int
-	numCPU   int
-	rem      int
-	constRem int
-	block    int
-	start    int
-	done     chan bool
-}
+/**
+ * Ranges shorter than this are run by the calling goroutine alone, since handing their parts to the
+ * workers would cost more than it saves
+ */
+const minParallelRange = 1024
 
 /**
- * Returns the start and end iterations for a CPU
+ * A part [start,end) of a range, run by a worker as part tid
  */
-func (p *parallelRunner) next() (int, int) {
-	start := p.start
-	end := p.start + p.block
-	if p.rem > 0 {
-		end++
-		p.rem--
-	}
-	p.start = end
-	return start, end
+type poolTask struct {
+	f          func(tid, start, end int)
+	tid        int
+	start, end int
+	wg         *sync.WaitGroup
 }
 
-func (p *parallelRunner) reset() {
-	p.rem = p.constRem
-	p.start = 0
+/**
+ * Workers that run the parts of a loop in parallel.  A pool lives for a whole training (see withWorkerPool),
+ * and is shared by the solvers and Q matrices of its subproblems, which may use it concurrently.
+ * A nil *workerPool runs everything in the calling goroutine.
+ */
+type workerPool struct {
+	numCPU int // number of parts a range is split into: the calling goroutine runs one, and numCPU-1 workers the others
+	tasks  chan poolTask
 }
 
-func (p parallelRunner) run(f func(int, int, int)) {
-
-	funcWrap := func(tid, start, end int) {
-		f(tid, start, end)
-		p.done <- true
+/**
+ * Returns a pool splitting ranges into numCPU parts (GOMAXPROCS parts if less than 1).  GOMAXPROCS is
+ * left for the host application to set.
+ */
+func newWorkerPool(numCPU int) *workerPool {
+	if numCPU < 1 {
+		numCPU = runtime.GOMAXPROCS(0)
 	}
 
-	p.reset()
-	for i := 0; i < p.numCPU; i++ {
-		start, end := p.next()
-		go funcWrap(i, start, end)
+	p := &workerPool{numCPU: numCPU}
+	if numCPU > 1 {
+		p.tasks = make(chan poolTask, numCPU)
+		for w := 1; w < numCPU; w++ {
+			go p.work()
+		}
 	}
+	return p
 }
 
-func (p parallelRunner) waitAll() {
+func (p *workerPool) work() {
+	for task := range p.tasks {
+		task.f(task.tid, task.start, task.end)
+		task.wg.Done()
+	}
+}
 
-	for i := 0; i < p.numCPU; i++ {
-		<-p.done
+/**
+ * Stops the workers once the tasks already given to them are done.  The pool must not be used afterwards.
+ */
+func (p *workerPool) close() {
+	if p != nil && p.tasks != nil {
+		close(p.tasks)
 	}
+}
 
+/**
+ * Returns the number of parts a range may be split into, i.e. the bound of the tid given to the loops
+ */
+func (p *workerPool) size() int {
+	if p == nil {
+		return 1
+	}
+	return p.numCPU
 }
 
-func newParallelRunner(n, nCPU int) parallelRunner {
-	var cpus int
-	if nCPU < 1 {
-		cpus = runtime.NumCPU()  // query the number of available CPUs
-		runtime.GOMAXPROCS(cpus) // set this as the number of usable CPUs
-	} else {
-		runtime.GOMAXPROCS(nCPU)
+/**
+ * Runs f on the iterations [begin,end) divided evenly into parallel parts, and returns when all are done.
+ * f(tid, start, end) runs the iterations [start,end) of part tid.
+ */
+func (p *workerPool) runRange(begin, end int, f func(tid, start, end int)) {
+	n := end - begin
+	if p == nil || p.numCPU == 1 || n < minParallelRange {
+		f(0, begin, end)
+		return
 	}
-	cpus = runtime.GOMAXPROCS(0) // get the new max number of CPUs
 
-	p := parallelRunner{N: n, numCPU: cpus}
+	block := n / p.numCPU
+	rem := n % p.numCPU
+	var wg sync.WaitGroup
+	wg.Add(p.numCPU - 1)
 
-	p.rem = p.N % p.numCPU
-	p.constRem = p.rem
-	p.block = p.N / p.numCPU
-	p.start = 0
+	start := begin + block // the calling goroutine runs part 0, of iterations [begin,begin+block)
+	for tid := 1; tid < p.numCPU; tid++ {
+		partEnd := start + block
+		if tid <= rem {
+			partEnd++
+		}
+		p.tasks <- poolTask{f: f, tid: tid, start: start, end: partEnd, wg: &wg}
+		start = partEnd
+	}
+	f(0, begin, begin+block)
 
-	p.done = make(chan bool, 1) // synchronization channel for run()/waitAll()
+	wg.Wait()
+}
 
-	return p
+/**
+ * Returns a copy of param whose subproblems share a new worker pool, with the function that stops it at the
+ * end of the training, or param itself (and a function doing nothing) if it already has a pool
+ */
+func withWorkerPool(param *Parameter) (*Parameter, func()) {
+	if param.pool != nil {
+		return param, func() {}
+	}
+	session := *param
+	session.pool = newWorkerPool(param.NumCPU)
+	return &session, session.pool.close
 }
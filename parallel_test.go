@@ -0,0 +1,131 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the worker pool of a training
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/**
+ * Every iteration of a range is run once, in a part of tid below the size of the pool, and a range too
+ * short to split is run in a single part
+ */
+func TestWorkerPoolRange(t *testing.T) {
+	for _, numCPU := range []int{1, 2, 4, 7} {
+		pools := []*workerPool{newWorkerPool(numCPU)}
+		if numCPU == 1 {
+			pools = append(pools, nil)
+		}
+		for _, pool := range pools {
+			for _, r := range [][2]int{{0, 0}, {3, 13}, {0, minParallelRange - 1}, {0, minParallelRange}, {5, 5003}} {
+				begin, end := r[0], r[1]
+				runs := make([]int32, end)
+				var parts int32 = 0
+				pool.runRange(begin, end, func(tid, start, stop int) {
+					atomic.AddInt32(&parts, 1)
+					if tid < 0 || tid >= pool.size() || start < begin || stop > end {
+						t.Errorf("%d CPUs, range %v: part %d of [%d,%d)", numCPU, r, tid, start, stop)
+					}
+					for i := start; i < stop; i++ {
+						atomic.AddInt32(&runs[i], 1)
+					}
+				})
+				for i := begin; i < end; i++ {
+					if runs[i] != 1 {
+						t.Fatalf("%d CPUs, range %v: iteration %d run %d times", numCPU, r, i, runs[i])
+					}
+				}
+				want := int32(pool.size())
+				if end-begin < minParallelRange {
+					want = 1
+				}
+				if parts != want {
+					t.Errorf("%d CPUs, range %v: %d parts, want %d", numCPU, r, parts, want)
+				}
+			}
+			pool.close()
+		}
+	}
+}
+
+/**
+ * Concurrent subproblems may run their ranges on the same pool, and the workers stop when it is closed
+ */
+func TestWorkerPoolShared(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	pool := newWorkerPool(4)
+	if runtime.NumGoroutine() != goroutines+3 {
+		t.Errorf("%d goroutines for a pool of 4 CPUs, want %d", runtime.NumGoroutine(), goroutines+3)
+	}
+
+	var wg sync.WaitGroup
+	sums := make([]int64, 8)
+	for g := range sums {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k := 0; k < 20; k++ {
+				pool.runRange(0, 4096, func(tid, start, end int) {
+					for i := start; i < end; i++ {
+						atomic.AddInt64(&sums[g], int64(i))
+					}
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g, sum := range sums {
+		if sum != 20*4095*4096/2 {
+			t.Errorf("goroutine %d: sum %d", g, sum)
+		}
+	}
+
+	pool.close()
+	for k := 0; k < 100 && runtime.NumGoroutine() > goroutines; k++ {
+		time.Sleep(time.Millisecond)
+	}
+	if runtime.NumGoroutine() > goroutines {
+		t.Errorf("%d goroutines after the pool is closed, want %d", runtime.NumGoroutine(), goroutines)
+	}
+}
+
+/**
+ * The number of CPUs of a training leaves GOMAXPROCS alone and does not change the model
+ */
+func TestNumCPU(t *testing.T) {
+	maxProcs := runtime.GOMAXPROCS(0)
+	param := quietParameter(C_SVC)
+	param.NumCPU = 1
+	prob := mustProblem(t, blobsText(1, 1500, 4, 3), param)
+	text := modelText(t, mustTrain(t, prob, param))
+
+	for _, numCPU := range []int{-1, 3, 8} {
+		param.NumCPU = numCPU
+		if got := modelText(t, mustTrain(t, prob, param)); got != text {
+			t.Errorf("%d CPUs: model\n%s\nwant\n%s", numCPU, got, text)
+		}
+		if runtime.GOMAXPROCS(0) != maxProcs {
+			t.Fatalf("%d CPUs: GOMAXPROCS %d, was %d", numCPU, runtime.GOMAXPROCS(0), maxProcs)
+		}
+	}
+}
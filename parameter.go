@@ -18,6 +18,11 @@
  */
 package libSvm
 
+import (
+	"math"
+	"time"
+)
+
 const LibSvmGoVersion = 0.318
 
 const (
@@ -36,6 +41,14 @@ const (
 	PRECOMPUTED = iota
 )
 
+const (
+	SMO                 = iota // LIBSVM's SMO solver with a kernel cache, for all svm and kernel types
+	L2R_L2LOSS_SVC_DUAL = iota // LIBLINEAR's dual coordinate descent for L2-loss C-SVC (linear kernel only)
+	L2R_L1LOSS_SVC_DUAL = iota // LIBLINEAR's dual coordinate descent for L1-loss C-SVC (linear kernel only)
+	L2R_L2LOSS_SVR_DUAL = iota // LIBLINEAR's dual coordinate descent for L2-loss epsilon-SVR (linear kernel only)
+	L2R_L1LOSS_SVR_DUAL = iota // LIBLINEAR's dual coordinate descent for L1-loss epsilon-SVR (linear kernel only)
+)
+
 var svm_type_string = []string{"c_svc", "nu_svc", "one_class", "epsilon_svr", "nu_svr"}
 var kernel_type_string = []string{"linear", "polynomial", "rbf", "sigmoid", "precomputed"}
 
@@ -45,21 +58,154 @@ type Parameter struct {
 	Degree     int     // Degree used in polynomial kernel
 	Gamma      float64 // Gamma used in rbf, polynomial, and sigmoid kernel
 	Coef0      float64 // Coef0 used in polynomial and sigmoid kernel
+	Solver     int     // Solver of the subproblems (SMO, or one of the dual coordinate descent solvers)
+
+	Eps            float64 // stopping criteria
+	C              float64 // penality
+	NrWeight       int
+	WeightLabel    []int
+	Weight         []float64
+	Nu             float64
+	P              float64
+	Probability    bool // Should probability estimation be performed?
+	CacheSize      int  // Size of Q matrix cache
+	CachePolicy    int  // Eviction policy of the Q matrix cache (LRU_CACHE or LFU_CACHE); a cache that can hold the whole Q matrix evicts nothing
+	CachePrecision int  // Type of the cached kernel values: SINGLE_PRECISION (float32) or DOUBLE_PRECISION (float64, twice the memory)
+	Shrinking      bool // Should the shrinking heuristics be used?
+	QuietMode      bool // quiet mode
+	NumCPU         int  // Number of CPUs to use (GOMAXPROCS if less than 1)
+
+	SubproblemWorkers int // Number of one-vs-one subproblems trained concurrently, splitting CacheSize between them (0 or 1 trains them one at a time)
 
-	Eps         float64 // stopping criteria
-	C           float64 // penality
-	NrWeight    int
-	WeightLabel []int
-	Weight      []float64
-	Nu          float64
-	P           float64
-	Probability bool // Should probability estimation be performed?
-	CacheSize   int  // Size of Q matrix cache
-	QuietMode   bool // quiet mode
-	NumCPU      int  // Number of CPUs to use
+	StrictInput bool // Reject unsorted or duplicate feature indices and non-finite values when reading a problem set
+	SortIndices bool // With StrictInput, sort unsorted feature indices instead of rejecting them
+
+	CompactLinear bool // Dump linear-kernel models as their primal weights instead of their SVs
+
+	MaxIter     int           // Maximum solver iterations per subproblem (0 uses the LIBSVM default, or 1000 outer iterations of the dual coordinate descent solvers)
+	MaxDuration time.Duration // Maximum time for training (0 is unlimited)
+
+	Observer TrainingObserver // Receives the training progress (nil prints it to stdout, unless QuietMode is set)
+
+	kernels sharedKernels // kernel values shared by the subproblems of one training or cross validation (see withKernelCache)
+	pool    *workerPool   // workers shared by the subproblems of one training or cross validation (see withWorkerPool)
 }
 
 func NewParameter() *Parameter {
 	return &Parameter{SvmType: C_SVC, KernelType: RBF, Degree: 3, Gamma: 0, Coef0: 0, Nu: 0.5, C: 1, Eps: 1e-3, P: 0.1,
-		NrWeight: 0, Probability: false, CacheSize: 100, QuietMode: false, NumCPU: -1}
+		NrWeight: 0, Probability: false, CacheSize: 100, Shrinking: true, QuietMode: false, NumCPU: -1}
+}
+
+/**
+ * Checks the parameters against the problem set before training, like LIBSVM's svm_check_parameter.
+ * The problem set may be nil.  If it is given, it must not be empty, the nu-SVC feasibility is checked, so is
+ * the format of the precomputed kernel (a ParseError), and gamma is set to its default if it is 0, as
+ * NewProblem does.  The returned error otherwise satisfies errors.Is(err, ErrInvalidParameter).
+ */
+func (param *Parameter) Validate(prob *Problem) error {
+	if prob != nil && prob.l == 0 {
+		return invalidParameter("the problem set has no instances")
+	}
+
+	switch param.SvmType {
+	case C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR:
+	default:
+		return invalidParameter("unknown svm type %d", param.SvmType)
+	}
+
+	switch param.KernelType {
+	case LINEAR, POLY, RBF, SIGMOID, PRECOMPUTED:
+	default:
+		return invalidParameter("unknown kernel type %d", param.KernelType)
+	}
+
+	if prob != nil && param.KernelType == PRECOMPUTED {
+		if err := prob.checkPrecomputed(); err != nil {
+			return err
+		}
+	}
+
+	if (param.KernelType == POLY || param.KernelType == RBF || param.KernelType == SIGMOID) && param.Gamma < 0 {
+		return invalidParameter("gamma < 0")
+	}
+	if param.KernelType == POLY && param.Degree < 0 {
+		return invalidParameter("degree of polynomial kernel < 0")
+	}
+
+	switch param.Solver {
+	case SMO:
+	case L2R_L2LOSS_SVC_DUAL, L2R_L1LOSS_SVC_DUAL:
+		if param.SvmType != C_SVC || param.KernelType != LINEAR {
+			return invalidParameter("the dual coordinate descent SVC solvers need C-SVC with the linear kernel")
+		}
+	case L2R_L2LOSS_SVR_DUAL, L2R_L1LOSS_SVR_DUAL:
+		if param.SvmType != EPSILON_SVR || param.KernelType != LINEAR {
+			return invalidParameter("the dual coordinate descent SVR solvers need epsilon-SVR with the linear kernel")
+		}
+	default:
+		return invalidParameter("unknown solver %d", param.Solver)
+	}
+
+	if param.CacheSize <= 0 {
+		return invalidParameter("cache_size <= 0")
+	}
+	if param.CachePolicy != LRU_CACHE && param.CachePolicy != LFU_CACHE {
+		return invalidParameter("unknown cache policy %d", param.CachePolicy)
+	}
+	if param.CachePrecision != SINGLE_PRECISION && param.CachePrecision != DOUBLE_PRECISION {
+		return invalidParameter("unknown cache precision %d", param.CachePrecision)
+	}
+	if param.Eps <= 0 {
+		return invalidParameter("eps <= 0")
+	}
+	if param.SubproblemWorkers < 0 {
+		return invalidParameter("subproblem_workers < 0")
+	}
+	if param.MaxIter < 0 {
+		return invalidParameter("max_iter < 0")
+	}
+
+	if (param.SvmType == C_SVC || param.SvmType == EPSILON_SVR || param.SvmType == NU_SVR) && param.C <= 0 {
+		return invalidParameter("C <= 0")
+	}
+	if (param.SvmType == NU_SVC || param.SvmType == ONE_CLASS || param.SvmType == NU_SVR) && (param.Nu <= 0 || param.Nu > 1) {
+		return invalidParameter("nu <= 0 or nu > 1")
+	}
+	if param.SvmType == EPSILON_SVR && param.P < 0 {
+		return invalidParameter("p < 0")
+	}
+
+	if param.NrWeight > len(param.WeightLabel) || param.NrWeight > len(param.Weight) {
+		return invalidParameter("nr_weight %d exceeds the number of weights", param.NrWeight)
+	}
+
+	if param.Probability && param.SvmType == ONE_CLASS {
+		return invalidParameter("one-class SVM probability output not supported yet")
+	}
+
+	// check whether nu-svc is feasible
+	if param.SvmType == NU_SVC && prob != nil {
+		nrClass, _, start, count, perm := groupClasses(prob)
+		weight := make([]float64, nrClass) // total instance weight of each class
+		for i := 0; i < nrClass; i++ {
+			for k := start[i]; k < start[i]+count[i]; k++ {
+				weight[i] += prob.weight(perm[k])
+			}
+		}
+		for i := 0; i < nrClass; i++ {
+			n1 := weight[i]
+			for j := i + 1; j < nrClass; j++ {
+				n2 := weight[j]
+				if param.Nu*(n1+n2)/2 > math.Min(n1, n2) {
+					return invalidParameter("specified nu is infeasible")
+				}
+			}
+		}
+	}
+
+	if prob != nil {
+		prob.defaultGamma(param)
+	}
+
+	return nil
 }
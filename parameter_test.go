@@ -0,0 +1,143 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the parameter validation
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+/**
+ * Invalid parameters are rejected with ErrInvalidParameter by Validate and by Train, before training
+ */
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		svmType int
+		set     func(param *Parameter)
+	}{
+		{"svm type", C_SVC, func(param *Parameter) { param.SvmType = 5 }},
+		{"kernel type", C_SVC, func(param *Parameter) { param.KernelType = -1 }},
+		{"gamma", C_SVC, func(param *Parameter) { param.Gamma = -1 }},
+		{"degree", C_SVC, func(param *Parameter) { param.KernelType, param.Degree = POLY, -1 }},
+		{"cache size", C_SVC, func(param *Parameter) { param.CacheSize = 0 }},
+		{"cache policy", C_SVC, func(param *Parameter) { param.CachePolicy = 2 }},
+		{"cache precision", C_SVC, func(param *Parameter) { param.CachePrecision = -1 }},
+		{"eps", C_SVC, func(param *Parameter) { param.Eps = 0 }},
+		{"C", C_SVC, func(param *Parameter) { param.C = -1 }},
+		{"C of epsilon-SVR", EPSILON_SVR, func(param *Parameter) { param.C = 0 }},
+		{"nu 0", NU_SVC, func(param *Parameter) { param.Nu = 0 }},
+		{"nu above 1", ONE_CLASS, func(param *Parameter) { param.Nu = 1.5 }},
+		{"nu of nu-SVR", NU_SVR, func(param *Parameter) { param.Nu = -0.5 }},
+		{"p", EPSILON_SVR, func(param *Parameter) { param.P = -0.1 }},
+		{"nr_weight", C_SVC, func(param *Parameter) { param.NrWeight = 1 }},
+		{"one-class probability", ONE_CLASS, func(param *Parameter) { param.Probability = true }},
+		{"max_iter", C_SVC, func(param *Parameter) { param.MaxIter = -1 }},
+		{"subproblem workers", C_SVC, func(param *Parameter) { param.SubproblemWorkers = -1 }},
+	}
+
+	for _, test := range tests {
+		param := quietParameter(test.svmType)
+		prob := mustProblem(t, problemText(test.svmType), param)
+		test.set(param)
+		if err := param.Validate(nil); !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("%s: Validate returned %v", test.name, err)
+		}
+		model := NewModel(param)
+		if err := model.Train(prob); !errors.Is(err, ErrInvalidParameter) || model.l != 0 {
+			t.Errorf("%s: Train returned %v, and %d SVs", test.name, err, model.l)
+		}
+	}
+}
+
+/**
+ * The default parameters of every svm type are valid, and the default gamma is set from the problem set
+ */
+func TestValidateDefaults(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		if err := param.Validate(nil); err != nil {
+			t.Errorf("svm type %d: %v", svmType, err)
+		}
+		prob := mustProblem(t, problemText(svmType), nil)
+		if err := param.Validate(prob); err != nil || param.Gamma != 0.25 {
+			t.Errorf("svm type %d: %v, gamma %v", svmType, err, param.Gamma)
+		}
+	}
+
+	empty, err := NewProblemBuilder().Build(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := quietParameter(C_SVC).Validate(empty); !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("empty problem set: %v", err)
+	}
+}
+
+/**
+ * nu-SVC is infeasible when nu is larger than 2*min(n1,n2)/(n1+n2) for a pair of classes, counting the
+ * instance weights
+ */
+func TestValidateNuFeasibility(t *testing.T) {
+	text := "1 1:1\n1 1:1.1\n2 1:-1\n" +
+		"3 1:5\n3 1:5.1\n3 1:5.2\n3 1:5.3\n3 1:5.4\n3 1:5.5\n3 1:5.6\n3 1:5.7\n3 1:5.8\n"
+	prob := mustProblem(t, text, nil)
+	param := quietParameter(NU_SVC)
+
+	for _, test := range []struct {
+		nu       float64
+		feasible bool
+	}{{0.19, true}, {0.21, false}, {0.5, false}} { // classes 2 and 3 allow nu <= 2*1/(1+9)
+		param.Nu = test.nu
+		if err := param.Validate(prob); (err == nil) != test.feasible || err != nil && !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("nu %v: %v", test.nu, err)
+		}
+	}
+
+	// weights of 2, 2 and 3 for the classes allow nu <= 2*2/(2+3)
+	third := 1.0 / 3
+	if err := prob.SetWeights([]float64{1, 1, 2, third, third, third, third, third, third, third, third, third}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		nu       float64
+		feasible bool
+	}{{0.5, true}, {0.79, true}, {0.81, false}} {
+		param.Nu = test.nu
+		if err := param.Validate(prob); (err == nil) != test.feasible {
+			t.Errorf("nu %v with weights: %v", test.nu, err)
+		}
+	}
+}
+
+/**
+ * At least a fraction nu of the instances are SVs of a one-class SVM
+ */
+func TestOneClassNu(t *testing.T) {
+	for _, nu := range []float64{0.1, 0.5, 0.9} {
+		param := quietParameter(ONE_CLASS)
+		param.Nu = nu
+		prob := mustProblem(t, problemText(ONE_CLASS), param)
+		model := mustTrain(t, prob, param)
+		if float64(model.l) < math.Floor(nu*float64(prob.l)) {
+			t.Errorf("nu %v: %d SVs of %d instances", nu, model.l, prob.l)
+		}
+	}
+}
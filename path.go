@@ -0,0 +1,115 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Trains models along a path of increasing C, seeding each one from the previous
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+)
+
+/**
+ * Trains one model for each C in cs.  Same as TrainPathContext with a context that is never cancelled.
+ */
+func TrainPath(prob *Problem, param *Parameter, cs []float64) ([]*Model, []*TrainReport, error) {
+	return TrainPathContext(context.Background(), prob, param, cs)
+}
+
+/**
+ * Trains one model for each C in cs, which must be positive and strictly increasing, with param otherwise
+ * unchanged, and returns the models and their reports in the order of cs.  This is much cheaper than
+ * training each model on its own: every subproblem starts from the alphas of the previous model scaled by
+ * the ratio of the two C values, and keeps its kernel cache from one C to the next, so the kernel values
+ * computed for the first C serve the whole path.  The half of the CacheSize of param left by the kernel cache
+ * of the instances is split between the subproblems, whose caches are all kept at once.
+ * Parameter.MaxDuration bounds the training of each model.  Only C-SVC, epsilon-SVR and nu-SVR depend on C.
+ * If a training fails, the models trained so far are returned with the error.
+ */
+func TrainPathContext(ctx context.Context, prob *Problem, param *Parameter, cs []float64) ([]*Model, []*TrainReport, error) {
+	if err := param.Validate(prob); err != nil {
+		return nil, nil, err
+	}
+	switch param.SvmType {
+	case C_SVC, EPSILON_SVR, NU_SVR:
+	default:
+		return nil, nil, invalidParameter("the path over C is only for C-SVC, epsilon-SVR and nu-SVR")
+	}
+	if len(cs) == 0 {
+		return nil, nil, invalidParameter("no C for the path")
+	}
+	for k, c := range cs {
+		if c <= 0 {
+			return nil, nil, invalidParameter("C %v of the path is not positive", c)
+		}
+		if k > 0 && c <= cs[k-1] {
+			return nil, nil, invalidParameter("C %v of the path does not increase from %v", c, cs[k-1])
+		}
+	}
+
+	var nrSubproblems int = 1
+	if param.SvmType == C_SVC {
+		nrClass, _, _, _, _ := groupClasses(prob)
+		nrSubproblems = nrClass * (nrClass - 1) / 2
+	}
+	sessionParam, closePool := withWorkerPool(withKernelCache(prob, param)) // the kernel values of the instances serve the whole path
+	defer closePool()
+	pathParam := *sessionParam
+	pathParam.CacheSize = maxi(1, pathParam.CacheSize/maxi(1, nrSubproblems))
+
+	kept := &keptMatrices{}
+	models := make([]*Model, 0, len(cs))
+	reports := make([]*TrainReport, 0, len(cs))
+	for k, c := range cs {
+		stepParam := *param // the parameters the model keeps
+		stepParam.C = c
+		if err := stepParam.Validate(prob); err != nil {
+			return models, reports, err
+		}
+		trainParam := pathParam
+		trainParam.C = c
+
+		var warm *warmStart
+		if k > 0 {
+			if warm = newWarmStart(models[k-1], &stepParam); warm != nil {
+				warm.scale = c / cs[k-1]
+			}
+		}
+
+		model := NewModel(&trainParam)
+		err := model.trainStep(ctx, prob, warm, kept)
+		model.param = &stepParam
+		if err != nil {
+			return models, reports, err
+		}
+		models = append(models, model)
+		reports = append(reports, model.Report())
+	}
+
+	return models, reports, nil
+}
+
+/**
+ * Trains one model of a path, within Parameter.MaxDuration
+ */
+func (model *Model) trainStep(ctx context.Context, prob *Problem, warm *warmStart, kept *keptMatrices) error {
+	if model.param.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, model.param.MaxDuration)
+		defer cancel()
+	}
+	return model.trainFrom(ctx, prob, warm, kept)
+}
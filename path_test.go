@@ -0,0 +1,124 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the regularization path over C
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+/**
+ * Cancels the path once its first model is trained
+ */
+type firstModelObserver struct {
+	NopObserver
+	cancel context.CancelFunc
+}
+
+func (o *firstModelObserver) TrainingDone(totalSV int) {
+	o.cancel()
+}
+
+/**
+ * Each model of the path is the model trained on its own with its C, and has the report of its own
+ * training, which finds the kernel values of the earlier models in the cache
+ */
+func TestTrainPath(t *testing.T) {
+	cs := []float64{0.1, 0.5, 2, 8}
+	for _, svmType := range []int{C_SVC, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Eps = 1e-6
+		prob := mustProblem(t, problemText(svmType), param)
+		models, reports, err := TrainPath(prob, param, cs)
+		if err != nil {
+			t.Fatalf("svm type %d: %v", svmType, err)
+		}
+		if len(models) != len(cs) || len(reports) != len(cs) || param.C != 1 {
+			t.Fatalf("svm type %d: %d models and %d reports, C %v", svmType, len(models), len(reports), param.C)
+		}
+
+		for k, c := range cs {
+			model := models[k]
+			if model.param.C != c || reports[k] != model.Report() {
+				t.Errorf("svm type %d, C %v: model of C %v, report %+v", svmType, c, model.param.C, *reports[k])
+			}
+
+			stepParam := *param
+			stepParam.C = c
+			want := mustTrain(t, prob, &stepParam)
+			for p, info := range model.Report().Subproblems {
+				wantInfo := want.Report().Subproblems[p]
+				if !near(info.Obj, wantInfo.Obj, 1e-4*math.Abs(wantInfo.Obj)) || !near(info.Rho, wantInfo.Rho, 1e-3) {
+					t.Errorf("svm type %d, C %v, subproblem %d: obj %v rho %v, want %v %v", svmType, c, p, info.Obj, info.Rho, wantInfo.Obj, wantInfo.Rho)
+				}
+			}
+			for prob.Begin(); !prob.Done(); prob.Next() {
+				_, x := prob.GetLine()
+				_, got := model.PredictValues(x)
+				_, wantValues := want.PredictValues(x)
+				if !nearSlices(got, wantValues, 1e-3) {
+					t.Fatalf("svm type %d, C %v: decision values %v, want %v", svmType, c, got, wantValues)
+				}
+			}
+			if k > 0 && reports[k].CacheMisses >= want.Report().CacheMisses {
+				t.Errorf("svm type %d, C %v: %d cache misses, and %d on its own", svmType, c, reports[k].CacheMisses, want.Report().CacheMisses)
+			}
+		}
+	}
+}
+
+func TestTrainPathInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		svmType int
+		cs      []float64
+	}{
+		{"nu-SVC", NU_SVC, []float64{1, 2}},
+		{"one-class SVM", ONE_CLASS, []float64{1, 2}},
+		{"no C", C_SVC, nil},
+		{"C of 0", C_SVC, []float64{0, 1}},
+		{"decreasing C", EPSILON_SVR, []float64{1, 2, 0.5}},
+		{"repeated C", NU_SVR, []float64{1, 1}},
+	}
+	for _, test := range tests {
+		param := quietParameter(test.svmType)
+		prob := mustProblem(t, problemText(test.svmType), param)
+		if models, reports, err := TrainPath(prob, param, test.cs); !errors.Is(err, ErrInvalidParameter) || models != nil || reports != nil {
+			t.Errorf("%s: %d models, %d reports, %v", test.name, len(models), len(reports), err)
+		}
+	}
+}
+
+/**
+ * A stopped path returns the models trained before it was stopped
+ */
+func TestTrainPathStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	param := quietParameter(C_SVC)
+	param.Observer = &firstModelObserver{cancel: cancel}
+	prob := mustProblem(t, problemText(C_SVC), param)
+
+	models, reports, err := TrainPathContext(ctx, prob, param, []float64{1, 2, 4})
+	if !isStopped(err, context.Canceled) || len(models) != 1 || len(reports) != 1 || models[0].param.C != 1 {
+		t.Errorf("%d models, %d reports, %v", len(models), len(reports), err)
+	}
+}
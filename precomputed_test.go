@@ -0,0 +1,163 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of training and prediction with the precomputed kernel
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+/**
+ * Returns the rows of the RBF gram matrix of the 4 dimensional vectors xs against the training vectors,
+ * in the precomputed kernel format 0:sample_serial_number 1:K(x,x_1) ... l:K(x,x_l)
+ */
+func gramText(ys []float64, xs, train []map[int]float64, gamma float64) string {
+	var b strings.Builder
+	for i, x := range xs {
+		fmt.Fprintf(&b, "%.17g 0:%d", ys[i], i+1)
+		for j, y := range train {
+			var d float64 = 0
+			for k := 1; k <= 4; k++ {
+				d += (x[k] - y[k]) * (x[k] - y[k])
+			}
+			fmt.Fprintf(&b, " %d:%.17g", j+1, math.Exp(-gamma*d))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func problemVectors(prob *Problem) (ys []float64, xs []map[int]float64) {
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		y, x := prob.GetLine()
+		ys = append(ys, y)
+		xs = append(xs, x)
+	}
+	return // ys, xs
+}
+
+/**
+ * Training on the gram matrix of the RBF kernel gives the RBF model, for every svm type, and the model
+ * is written and read back with the serial numbers of its SVs only
+ */
+func TestPrecomputedKernel(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Gamma = 0.25
+		param.Eps = 1e-6
+		prob := mustProblem(t, problemText(svmType), param)
+		rbf := mustTrain(t, prob, param)
+
+		ys, xs := problemVectors(prob)
+		gram := quietParameter(svmType)
+		gram.KernelType = PRECOMPUTED
+		gram.Eps = param.Eps
+		model := mustTrain(t, mustProblem(t, gramText(ys, xs, xs, param.Gamma), gram), gram)
+
+		want, got := rbf.Report().Subproblems, model.Report().Subproblems
+		for p := range want {
+			if !near(got[p].Obj, want[p].Obj, 1e-6) || !near(got[p].Rho, want[p].Rho, 1e-6) || got[p].NSV != want[p].NSV {
+				t.Errorf("svm type %d, subproblem %d: obj %v rho %v nSV %d, want %v %v %d",
+					svmType, p, got[p].Obj, got[p].Rho, got[p].NSV, want[p].Obj, want[p].Rho, want[p].NSV)
+			}
+		}
+
+		var buf bytes.Buffer
+		if _, err := model.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		sv := buf.String()[strings.Index(buf.String(), "SV\n"):]
+		if strings.Contains(sv, " 1:") {
+			t.Errorf("svm type %d: kernel values written with the SVs:\n%s", svmType, sv)
+		}
+		read, err := ReadModelFrom(&buf)
+		if err != nil {
+			t.Fatalf("svm type %d: ReadModelFrom: %v", svmType, err)
+		}
+
+		testText := blobsText(2, 30, 4, 3)
+		if svmType == EPSILON_SVR || svmType == NU_SVR {
+			testText = regressionText(2, 30, 4)
+		}
+		testYs, testXs := problemVectors(mustProblem(t, testText, nil))
+		test := mustProblem(t, gramText(testYs, testXs, xs, param.Gamma), nil)
+		i := 0
+		for test.Begin(); !test.Done(); test.Next() {
+			_, x := test.GetLine()
+			wantValue, wantValues := rbf.PredictValues(testXs[i])
+			for k, m := range []*Model{model, read} {
+				tol := []float64{1e-6, 1e-4}[k] // rho is written with 6 significant digits
+				if value, values := m.PredictValues(x); !nearSlices(values, wantValues, tol) ||
+					(svmType == EPSILON_SVR || svmType == NU_SVR) && !near(value, wantValue, tol) {
+					t.Fatalf("svm type %d, vector %d: predicted %v %v, want %v %v", svmType, i, value, values, wantValue, wantValues)
+				}
+			}
+			i++
+		}
+	}
+}
+
+/**
+ * A malformed precomputed kernel row is a ParseError at its line, whether the problem set is read or
+ * validated before training
+ */
+func TestPrecomputedKernelFormat(t *testing.T) {
+	tests := []struct {
+		text string
+		line int
+	}{
+		{"1 0:1 1:1 2:0\n-1 1:0 2:1\n", 2},                // no serial number
+		{"1 0:1 1:1 2:0\n\n-1 0:3 1:0 2:1\n", 3},          // serial number out of range
+		{"1 0:1 1:1 2:0\n-1 0:0 1:0 2:1\n", 2},            // serial number out of range
+		{"1 0:1 1:1 2:0\n\n\n-1 0:2 1:0\n", 4},            // missing kernel value
+		{"1 0:1 1:1 3:0\n-1 0:2 1:0 2:1 3:1\n", 1},        // kernel values not contiguous
+		{"1 0:2 1:1 2:0 3:1\n-1 0:3 1:0 2:1 3:0.5\n", -1}, // serial numbers within the rows
+	}
+
+	param := quietParameter(C_SVC)
+	param.KernelType = PRECOMPUTED
+	for _, test := range tests {
+		_, err := NewProblemFrom(strings.NewReader(test.text), param)
+		if test.line < 0 {
+			if err != nil {
+				t.Errorf("%q: %v", test.text, err)
+			}
+			continue
+		}
+		var pe *ParseError
+		if !errors.Is(err, ErrParse) || !errors.As(err, &pe) || pe.Line != test.line {
+			t.Errorf("%q: %v, want a parse error at line %d", test.text, err, test.line)
+		}
+
+		prob, err := NewProblemFrom(strings.NewReader(test.text), nil)
+		if err != nil {
+			t.Fatalf("%q read without a parameter: %v", test.text, err)
+		}
+		if err := param.Validate(prob); !errors.As(err, &pe) || pe.Line != test.line {
+			t.Errorf("%q validated: %v, want a parse error at line %d", test.text, err, test.line)
+		}
+		if err := NewModel(param).Train(prob); !errors.Is(err, ErrParse) {
+			t.Errorf("%q trained: %v", test.text, err)
+		}
+	}
+}
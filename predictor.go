@@ -18,6 +18,94 @@
  */
 package libSvm
 
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+/**
+ * Buffers reused from one prediction to the next: the kernel values of the test vector and the SVs, the
+ * test vector scattered into a dense vector, which is all zeros between predictions, and a sorted copy of
+ * a test vector whose dimensions are not in ascending order
+ */
+type predictScratch struct {
+	kvalue []float64
+	dense  []float64
+	nodes  []snode
+}
+
+/**
+ * Scratch buffers of the predictions that are not given one by their caller
+ */
+var scratchPool = sync.Pool{New: func() interface{} { return new(predictScratch) }}
+
+/**
+ * Returns the kernel values buffer for l SVs
+ */
+func (scratch *predictScratch) kernelValues(l int) []float64 {
+	if len(scratch.kvalue) < l {
+		scratch.kvalue = make([]float64, l)
+	}
+	return scratch.kvalue[:l]
+}
+
+/**
+ * Returns px scattered into the dense buffer, whose length is the largest dimension n of px.  The dimensions
+ * of px must be at least 1, in any order.  The buffer must be cleared with clear(px) once done.
+ */
+func (scratch *predictScratch) scatter(px []snode, n int) []float64 {
+	if len(scratch.dense) < n {
+		scratch.dense = make([]float64, n)
+	}
+	x := scratch.dense[:n]
+	for k := 0; px[k].index != -1; k++ {
+		x[px[k].index-1] = px[k].value
+	}
+	return x
+}
+
+func (scratch *predictScratch) clear(px []snode) {
+	for k := 0; px[k].index != -1; k++ {
+		scratch.dense[px[k].index-1] = 0
+	}
+}
+
+/**
+ * Returns a copy of px with its dimensions in ascending order, in the nodes buffer
+ */
+func (scratch *predictScratch) sorted(px []snode) []snode {
+	scratch.nodes = scratch.nodes[:0]
+	for k := 0; px[k].index != -1; k++ {
+		scratch.nodes = append(scratch.nodes, px[k])
+	}
+	nodes := scratch.nodes
+	sort.Slice(nodes, func(a, b int) bool { return nodes[a].index < nodes[b].index })
+	scratch.nodes = append(scratch.nodes, snode{index: -1})
+	return scratch.nodes
+}
+
+/**
+ * Returns the smallest and the largest dimension of px (both 0 if px is empty), and whether its dimensions
+ * are in ascending order.  Vectors of a problem set that is not read strictly may be in any order.
+ */
+func snodeRange(px []snode) (lo, hi int, ascending bool) {
+	ascending = true
+	for k := 0; px[k].index != -1; k++ {
+		index := px[k].index
+		if k == 0 {
+			lo, hi = index, index
+			continue
+		}
+		if index <= px[k-1].index {
+			ascending = false
+		}
+		lo = mini(lo, index)
+		hi = maxi(hi, index)
+	}
+	return // lo, hi, ascending
+}
+
 /**
 *  This function gives decision values on a test vector x given a
    model, and return the predicted label (classification) or
@@ -38,46 +126,184 @@ package libSvm
 
 */
 func (model Model) PredictValues(x map[int]float64) (returnValue float64, decisionValues []float64) {
-	returnValue = 0
+	return model.predictValues(MapToSnode(x), nil)
+}
 
-	px := MapToSnode(x)
+/**
+ * Same as PredictValues, but for the vector px (snodes ending with index -1), with the buffers of scratch,
+ * or of one taken from scratchPool if it is nil
+ */
+func (model Model) predictValues(px []snode, scratch *predictScratch) (returnValue float64, decisionValues []float64) {
+	decisionValues = model.decisionValues(px, scratch)
+	return model.decide(decisionValues), decisionValues
+}
+
+/**
+ * Same as predictValues, but for the dense vector x, whose element k is dimension k+1
+ */
+func (model Model) predictValuesDense(x []float64, scratch *predictScratch) (returnValue float64, decisionValues []float64) {
+	decisionValues = model.decisionValuesDense(x, scratch)
+	return model.decide(decisionValues), decisionValues
+}
+
+/**
+ * Returns the decision values of px, from the primal weights of a linear model if it has them,
+ * and otherwise from the kernel values of px and the SVs
+ */
+func (model Model) decisionValues(px []snode, scratch *predictScratch) []float64 {
+	if model.linearW != nil {
+		return model.linearDecisionValues(px)
+	}
+	if scratch == nil {
+		scratch = scratchPool.Get().(*predictScratch)
+		defer scratchPool.Put(scratch)
+	}
+	return model.svDecisionValues(model.svKernelValues(px, scratch))
+}
 
+func (model Model) decisionValuesDense(x []float64, scratch *predictScratch) []float64 {
+	if model.linearW != nil {
+		return model.linearDecisionValuesDense(x)
+	}
+	if scratch == nil {
+		scratch = scratchPool.Get().(*predictScratch)
+		defer scratchPool.Put(scratch)
+	}
+	return model.svDecisionValues(model.svKernelValuesDense(x, scratch.kernelValues(model.l)))
+}
+
+/**
+ * Precomputes the squared norms of the SVs, which every RBF kernel value needs, once the SVs are known
+ */
+func (model *Model) computeSVSquare() {
+	model.svSquare = nil
+	if model.param.KernelType != RBF {
+		return
+	}
+	model.svSquare = make([]float64, model.l)
+	for i := 0; i < model.l; i++ {
+		py := model.svSpace[model.sV[i]:]
+		model.svSquare[i] = dot(py, py)
+	}
+}
+
+/**
+ * Returns the kernel value from the dot product of x and y, and their squared norms (RBF only)
+ */
+func (model Model) kernelFromDot(xy, xSquare, ySquare float64) float64 {
+	param := model.param
+	switch param.KernelType {
+	case LINEAR:
+		return xy
+	case RBF:
+		return math.Exp(-param.Gamma * (xSquare + ySquare - 2*xy))
+	case POLY:
+		return math.Pow(param.Gamma*xy+param.Coef0, float64(param.Degree))
+	case SIGMOID:
+		return math.Tanh(param.Gamma*xy + param.Coef0)
+	}
+	return 0
+}
+
+/**
+ * Returns the kernel values of px and each SV, in the buffers of scratch
+ */
+func (model Model) svKernelValues(px []snode, scratch *predictScratch) []float64 {
+	var l int = model.l
+	kvalue := scratch.kernelValues(l)
+	lo, n, ascending := snodeRange(px)
+	if model.param.KernelType != PRECOMPUTED && lo >= 1 && n <= len(model.svSpace) {
+		// scatter px once, unless the dense vector would be larger than all the SVs together
+		kvalue = model.svKernelValuesDense(scratch.scatter(px, n), kvalue)
+		scratch.clear(px)
+		return kvalue
+	}
+	if !ascending { // the sparse dot product merges dimensions in ascending order
+		px = scratch.sorted(px)
+	}
+
+	if model.param.KernelType == RBF && len(model.svSquare) == l {
+		xSquare := dot(px, px)
+		for i := 0; i < l; i++ {
+			py := model.svSpace[model.sV[i]:]
+			kvalue[i] = model.kernelFromDot(dot(px, py), xSquare, model.svSquare[i])
+		}
+		return kvalue
+	}
+
+	for i := 0; i < l; i++ {
+		var idx_y int = model.sV[i]
+		py := model.svSpace[idx_y:]
+		kvalue[i] = computeKernelValue(px, py, model.param)
+	}
+	return kvalue
+}
+
+/**
+ * Same as svKernelValues, but for the dense vector x, with kvalue holding l values.  The dot product with a
+ * SV only visits the dimensions of the SV, instead of merging the dimensions of both vectors.
+ */
+func (model Model) svKernelValuesDense(x []float64, kvalue []float64) []float64 {
+	var l int = model.l
+
+	if model.param.KernelType == PRECOMPUTED { // x holds K(x,x_1) ... K(x,x_l) as dimensions 1 ... l
+		for i := 0; i < l; i++ {
+			serial := int(model.svSpace[model.sV[i]].value)
+			if serial >= 1 && serial <= len(x) {
+				kvalue[i] = x[serial-1]
+			} else {
+				kvalue[i] = 0
+			}
+		}
+		return kvalue
+	}
+
+	var xSquare float64 = 0
+	for _, value := range x {
+		xSquare += value * value
+	}
+
+	for i := 0; i < l; i++ {
+		var xy float64 = 0
+		for idx := model.sV[i]; model.svSpace[idx].index != -1; idx++ {
+			node := model.svSpace[idx]
+			if node.index >= 1 && node.index <= len(x) {
+				xy += x[node.index-1] * node.value
+			}
+		}
+
+		var ySquare float64 = 0
+		if model.param.KernelType == RBF {
+			if len(model.svSquare) == l {
+				ySquare = model.svSquare[i]
+			} else {
+				py := model.svSpace[model.sV[i]:]
+				ySquare = dot(py, py)
+			}
+		}
+		kvalue[i] = model.kernelFromDot(xy, xSquare, ySquare)
+	}
+	return kvalue
+}
+
+/**
+ * Returns the decision values from the kernel values of the test vector and the SVs
+ */
+func (model Model) svDecisionValues(kvalue []float64) (decisionValues []float64) {
 	switch model.param.SvmType {
 	case ONE_CLASS, EPSILON_SVR, NU_SVR:
 		var svCoef []float64 = model.svCoef[0]
 
 		var sum float64 = 0
 		for i := 0; i < model.l; i++ {
-			var idx_y int = model.sV[i]
-			py := model.svSpace[idx_y:]
-			sum += svCoef[i] * computeKernelValue(px, py, model.param)
+			sum += svCoef[i] * kvalue[i]
 		}
 		sum -= model.rho[0]
 
 		decisionValues = append(decisionValues, sum)
 
-		if model.param.SvmType == ONE_CLASS {
-			if sum > 0 {
-				returnValue = 1 // return
-			} else {
-				returnValue = -1 // return
-			}
-			return // returnValue, decisionValues
-		} else {
-			returnValue = sum
-			return // returnValue, decisionValues
-		}
-
 	case C_SVC, NU_SVC:
 		var nrClass int = model.nrClass
-		var l int = model.l
-
-		kvalue := make([]float64, l)
-		for i := 0; i < l; i++ {
-			var idx_y int = model.sV[i]
-			py := model.svSpace[idx_y:]
-			kvalue[i] = computeKernelValue(px, py, model.param)
-		}
 
 		start := make([]int, nrClass)
 		start[0] = 0
@@ -85,11 +311,6 @@ func (model Model) PredictValues(x map[int]float64) (returnValue float64, decisi
 			start[i] = start[i-1] + model.nSV[i-1]
 		}
 
-		vote := make([]int, nrClass)
-		for i := 0; i < nrClass; i++ {
-			vote[i] = 0
-		}
-
 		var p int = 0
 		for i := 0; i < nrClass; i++ {
 			for j := i + 1; j < nrClass; j++ {
@@ -111,7 +332,37 @@ func (model Model) PredictValues(x map[int]float64) (returnValue float64, decisi
 				}
 				sum -= model.rho[p]
 				decisionValues = append(decisionValues, sum)
-				if sum > 0 {
+				p++
+			}
+		}
+	}
+
+	return // decisionValues
+}
+
+/**
+ * Returns the prediction from the decision values: the label with the most votes for classification,
+ * +1/-1 for one-class SVM, and the function value for regression
+ */
+func (model Model) decide(decisionValues []float64) float64 {
+	switch model.param.SvmType {
+	case ONE_CLASS:
+		if decisionValues[0] > 0 {
+			return 1
+		}
+		return -1
+
+	case EPSILON_SVR, NU_SVR:
+		return decisionValues[0]
+
+	case C_SVC, NU_SVC:
+		var nrClass int = model.nrClass
+
+		vote := make([]int, nrClass)
+		var p int = 0
+		for i := 0; i < nrClass; i++ {
+			for j := i + 1; j < nrClass; j++ {
+				if decisionValues[p] > 0 {
 					vote[i]++
 				} else {
 					vote[j]++
@@ -127,11 +378,10 @@ func (model Model) PredictValues(x map[int]float64) (returnValue float64, decisi
 			}
 		}
 
-		returnValue = float64(model.label[maxIdx])
-		return // returnValue, decisionValues
+		return float64(model.label[maxIdx])
 	}
 
-	return
+	return 0
 }
 
 /**
@@ -150,3 +400,27 @@ func (model Model) Predict(x map[int]float64) float64 {
 
 	return predict
 }
+
+/**
+ * Same as Predict, for a dense test vector whose element x[k] is dimension k+1.  Each SV is
+ * evaluated against x directly, which is faster than a sparse vector when x has many non-zero
+ * dimensions.
+ */
+func (model Model) PredictDense(x []float64) float64 {
+	predict, _ := model.predictValuesDense(x, nil)
+	return predict
+}
+
+/**
+ * Same as PredictValues, for a dense test vector
+ */
+func (model Model) PredictValuesDense(x []float64) (returnValue float64, decisionValues []float64) {
+	return model.predictValuesDense(x, nil)
+}
+
+/**
+ * Same as PredictProbability, for a dense test vector
+ */
+func (model Model) PredictProbabilityDense(x []float64) (returnValue float64, probabilityEstimate []float64) {
+	return model.predictProbabilityDense(x, nil)
+}
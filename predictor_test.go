@@ -0,0 +1,243 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the prediction, and benchmarks with a model of many SVs
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const (
+	benchSVs       = 50000 // number of SVs of the benchmark model
+	benchDimension = 200   // dimensions of the SVs and the test vectors
+	benchNonZero   = 20    // non-zero dimensions of each of them
+)
+
+/**
+ * Returns a random sparse vector of benchNonZero dimensions out of benchDimension, ending with index -1
+ */
+func benchVector(r *rand.Rand) []snode {
+	px := make([]snode, 0, benchNonZero+1)
+	for _, k := range r.Perm(benchDimension)[:benchNonZero] {
+		px = append(px, snode{index: k + 1})
+	}
+	for i := 1; i < len(px); i++ { // ascending indices
+		for j := i; j > 0 && px[j].index < px[j-1].index; j-- {
+			px[j], px[j-1] = px[j-1], px[j]
+		}
+	}
+	for k := range px {
+		px[k].value = r.Float64()
+	}
+	return append(px, snode{index: -1})
+}
+
+/**
+ * Returns a two-class RBF model with benchSVs random SVs, as if it had been trained
+ */
+func benchModel(r *rand.Rand) *Model {
+	param := NewParameter()
+	param.Gamma = 1.0 / benchDimension
+	model := NewModel(param)
+	model.l = benchSVs
+	model.nrClass = 2
+	model.label = []int{1, -1}
+	model.nSV = []int{benchSVs / 2, benchSVs - benchSVs/2}
+	model.rho = []float64{r.NormFloat64()}
+	model.sV = make([]int, benchSVs)
+	model.svCoef = [][]float64{make([]float64, benchSVs)}
+	for i := 0; i < benchSVs; i++ {
+		model.sV[i] = len(model.svSpace)
+		model.svSpace = append(model.svSpace, benchVector(r)...)
+		model.svCoef[0][i] = r.Float64()
+		if i >= model.nSV[0] {
+			model.svCoef[0][i] = -model.svCoef[0][i]
+		}
+	}
+	model.computeSVSquare()
+	return model
+}
+
+/**
+ * Vectors of a problem set that is not read strictly keep the order of their dimensions, and must be
+ * predicted as their sorted map is, whether they are scattered into a dense vector or not
+ */
+func TestPredictUnsortedVector(t *testing.T) {
+	for _, kernelType := range []int{LINEAR, RBF, POLY} {
+		param := quietParameter(C_SVC)
+		param.KernelType = kernelType
+		model := mustTrain(t, mustProblem(t, blobsText(1, 60, 5, 2), param), param)
+
+		lines := []string{
+			"1 5:-0.13 2:-1.2 3:0.67 1:-0.18",
+			"1 4:0.5 0:0.25 2:1.5",            // dimension 0 is not scattered
+			"1 3:0.4 100000:1 1:-0.7",         // too large to scatter
+			"1 1:-0.18 2:-1.2 3:0.67 5:-0.13", // already sorted
+			"1",                               // empty
+		}
+		prob := mustProblem(t, strings.Join(lines, "\n"), nil)
+
+		batch, batchValues := model.PredictValuesProblem(prob)
+		var i int = 0
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetSparseLine()
+			nodes := append([]snode(nil), x.nodes...)
+			sorted := MapToSnode(x.Map())
+
+			want, wantValues := model.predictValues(sorted, nil)
+			got, gotValues := model.PredictValuesSparse(x)
+			if got != want || !nearSlices(gotValues, wantValues, 1e-12) {
+				t.Errorf("kernel %d, line %q: PredictValuesSparse = %v %v, want %v %v", kernelType, lines[i], got, gotValues, want, wantValues)
+			}
+			if batch[i] != want || !nearSlices(batchValues[i], wantValues, 1e-12) {
+				t.Errorf("kernel %d, line %q: PredictValuesProblem = %v %v, want %v %v", kernelType, lines[i], batch[i], batchValues[i], want, wantValues)
+			}
+			for k := range nodes {
+				if x.nodes[k] != nodes[k] {
+					t.Fatalf("kernel %d, line %q: the vector was modified", kernelType, lines[i])
+				}
+			}
+			i++
+		}
+	}
+}
+
+/**
+ * The dense, sparse, map, and batch predictions of the same vectors agree
+ */
+func TestPredictPathsAgree(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		text := problemText(svmType)
+		model := mustTrain(t, mustProblem(t, text, param), param)
+		prob := mustProblem(t, text, nil)
+
+		batch := model.PredictProblem(prob)
+		var i int = 0
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetLine()
+			want, wantValues := model.PredictValues(x)
+
+			dense := make([]float64, 4)
+			for index, value := range x {
+				dense[index-1] = value
+			}
+			got, gotValues := model.PredictValuesDense(dense)
+			if !near(got, want, 1e-12) || !nearSlices(gotValues, wantValues, 1e-12) {
+				t.Fatalf("svm type %d, instance %d: PredictValuesDense = %v %v, want %v %v", svmType, i, got, gotValues, want, wantValues)
+			}
+			if got := model.PredictSparse(NewSparseVectorFromMap(x)); !near(got, want, 1e-12) {
+				t.Fatalf("svm type %d, instance %d: PredictSparse = %v, want %v", svmType, i, got, want)
+			}
+			if !near(batch[i], want, 1e-12) {
+				t.Fatalf("svm type %d, instance %d: PredictProblem = %v, want %v", svmType, i, batch[i], want)
+			}
+			i++
+		}
+	}
+}
+
+/**
+ * The SV squared norms are cached for the RBF kernel only, by Train and by reading a model, and the
+ * decision value is that of the kernel values computed from scratch
+ */
+func TestSVSquare(t *testing.T) {
+	for _, kernelType := range []int{LINEAR, RBF, POLY} {
+		param := quietParameter(EPSILON_SVR)
+		param.KernelType = kernelType
+		prob := mustProblem(t, problemText(EPSILON_SVR), param)
+		model := mustTrain(t, prob, param)
+		read, err := ReadModelFrom(strings.NewReader(modelText(t, model)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range []*Model{model, read} {
+			if kernelType != RBF {
+				if m.svSquare != nil {
+					t.Errorf("kernel %d: SV squared norms %v", kernelType, m.svSquare)
+				}
+			} else if len(m.svSquare) != m.l {
+				t.Fatalf("%d SV squared norms of %d SVs", len(m.svSquare), m.l)
+			}
+			for i := range m.svSquare {
+				sv := m.svSpace[m.sV[i]:]
+				if m.svSquare[i] != dot(sv, sv) {
+					t.Errorf("SV %d: squared norm %v, want %v", i, m.svSquare[i], dot(sv, sv))
+				}
+			}
+
+			for prob.Begin(); !prob.Done(); prob.Next() {
+				_, x := prob.GetSparseLine()
+				var want float64 = -m.rho[0]
+				for i := 0; i < m.l; i++ {
+					want += m.svCoef[0][i] * computeKernelValue(x.nodes, m.svSpace[m.sV[i]:], m.param)
+				}
+				if _, values := m.PredictValuesSparse(x); !near(values[0], want, 1e-12) {
+					t.Fatalf("kernel %d: decision value %v, want %v", kernelType, values[0], want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPredictSparse(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	model := benchModel(r)
+	x := SparseVector{nodes: benchVector(r)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		model.PredictSparse(x)
+	}
+}
+
+func BenchmarkPredictDense(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	model := benchModel(r)
+	x := make([]float64, benchDimension)
+	for _, node := range benchVector(r) {
+		if node.index != -1 {
+			x[node.index-1] = node.value
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		model.PredictDense(x)
+	}
+}
+
+func BenchmarkPredictBatch(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	model := benchModel(r)
+	pxs := make([][]snode, 64)
+	for i := range pxs {
+		pxs[i] = benchVector(r)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		model.predictValuesBatch(pxs)
+	}
+}
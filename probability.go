@@ -19,7 +19,7 @@
 package libSvm
 
 import (
-	"fmt"
+	"context"
 	"math"
 	"math/rand"
 	"time"
@@ -37,12 +37,32 @@ import (
 
 */
 func (model Model) PredictProbability(x map[int]float64) (returnValue float64, probabilityEstimate []float64) {
+	return model.predictProbability(MapToSnode(x), nil)
+}
+
+/**
+ * Same as PredictProbability, but for the vector px, with the buffers of scratch like predictValues
+ */
+func (model Model) predictProbability(px []snode, scratch *predictScratch) (returnValue float64, probabilityEstimate []float64) {
+	return model.probability(model.decisionValues(px, scratch))
+}
+
+/**
+ * Same as predictProbability, but for the dense vector x, whose element k is dimension k+1
+ */
+func (model Model) predictProbabilityDense(x []float64, scratch *predictScratch) (returnValue float64, probabilityEstimate []float64) {
+	return model.probability(model.decisionValuesDense(x, scratch))
+}
+
+/**
+ * Returns the prediction and probability estimates from the decision values of the test vector
+ */
+func (model Model) probability(decisionValues []float64) (returnValue float64, probabilityEstimate []float64) {
 
 	if (model.param.SvmType == C_SVC || model.param.SvmType == NU_SVC) &&
 		model.probA != nil && model.probB != nil {
 
 		var nrClass int = model.nrClass
-		_, decisionValues := model.PredictValues(x)
 
 		var minProb float64 = 1e-7
 
@@ -61,7 +81,7 @@ func (model Model) PredictProbability(x map[int]float64) (returnValue float64, p
 			}
 		}
 
-		probabilityEstimate = multiClassProbability(nrClass, pairWiseProb)
+		probabilityEstimate = multiClassProbability(nrClass, pairWiseProb, model.param.observer())
 
 		var maxIdx int = 0
 		for i := 1; i < nrClass; i++ {
@@ -74,7 +94,7 @@ func (model Model) PredictProbability(x map[int]float64) (returnValue float64, p
 		return // returnValue, probabilityEstimates
 	} else {
 		probabilityEstimate = nil
-		returnValue = model.Predict(x)
+		returnValue = model.decide(decisionValues)
 		return // returnValue, probabilityEstimates
 	}
 
@@ -89,7 +109,7 @@ func sigmoidPredict(decisionValue, A, B float64) float64 {
 	}
 }
 
-func multiClassProbability(k int, r [][]float64) []float64 {
+func multiClassProbability(k int, r [][]float64, observer TrainingObserver) []float64 {
 	p := make([]float64, k)
 
 	Q := make([][]float64, k)
@@ -149,7 +169,7 @@ func multiClassProbability(k int, r [][]float64) []float64 {
 	}
 
 	if iter >= maxIter {
-		fmt.Println("Exceeds max_iter in multiclass_prob")
+		observer.Warning("Exceeds max_iter in multiclass_prob")
 	}
 
 	return p
@@ -159,8 +179,9 @@ func multiClassProbability(k int, r [][]float64) []float64 {
  * Cross-validation decision values for probability estimates
  * @return probA, probB
  */
-func binarySvcProbability(prob *Problem, param *Parameter, Cp, Cn float64) (probA float64, probB float64) {
+func binarySvcProbability(ctx context.Context, prob *Problem, param *Parameter, Cp, Cn float64, labels []int) (probA float64, probB float64, err error) {
 	var nrFold int = 5
+	observer := param.observer()
 	perm := make([]int, prob.l)
 	decisionValues := make([]float64, prob.l)
 
@@ -176,6 +197,12 @@ func binarySvcProbability(prob *Problem, param *Parameter, Cp, Cn float64) (prob
 	}
 
 	for i := 0; i < nrFold; i++ {
+		if err = ctx.Err(); err != nil {
+			err = &TrainingStoppedError{Err: err}
+			return // probA, probB, err
+		}
+		observer.ProbabilityProgress(ProbabilityInfo{Labels: labels, Fold: i, NrFold: nrFold})
+
 		begin := i * prob.l / nrFold
 		end := (i + 1) * prob.l / nrFold
 
@@ -184,16 +211,25 @@ func binarySvcProbability(prob *Problem, param *Parameter, Cp, Cn float64) (prob
 		subProb.l = prob.l - (end - begin)
 		subProb.x = make([]int, subProb.l)
 		subProb.y = make([]float64, subProb.l)
+		if prob.w != nil {
+			subProb.w = make([]float64, subProb.l)
+		}
 
 		var k int = 0
 		for j := 0; j < begin; j++ {
 			subProb.x[k] = prob.x[perm[j]]
 			subProb.y[k] = prob.y[perm[j]]
+			if prob.w != nil {
+				subProb.w[k] = prob.w[perm[j]]
+			}
 			k++
 		}
 		for j := end; j < prob.l; j++ {
 			subProb.x[k] = prob.x[perm[j]]
 			subProb.y[k] = prob.y[perm[j]]
+			if prob.w != nil {
+				subProb.w[k] = prob.w[perm[j]]
+			}
 			k++
 		}
 
@@ -231,7 +267,9 @@ func binarySvcProbability(prob *Problem, param *Parameter, Cp, Cn float64) (prob
 			subParam.Weight[0] = Cp
 			subParam.Weight[1] = Cn
 			subModel := NewModel(&subParam)
-			subModel.Train(&subProb)
+			if err = subModel.train(ctx, &subProb); err != nil {
+				return // probA, probB, err
+			}
 			for j := begin; j < end; j++ {
 				idx := prob.x[perm[j]]
 				x := SnodeToMap(prob.xSpace[idx:])
@@ -241,11 +279,12 @@ func binarySvcProbability(prob *Problem, param *Parameter, Cp, Cn float64) (prob
 		}
 	}
 
-	probA, probB = sigmoidTrain(prob.l, decisionValues, prob.y)
-	return // probA, probB
+	probA, probB = sigmoidTrain(prob.l, decisionValues, prob.y, observer)
+	observer.ProbabilityProgress(ProbabilityInfo{Labels: labels, Fold: nrFold, NrFold: nrFold, A: probA, B: probB})
+	return // probA, probB, err
 }
 
-func sigmoidTrain(l int, decisionValues, labels []float64) (probA float64, probB float64) {
+func sigmoidTrain(l int, decisionValues, labels []float64, observer TrainingObserver) (probA float64, probB float64) {
 	var prior1 float64 = 0
 	var prior0 float64 = 0
 	probA = 0
@@ -353,14 +392,14 @@ func sigmoidTrain(l int, decisionValues, labels []float64) (probA float64, probB
 		}
 
 		if stepsize < minStep {
-			fmt.Printf("Line search fails in two-class probability estimates\n")
+			observer.Warning("Line search fails in two-class probability estimates")
 			break
 		}
 
 	}
 
 	if iter >= maxIter {
-		fmt.Printf("Reaching maximal iterations in two-class probability estimates\n")
+		observer.Warning("Reaching maximal iterations in two-class probability estimates")
 	}
 
 	return // probA, probB
@@ -369,14 +408,22 @@ func sigmoidTrain(l int, decisionValues, labels []float64) (probA float64, probB
 /**
  * Return parameter of a Laplace distribution
  */
-func svrProbability(prob *Problem, param *Parameter) float64 {
+func svrProbability(ctx context.Context, prob *Problem, param *Parameter) (float64, error) {
 	var nrFold int = 5
 	var mae float64 = 0
 
 	var newParam Parameter = *param
 	newParam.Probability = false
 
-	ymv := CrossValidation(prob, &newParam, nrFold)
+	observer := param.observer()
+	progress := func(fold int) {
+		observer.ProbabilityProgress(ProbabilityInfo{Fold: fold, NrFold: nrFold})
+	}
+
+	ymv, err := crossValidation(ctx, prob, &newParam, nrFold, progress, nil)
+	if err != nil {
+		return 0, err
+	}
 
 	for i := 0; i < prob.l; i++ {
 		ymv[i] = prob.y[i] - ymv[i]
@@ -396,7 +443,7 @@ func svrProbability(prob *Problem, param *Parameter) float64 {
 		}
 	}
 	mae /= float64(prob.l - count)
-	fmt.Printf("Prob. model for test data: target value = predicted value + z,\nz: Laplace distribution e^(-|z|/sigma)/(2sigma),sigma= %g\n", mae)
+	observer.ProbabilityProgress(ProbabilityInfo{Fold: nrFold, NrFold: nrFold, Sigma: mae})
 
-	return mae
+	return mae, nil
 }
@@ -21,7 +21,9 @@ package libSvm
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -36,6 +38,9 @@ type Problem struct {
 	y      []float64 // labels
 	x      []int     // starting indices in xSpace defining SVs
 	xSpace []snode   // SV coeffs
+	w      []float64 // instance weights scaling C (nil if every instance has weight 1)
+	maxIdx int       // largest feature index, from which the default gamma is set
+	lines  []int     // line of each instance in the data it was read from (nil if not read)
 	i      int       // counter for iterator
 }
 
@@ -45,56 +50,161 @@ func NewProblem(file string, param *Parameter) (*Problem, error) {
 	return prob, err
 }
 
+/**
+ * Creates a problem set from the training data read from r.  Gzip compressed data is detected and decompressed.
+ */
+func NewProblemFrom(r io.Reader, param *Parameter) (*Problem, error) {
+	prob := &Problem{l: 0, i: 0}
+	_, err := prob.readFrom(r, param)
+	return prob, err
+}
+
 func (problem *Problem) Read(file string, param *Parameter) error { // reads the problem from the specified file
 	f, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("Fail to open file %s\n", file)
+		return fmt.Errorf("Fail to open file %s: %w", file, err)
 	}
 
 	defer f.Close() // close f on method return
 
+	prob, err := NewProblemFrom(f, param)
+	*problem = *prob
+	return withFileName(err, file)
+}
+
+/**
+ * Reads the problem set from r, and returns the number of bytes read.  Gzip compressed data is detected
+ * and decompressed.  Since no parameter is given, the input is not read strictly, and the format of the
+ * precomputed kernel is checked and the default gamma set by Parameter.Validate, before training.
+ */
+func (problem *Problem) ReadFrom(r io.Reader) (int64, error) {
+	return problem.readFrom(r, nil)
+}
+
+func (problem *Problem) readFrom(r io.Reader, param *Parameter) (int64, error) {
+	cr := &countingReader{r: r}
+	if err := problem.read(cr, param); err != nil {
+		return cr.n, err
+	}
+	return cr.n, problem.complete(param)
+}
+
+/**
+ * Reads the labels and vectors from r, and the largest feature index.  A line may have a
+ * positive instance weight between the label and the first index:value pair, as in "1 0.5 1:0.3 2:1",
+ * and the instances without one have weight 1.  Blank lines and lines holding only a comment are skipped.  If param.StrictInput is set (param may be nil), the feature
+ * indices of each vector must be ascending and unique (unless param.SortIndices is also set, in which
+ * case they are sorted), and the labels and values must be finite.
+ */
+func (problem *Problem) read(r io.Reader, param *Parameter) error {
+	strict := param != nil && param.StrictInput
+	sortIndices := strict && param.SortIndices
+
+	reader, err := newLineReader(r)
+	if err != nil {
+		return err
+	}
+
 	problem.y = nil
 	problem.x = nil
 	problem.xSpace = nil
+	problem.w = nil
+	problem.lines = nil
 
-	reader := bufio.NewReader(f)
 	var max_idx int = 0
 	var l int = 0
+	var weights []float64
+	var weighted bool = false
 
 	for {
-		line, err := readline(reader)
-		if err != nil {
+		line, err := reader.next()
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			return err
 		}
-		problem.x = append(problem.x, len(problem.xSpace))
 
 		lineSansComments := strings.Split(line, "#") // remove any comments
 
-		tokens := strings.Fields(lineSansComments[0]) // get all the word tokens (seperated by white spaces)
+		tokens, columns := splitFields(lineSansComments[0]) // get all the word tokens (seperated by white spaces)
+		if len(tokens) == 0 {
+			continue // blank or comment-only line
+		}
+
+		start := len(problem.xSpace)
+		problem.x = append(problem.x, start)
+		problem.lines = append(problem.lines, reader.line)
+
 		if label, err := strconv.ParseFloat(tokens[0], 64); err == nil {
+			if strict && !isFinite(label) {
+				return newParseError(reader.line, columns[0], nil, "Label %v is not finite", tokens[0])
+			}
 			problem.y = append(problem.y, label)
 		} else {
-			return fmt.Errorf("Fail to parse label\n")
+			return newParseError(reader.line, columns[0], err, "Fail to parse label")
 		}
 
-		space := tokens[1:]
-		for _, w := range space {
-			if len(w) > 0 {
-				node := strings.Split(w, ":")
-				if len(node) > 1 {
-					var index int
-					var value float64
-					if index, err = strconv.Atoi(node[0]); err != nil {
-						return fmt.Errorf("Fail to parse index from token %v\n", w)
-					}
-					if value, err = strconv.ParseFloat(node[1], 64); err != nil {
-						return fmt.Errorf("Fail to parse value from token %v\n", w)
+		var first int = 1 // token of the first index:value pair
+		var weight float64 = 1
+		if len(tokens) > 1 && !strings.Contains(tokens[1], ":") {
+			if weight, err = strconv.ParseFloat(tokens[1], 64); err != nil {
+				return newParseError(reader.line, columns[1], err, "Fail to parse weight")
+			}
+			if !(weight > 0) || !isFinite(weight) {
+				return newParseError(reader.line, columns[1], nil, "Weight %v is not positive and finite", tokens[1])
+			}
+			first = 2
+			weighted = true
+		}
+		weights = append(weights, weight)
+
+		var unsorted bool = false
+
+		for k := first; k < len(tokens); k++ {
+			w := tokens[k]
+			node := strings.Split(w, ":")
+			if len(node) != 2 {
+				return newParseError(reader.line, columns[k], nil, "Fail to parse index:value from token %v", w)
+			}
+			var index int
+			var value float64
+			if index, err = strconv.Atoi(node[0]); err != nil {
+				return newParseError(reader.line, columns[k], err, "Fail to parse index from token %v", w)
+			}
+			if index < 0 {
+				return newParseError(reader.line, columns[k], nil, "Negative index in token %v", w)
+			}
+			if value, err = strconv.ParseFloat(node[1], 64); err != nil {
+				return newParseError(reader.line, columns[k]+len(node[0])+1, err, "Fail to parse value from token %v", w)
+			}
+
+			if strict {
+				if !isFinite(value) {
+					return newParseError(reader.line, columns[k]+len(node[0])+1, nil, "Value in token %v is not finite", w)
+				}
+				if last := len(problem.xSpace) - 1; last >= start && index <= problem.xSpace[last].index {
+					if index == problem.xSpace[last].index {
+						return newParseError(reader.line, columns[k], nil, "Duplicate index %d", index)
 					}
-					problem.xSpace = append(problem.xSpace, snode{index: index, value: value})
-					if index > max_idx {
-						max_idx = index
+					if !sortIndices {
+						return newParseError(reader.line, columns[k], nil, "Index %d is not in ascending order", index)
 					}
+					unsorted = true
+				}
+			}
+
+			problem.xSpace = append(problem.xSpace, snode{index: index, value: value})
+			if index > max_idx {
+				max_idx = index
+			}
+		}
 
+		if unsorted {
+			nodes := problem.xSpace[start:]
+			sort.Slice(nodes, func(a, b int) bool { return nodes[a].index < nodes[b].index })
+			for k := 1; k < len(nodes); k++ {
+				if nodes[k].index == nodes[k-1].index {
+					return newParseError(reader.line, 0, nil, "Duplicate index %d", nodes[k].index)
 				}
 			}
 		}
@@ -103,14 +213,101 @@ func (problem *Problem) Read(file string, param *Parameter) error { // reads the
 		l++
 	}
 	problem.l = l
+	problem.maxIdx = max_idx
+	if weighted {
+		problem.w = weights
+	}
+
+	return nil
+}
+
+/**
+ * Writes the problem set to w in the LIBSVM data format, and returns the number of bytes written
+ */
+func (problem *Problem) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	output := bufio.NewWriter(cw)
+
+	for i := 0; i < problem.l; i++ {
+		output.WriteString(strconv.FormatFloat(problem.y[i], 'g', -1, 64) + " ") // labels and weights are written exactly
+		if problem.w != nil {
+			output.WriteString(strconv.FormatFloat(problem.w[i], 'g', -1, 64) + " ")
+		}
+		for idx := problem.x[i]; problem.xSpace[idx].index != -1; idx++ {
+			fmt.Fprintf(output, "%d:%.6g ", problem.xSpace[idx].index, problem.xSpace[idx].value)
+		}
+		output.WriteString("\n")
+	}
 
-	if param.Gamma == 0 && max_idx > 0 {
-		param.Gamma = 1.0 / float64(max_idx)
+	err := output.Flush()
+	return cw.n, err
+}
+
+/**
+ * Checks the problem set against the parameter, and defaults gamma from the largest feature index.
+ * Nothing is done without a parameter: Parameter.Validate does the same before training.
+ */
+func (problem *Problem) complete(param *Parameter) error {
+	if param == nil {
+		return nil
 	}
+	if param.KernelType == PRECOMPUTED {
+		if err := problem.checkPrecomputed(); err != nil {
+			return err
+		}
+	}
+	problem.defaultGamma(param)
+	return nil
+}
 
+/**
+ * Checks that each vector is of the form 0:sample_serial_number 1:K(x,x_1) ... n:K(x,x_n) for the
+ * precomputed kernel, where n is at least the number of instances and the largest serial number, since
+ * the kernel value of a serial number is looked up at its offset in the vector
+ */
+func (problem *Problem) checkPrecomputed() error {
+	var n int = problem.l
+	for i := 0; i < problem.l; i++ {
+		idx := problem.x[i]
+		if problem.xSpace[idx].index != 0 {
+			return newParseError(problem.line(i), 0, nil, "Wrong input format: first column must be 0:sample_serial_number")
+		}
+		serial := int(problem.xSpace[idx].value)
+		if serial <= 0 || serial > problem.maxIdx {
+			return newParseError(problem.line(i), 0, nil, "Wrong input format: sample_serial_number out of range")
+		}
+		n = maxi(n, serial)
+	}
+	for i := 0; i < problem.l; i++ {
+		idx := problem.x[i]
+		for k := 1; k <= n; k++ {
+			if problem.xSpace[idx+k].index != k {
+				return newParseError(problem.line(i), 0, nil, "Wrong input format: kernel values must be given for indices 1 to %d in order", n)
+			}
+		}
+	}
 	return nil
 }
 
+/**
+ * Returns the line instance i was read from, or its position in the problem set if it was not read
+ */
+func (problem *Problem) line(i int) int {
+	if problem.lines == nil {
+		return i + 1
+	}
+	return problem.lines[i]
+}
+
+/**
+ * Sets the gamma of param to 1/(largest feature index) if it has not been specified
+ */
+func (problem *Problem) defaultGamma(param *Parameter) {
+	if param.Gamma == 0 && problem.maxIdx > 0 {
+		param.Gamma = 1.0 / float64(problem.maxIdx)
+	}
+}
+
 /**
  * Initialize the start of iterating through the labels and vectors in the problem set
  */
@@ -148,6 +345,65 @@ func (problem *Problem) GetLine() (y float64, x map[int]float64) {
 	return // y, x
 }
 
+/**
+ * Return the weight of the current instance in the problem set (1 if no weights are set)
+ */
+func (problem *Problem) GetWeight() float64 {
+	return problem.weight(problem.i)
+}
+
+/**
+ * Returns a copy of the instance weights, or nil if no weights are set
+ */
+func (problem *Problem) Weights() []float64 {
+	if problem.w == nil {
+		return nil
+	}
+	return append([]float64(nil), problem.w...)
+}
+
+/**
+ * Sets the weight of each instance, which scales its C in training (LIBSVM's instance weights).  The
+ * weights must be positive and finite, one for each instance.  A nil slice gives every instance weight 1.
+ */
+func (problem *Problem) SetWeights(w []float64) error {
+	if w == nil {
+		problem.w = nil
+		return nil
+	}
+	if len(w) != problem.l {
+		return fmt.Errorf("%d weights for %d instances", len(w), problem.l)
+	}
+	for i, weight := range w {
+		if !(weight > 0) || !isFinite(weight) {
+			return fmt.Errorf("weight %v of instance %d is not positive and finite", weight, i+1)
+		}
+	}
+	problem.w = append([]float64(nil), w...)
+	return nil
+}
+
+func (problem *Problem) weight(i int) float64 {
+	if problem.w == nil {
+		return 1
+	}
+	return problem.w[i]
+}
+
+/**
+ * Returns the sum of the instance weights, which is the problem size if no weights are set
+ */
+func (problem *Problem) totalWeight() float64 {
+	if problem.w == nil {
+		return float64(problem.l)
+	}
+	var sum float64 = 0
+	for _, weight := range problem.w {
+		sum += weight
+	}
+	return sum
+}
+
 /**
  * Returns number of label and vectors in the problem set
  * @return problem set size
@@ -0,0 +1,111 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of reading the LIBSVM data format
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func strictParameter(sortIndices bool) *Parameter {
+	param := quietParameter(C_SVC)
+	param.StrictInput = true
+	param.SortIndices = sortIndices
+	return param
+}
+
+/**
+ * Blank lines and lines holding only a comment are skipped, and the instances keep their line numbers
+ */
+func TestReadSkipsBlankLines(t *testing.T) {
+	text := "# header\n\n1 1:1 2:2 # comment\n   \n\t\n-1 3:1\n#\n1\n"
+	for _, param := range []*Parameter{nil, strictParameter(false)} {
+		prob := mustProblem(t, text, param)
+		if prob.l != 3 || !reflect.DeepEqual(prob.lines, []int{3, 6, 8}) || !reflect.DeepEqual(prob.y, []float64{1, -1, 1}) {
+			t.Errorf("read %d instances %v at lines %v", prob.l, prob.y, prob.lines)
+		}
+		if want := mustProblem(t, "1 1:1 2:2\n-1 3:1\n1\n", param); !sameProblem(prob, want) {
+			t.Errorf("read %+v, want %+v", *prob, *want)
+		}
+	}
+}
+
+/**
+ * The strict reader rejects indices that are not ascending, duplicate indices and values that are not
+ * finite, at their line and column, and sorts the indices if asked to
+ */
+func TestReadStrict(t *testing.T) {
+	tests := []struct {
+		text         string
+		line, column int // of the error of the strict reader, or 0
+		sorted       int // line of the error when the indices are sorted, or 0
+	}{
+		{"1 1:1 3:1\n\n-1 2:1 1:1\n", 3, 8, 0},
+		{"1 1:1 1:2\n", 1, 7, 1},
+		{"1 3:1 1:1 3:2\n", 1, 7, 1},
+		{"1 1:1\n-1 1:NaN\n", 2, 6, 2},
+		{"1 1:+Inf\n", 1, 5, 1},
+		{"-Inf 1:1\n", 1, 1, 1},
+		{"1 1:1 2:1e308 3:-0\n", 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		if _, err := NewProblemFrom(strings.NewReader(test.text), nil); err != nil {
+			t.Errorf("%q read leniently: %v", test.text, err)
+		}
+
+		_, err := NewProblemFrom(strings.NewReader(test.text), strictParameter(false))
+		if test.line == 0 && err != nil || test.line != 0 && !isParseError(err, test.line, test.column) {
+			t.Errorf("%q: %v, want a parse error at %d:%d", test.text, err, test.line, test.column)
+		}
+
+		_, err = NewProblemFrom(strings.NewReader(test.text), strictParameter(true))
+		if test.sorted == 0 && err != nil {
+			t.Errorf("%q sorted: %v", test.text, err)
+		}
+		var pe *ParseError
+		if test.sorted != 0 && (!errors.As(err, &pe) || pe.Line != test.sorted) {
+			t.Errorf("%q sorted: %v, want a parse error at line %d", test.text, err, test.sorted)
+		}
+	}
+}
+
+/**
+ * The sorted indices give the problem set of the sorted text
+ */
+func TestReadSortIndices(t *testing.T) {
+	prob := mustProblem(t, "1 3:3 1:1 2:2\n-1 5:1 4:2\n", strictParameter(true))
+	want := mustProblem(t, "1 1:1 2:2 3:3\n-1 4:2 5:1\n", nil)
+	if !sameProblem(prob, want) {
+		t.Errorf("read %+v, want %+v", *prob, *want)
+	}
+}
+
+/**
+ * The lenient reader keeps the values that are not finite
+ */
+func TestReadLenient(t *testing.T) {
+	prob := mustProblem(t, "1 1:NaN 2:Inf\n", nil)
+	if x := SnodeToMap(prob.xSpace); !math.IsNaN(x[1]) || !math.IsInf(x[2], 1) {
+		t.Errorf("read %v", x)
+	}
+}
@@ -20,45 +20,78 @@
 package libSvm
 
 type matrixQ interface {
-	getQ(i, l int) []cacheDataType // Returns all the Q matrix values for row i
-	getQD() []float64              // Returns the Q matrix values for the diagonal
-	computeQ(i, j int) float64     // Returns the Q matrix value at (i,j)
-	showCacheStats()
+	getQD() []float64          // Returns the Q matrix values for the diagonal
+	computeQ(i, j int) float64 // Returns the Q matrix value at (i,j)
+	swapIndex(i, j int)        // Swaps rows/columns i and j (used by the solver when shrinking)
+	getStats() qStats          // Returns the kernel evaluations and cache statistics
+}
+
+/**
+ * A Q matrix whose rows are cached as T (see Parameter.CachePrecision)
+ */
+type qRows[T cacheValue] interface {
+	matrixQ
+	getQ(i, l int) []T // Returns the first l Q matrix values for row i
+}
+
+/**
+ * Work done by a Q matrix and its caches
+ */
+type qStats struct {
+	kernelEvals int // number of kernel evaluations
+	hits        int // rows found in the cache of the Q matrix
+	misses      int // rows (or parts of rows) that had to be computed
+	evictions   int // rows evicted from the cache of the Q matrix
+	sharedHits  int // kernel values found in the kernel cache shared with the other subproblems
+}
+
+/**
+ * Returns the work done since base was taken from the same Q matrix
+ */
+func (stats qStats) since(base qStats) qStats {
+	return qStats{kernelEvals: stats.kernelEvals - base.kernelEvals, hits: stats.hits - base.hits, misses: stats.misses - base.misses,
+		evictions: stats.evictions - base.evictions, sharedHits: stats.sharedHits - base.sharedHits}
+}
+
+func (c *cache[T]) stats(kernelEvals, sharedHits int) qStats {
+	return qStats{kernelEvals: kernelEvals, hits: c.hits, misses: c.misses, evictions: c.evictions, sharedHits: sharedHits}
 }
 
 /**
  * Q matrix for support vector classification (SVC)
  */
-type svcQ struct {
-	y         []int8
-	qd        []float64
-	kernel    kernelFunction
-	parRunner parallelRunner
-	colCache  *cache
+type svcQ[T cacheValue] struct {
+	y           []int8
+	qd          []float64
+	kernel      kernelFunction
+	kernels     *kernelCache[T] // kernel values shared with the other subproblems (nil if none)
+	ids         []int           // index in kernels of each variable
+	pool        *workerPool
+	colCache    *cache[T]
+	kernelEvals int // number of kernel evaluations
+	sharedHits  int // kernel values found in kernels
 }
 
 /**
  * Returns the diagonal values
  */
-func (q *svcQ) getQD() []float64 {
+func (q *svcQ[T]) getQD() []float64 {
 	return q.qd
 }
 
 /**
  * Get Q values for row i
  */
-func (q *svcQ) getQ(i, l int) []cacheDataType {
-
-	rcq, newData := q.colCache.getData(i)
-	if newData {
-		run := func(tid, start, end int) {
-			for j := start; j < end; j++ { // compute column elements
-				rcq[j] = cacheDataType(q.y[i]*q.y[j]) * cacheDataType(q.kernel.compute(i, j))
-			}
+func (q *svcQ[T]) getQ(i, l int) []T {
+
+	rcq, valid := q.colCache.getData(i, l)
+	if valid < l {
+		evals, shared := computeKernelRow(q.kernels, q.ids, q.kernel, q.pool, i, valid, l, rcq)
+		q.kernelEvals += evals
+		q.sharedHits += shared
+		for j := valid; j < l; j++ { // compute column elements
+			rcq[j] *= T(q.y[i] * q.y[j])
 		}
-
-		q.parRunner.run(run)
-		q.parRunner.waitAll()
 	}
 
 	return rcq
@@ -67,21 +100,45 @@ func (q *svcQ) getQ(i, l int) []cacheDataType {
 /**
  * Computes the Q[i,j] entry
  */
-func (q *svcQ) computeQ(i, j int) float64 {
+func (q *svcQ[T]) computeQ(i, j int) float64 {
+	q.kernelEvals++
 	return float64(q.y[i]*q.y[j]) * q.kernel.compute(i, j)
 }
 
 /**
- * Prints out the cache performance statistics
+ * Swaps rows/columns i and j
+ */
+func (q *svcQ[T]) swapIndex(i, j int) {
+	q.colCache.swapIndex(i, j)
+	q.kernel.swapIndex(i, j)
+	if q.ids != nil {
+		q.ids[i], q.ids[j] = q.ids[j], q.ids[i]
+	}
+	q.y[i], q.y[j] = q.y[j], q.y[i]
+	q.qd[i], q.qd[j] = q.qd[j], q.qd[i]
+}
+
+/**
+ * Returns the kernel evaluations and cache statistics
+ */
+func (q *svcQ[T]) getStats() qStats {
+	return q.colCache.stats(q.kernelEvals, q.sharedHits)
+}
+
+/**
+ * Returns the Q matrix of SVC, cached with the precision of param
  */
-func (q *svcQ) showCacheStats() {
-	q.colCache.stats()
+func newSVCQ(prob *Problem, param *Parameter, y []int8) (matrixQ, error) {
+	if param.CachePrecision == DOUBLE_PRECISION {
+		return newSVCQOf[float64](prob, param, y)
+	}
+	return newSVCQOf[float32](prob, param, y)
 }
 
-func newSVCQ(prob *Problem, param *Parameter, y []int8) *svcQ {
+func newSVCQOf[T cacheValue](prob *Problem, param *Parameter, y []int8) (matrixQ, error) {
 	kernel, err := newKernel(prob, param)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	qd := make([]float64, prob.l)
@@ -89,41 +146,45 @@ func newSVCQ(prob *Problem, param *Parameter, y []int8) *svcQ {
 		qd[i] = kernel.compute(i, i)
 	}
 
-	return &svcQ{y: y, qd: qd, kernel: kernel, parRunner: newParallelRunner(prob.l, param.NumCPU), colCache: newCache(prob.l, prob.l, param.CacheSize)}
+	qy := make([]int8, prob.l) // keep our own copy of y, since swapIndex() reorders it
+	copy(qy, y)
+
+	kernels := kernelCacheOf[T](param)
+	return &svcQ[T]{y: qy, qd: qd, kernel: kernel, kernels: kernels, ids: kernels.indices(prob), pool: param.pool, colCache: newCache[T](prob.l, prob.l, param.CacheSize, param.CachePolicy),
+		kernelEvals: prob.l}, nil
 }
 
 /**
  * Q matrix for one-class support vector machines: determines if new data is likely to be in one class (novality detection).
  */
-type oneClassQ struct {
-	qd        []float64
-	kernel    kernelFunction
-	parRunner parallelRunner
-	colCache  *cache
+type oneClassQ[T cacheValue] struct {
+	qd          []float64
+	kernel      kernelFunction
+	kernels     *kernelCache[T] // kernel values shared with the other subproblems (nil if none)
+	ids         []int           // index in kernels of each variable
+	pool        *workerPool
+	colCache    *cache[T]
+	kernelEvals int // number of kernel evaluations
+	sharedHits  int // kernel values found in kernels
 }
 
 /**
  * Returns the diagonal values
  */
-func (q *oneClassQ) getQD() []float64 {
+func (q *oneClassQ[T]) getQD() []float64 {
 	return q.qd
 }
 
 /**
  * Get Q values for row i
  */
-func (q *oneClassQ) getQ(i, l int) []cacheDataType {
-
-	rcq, newData := q.colCache.getData(i)
-	if newData {
-		run := func(tid, start, end int) {
-			for j := start; j < end; j++ { // compute column elements
-				rcq[j] = cacheDataType(q.kernel.compute(i, j))
-			}
-		}
+func (q *oneClassQ[T]) getQ(i, l int) []T {
 
-		q.parRunner.run(run)
-		q.parRunner.waitAll()
+	rcq, valid := q.colCache.getData(i, l)
+	if valid < l {
+		evals, shared := computeKernelRow(q.kernels, q.ids, q.kernel, q.pool, i, valid, l, rcq)
+		q.kernelEvals += evals
+		q.sharedHits += shared
 	}
 
 	return rcq
@@ -132,21 +193,44 @@ func (q *oneClassQ) getQ(i, l int) []cacheDataType {
 /**
  * Computes the Q[i,j] entry
  */
-func (q *oneClassQ) computeQ(i, j int) float64 {
+func (q *oneClassQ[T]) computeQ(i, j int) float64 {
+	q.kernelEvals++
 	return q.kernel.compute(i, j)
 }
 
 /**
- * Prints out the cache performance statistics
+ * Swaps rows/columns i and j
  */
-func (q *oneClassQ) showCacheStats() {
-	q.colCache.stats()
+func (q *oneClassQ[T]) swapIndex(i, j int) {
+	q.colCache.swapIndex(i, j)
+	q.kernel.swapIndex(i, j)
+	if q.ids != nil {
+		q.ids[i], q.ids[j] = q.ids[j], q.ids[i]
+	}
+	q.qd[i], q.qd[j] = q.qd[j], q.qd[i]
+}
+
+/**
+ * Returns the kernel evaluations and cache statistics
+ */
+func (q *oneClassQ[T]) getStats() qStats {
+	return q.colCache.stats(q.kernelEvals, q.sharedHits)
 }
 
-func newOneClassQ(prob *Problem, param *Parameter) *oneClassQ {
+/**
+ * Returns the Q matrix of one-class SVM, cached with the precision of param
+ */
+func newOneClassQ(prob *Problem, param *Parameter) (matrixQ, error) {
+	if param.CachePrecision == DOUBLE_PRECISION {
+		return newOneClassQOf[float64](prob, param)
+	}
+	return newOneClassQOf[float32](prob, param)
+}
+
+func newOneClassQOf[T cacheValue](prob *Problem, param *Parameter) (matrixQ, error) {
 	kernel, err := newKernel(prob, param)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	qd := make([]float64, prob.l)
@@ -154,96 +238,120 @@ func newOneClassQ(prob *Problem, param *Parameter) *oneClassQ {
 		qd[i] = kernel.compute(i, i)
 	}
 
-	return &oneClassQ{qd: qd, kernel: kernel, parRunner: newParallelRunner(prob.l, param.NumCPU), colCache: newCache(prob.l, prob.l, param.CacheSize)}
+	kernels := kernelCacheOf[T](param)
+	return &oneClassQ[T]{qd: qd, kernel: kernel, kernels: kernels, ids: kernels.indices(prob), pool: param.pool, colCache: newCache[T](prob.l, prob.l, param.CacheSize, param.CachePolicy),
+		kernelEvals: prob.l}, nil
 }
 
 /**
  * Q matrix for support vector regression
  */
-type svrQ struct {
-	l         int       // problem size
-	qd        []float64 // Q matrix diagonial values
-	kernel    kernelFunction
-	parRunner parallelRunner
-	colCache  *cache
-}
-
-func (q *svrQ) real_idx(i int) int {
-	if i < q.l {
-		return i
-	} else {
-		return i - q.l
-	}
-}
-
-func (q *svrQ) sign(i int) float64 {
-	if i < q.l {
-		return 1
-	} else {
-		return -1
-	}
+type svrQ[T cacheValue] struct {
+	l           int       // problem size
+	qd          []float64 // Q matrix diagonial values
+	signs       []int8    // +1 for alpha, -1 for alpha*
+	index       []int     // real index in [0,l) of each of the 2*l variables
+	buffer      [2][]T
+	nextBuffer  int
+	kernel      kernelFunction
+	kernels     *kernelCache[T] // kernel values shared with the other subproblems (nil if none)
+	ids         []int           // index in kernels of each real index
+	pool        *workerPool
+	colCache    *cache[T] // caches the kernel rows for the real indices [0,l)
+	kernelEvals int       // number of kernel evaluations
+	sharedHits  int       // kernel values found in kernels
 }
 
 /**
  * Returns the diagonal values
  */
-func (q *svrQ) getQD() []float64 {
+func (q *svrQ[T]) getQD() []float64 {
 	return q.qd
 }
 
 /**
  * Get Q values for row i
  */
-func (q *svrQ) getQ(i, l int) []cacheDataType { // @param l is 2 * q.l
-	sign_i := q.sign(i)
-	real_i := q.real_idx(i)
-
-	// NOTE: query cache with "real_i" since cache stores [0,l)
-	rcq, newData := q.colCache.getData(real_i)
-	if newData {
-		run := func(tid, start, end int) {
-			for j := start; j < end; j++ { // compute column elements
-				t := q.kernel.compute(real_i, j)
-				rcq[j] = cacheDataType(sign_i * q.sign(j) * t)
-				rcq[j+q.l] = cacheDataType(sign_i * q.sign(j+l) * t)
-			}
-		}
+func (q *svrQ[T]) getQ(i, l int) []T { // @param l is at most 2 * q.l
+	real_i := q.index[i]
+
+	// NOTE: query cache with "real_i" since cache stores kernel rows [0,l)
+	data, valid := q.colCache.getData(real_i, q.l)
+	if valid < q.l {
+		evals, shared := computeKernelRow(q.kernels, q.ids, q.kernel, q.pool, real_i, valid, q.l, data)
+		q.kernelEvals += evals
+		q.sharedHits += shared
+	}
 
-		q.parRunner.run(run)
-		q.parRunner.waitAll()
+	// reorder and copy into one of the two buffers, so the rows for i and j can be used at the same time
+	buf := q.buffer[q.nextBuffer]
+	q.nextBuffer = 1 - q.nextBuffer
+	sign_i := T(q.signs[i])
+	for j := 0; j < l; j++ {
+		buf[j] = sign_i * T(q.signs[j]) * data[q.index[j]]
 	}
-	return rcq
+
+	return buf
 }
 
 /**
  * Computes the Q[i,j] entry
  */
-func (q *svrQ) computeQ(i, j int) float64 {
-	real_i := q.real_idx(i)
-	real_j := q.real_idx(j)
+func (q *svrQ[T]) computeQ(i, j int) float64 {
+	q.kernelEvals++
+	return float64(q.signs[i]) * float64(q.signs[j]) * q.kernel.compute(q.index[i], q.index[j])
+}
+
+/**
+ * Swaps rows/columns i and j.  The kernel cache is untouched since it is indexed by the real index.
+ */
+func (q *svrQ[T]) swapIndex(i, j int) {
+	q.signs[i], q.signs[j] = q.signs[j], q.signs[i]
+	q.index[i], q.index[j] = q.index[j], q.index[i]
+	q.qd[i], q.qd[j] = q.qd[j], q.qd[i]
+}
 
-	return q.sign(i) * q.sign(j) * q.kernel.compute(real_i, real_j)
+/**
+ * Returns the kernel evaluations and cache statistics
+ */
+func (q *svrQ[T]) getStats() qStats {
+	return q.colCache.stats(q.kernelEvals, q.sharedHits)
 }
 
 /**
- * Prints out the cache performance statistics
+ * Returns the Q matrix of SVR, cached with the precision of param
  */
-func (q *svrQ) showCacheStats() {
-	q.colCache.stats()
+func newSVRQ(prob *Problem, param *Parameter) (matrixQ, error) {
+	if param.CachePrecision == DOUBLE_PRECISION {
+		return newSVRQOf[float64](prob, param)
+	}
+	return newSVRQOf[float32](prob, param)
 }
 
-func newSVRQ(prob *Problem, param *Parameter) *svrQ {
+func newSVRQOf[T cacheValue](prob *Problem, param *Parameter) (matrixQ, error) {
 	kernel, err := newKernel(prob, param)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	l := prob.l
 	qd := make([]float64, 2*l)
+	signs := make([]int8, 2*l)
+	index := make([]int, 2*l)
 	for i := 0; i < l; i++ {
+		signs[i] = 1
+		signs[i+l] = -1
+		index[i] = i
+		index[i+l] = i
 		qd[i] = kernel.compute(i, i)
 		qd[i+l] = qd[i]
 	}
 
-	return &svrQ{l: l, qd: qd, kernel: kernel, parRunner: newParallelRunner(prob.l, param.NumCPU), colCache: newCache(prob.l, 2*prob.l, param.CacheSize)}
+	kernels := kernelCacheOf[T](param)
+	q := &svrQ[T]{l: l, qd: qd, signs: signs, index: index, kernel: kernel, kernels: kernels, ids: kernels.indices(prob),
+		pool: param.pool, colCache: newCache[T](prob.l, prob.l, param.CacheSize, param.CachePolicy), kernelEvals: l}
+	q.buffer[0] = make([]T, 2*l)
+	q.buffer[1] = make([]T, 2*l)
+
+	return q, nil
 }
@@ -0,0 +1,63 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Summary of a training run
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"time"
+)
+
+/**
+ * Summarizes a training run.  Subproblems holds one entry per one-vs-one pair for classification
+ * (in the order of the model's decision functions), or the single regression or one-class problem.
+ * Subproblems trained for the probability calibration are not included.
+ */
+type TrainReport struct {
+	Subproblems       []SubproblemInfo
+	TotalSV           int
+	MaxIterReached    bool          // at least one subproblem stopped at the maximum number of iterations
+	Duration          time.Duration // wall time of the whole training, including the probability calibration
+	KernelEvaluations int           // summed over the subproblems
+	CacheHits         int
+	CacheMisses       int
+	CacheEvictions    int
+	SharedCacheHits   int
+}
+
+func newTrainReport(subproblems []SubproblemInfo, totalSV int) *TrainReport {
+	report := &TrainReport{Subproblems: subproblems, TotalSV: totalSV}
+	for _, info := range subproblems {
+		if info.MaxIterReached {
+			report.MaxIterReached = true
+		}
+		report.KernelEvaluations += info.KernelEvaluations
+		report.CacheHits += info.CacheHits
+		report.CacheMisses += info.CacheMisses
+		report.CacheEvictions += info.CacheEvictions
+		report.SharedCacheHits += info.SharedCacheHits
+	}
+	return report
+}
+
+/**
+ * Returns the report of the training that built the model, or nil if the model was read from a file or
+ * its last training was stopped or failed.  A training rejected by Parameter.Validate leaves the model as it was.
+ */
+func (model *Model) Report() *TrainReport {
+	return model.report
+}
@@ -0,0 +1,112 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the training report
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+/**
+ * The report describes the subproblems of the model, without those of the probability calibration, and
+ * sums their statistics
+ */
+func TestTrainReport(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Probability = svmType != ONE_CLASS
+		model := mustTrain(t, mustProblem(t, problemText(svmType), param), param)
+		report := model.Report()
+
+		if report.TotalSV != model.l || report.MaxIterReached || report.Duration <= 0 {
+			t.Errorf("svm type %d: %d SVs (max iterations %v) in %v, want %d", svmType, report.TotalSV, report.MaxIterReached, report.Duration, model.l)
+		}
+		if len(report.Subproblems) != model.nrDecisionValues() {
+			t.Fatalf("svm type %d: %d subproblems, want %d", svmType, len(report.Subproblems), model.nrDecisionValues())
+		}
+
+		var sum TrainReport
+		var nSV int = 0
+		for p, info := range report.Subproblems {
+			if info.SvmType != svmType || info.Rho != model.rho[p] || info.Iterations <= 0 || info.Duration <= 0 {
+				t.Errorf("svm type %d, subproblem %d: %+v, want rho %v", svmType, p, info, model.rho[p])
+			}
+			if info.NSV <= 0 || info.NBSV > info.NSV || info.KernelEvaluations <= 0 || info.CacheMisses <= 0 {
+				t.Errorf("svm type %d, subproblem %d: %+v", svmType, p, info)
+			}
+			if (svmType == C_SVC || svmType == EPSILON_SVR) && (info.Cp != param.C || info.Cn != param.C) {
+				t.Errorf("svm type %d, subproblem %d: Cp %v Cn %v, want %v", svmType, p, info.Cp, info.Cn, param.C)
+			}
+			nSV += info.NSV
+			sum.KernelEvaluations += info.KernelEvaluations
+			sum.CacheHits += info.CacheHits
+			sum.CacheMisses += info.CacheMisses
+			sum.CacheEvictions += info.CacheEvictions
+			sum.SharedCacheHits += info.SharedCacheHits
+		}
+		if svmType == C_SVC || svmType == NU_SVC {
+			if nSV < model.l { // an SV belongs to at least one pair
+				t.Errorf("svm type %d: %d SVs in the subproblems, and %d in the model", svmType, nSV, model.l)
+			}
+		} else if nSV != model.l {
+			t.Errorf("svm type %d: %d SVs in the subproblem, and %d in the model", svmType, nSV, model.l)
+		}
+		if sum.KernelEvaluations != report.KernelEvaluations || sum.CacheHits != report.CacheHits ||
+			sum.CacheMisses != report.CacheMisses || sum.CacheEvictions != report.CacheEvictions ||
+			sum.SharedCacheHits != report.SharedCacheHits {
+			t.Errorf("svm type %d: report %+v, want the sums %+v", svmType, *report, sum)
+		}
+	}
+}
+
+/**
+ * A model read from a file, or whose training was stopped, has no report, and invalid parameters leave
+ * the report of the model
+ */
+func TestTrainReportMissing(t *testing.T) {
+	param := quietParameter(C_SVC)
+	prob := mustProblem(t, problemText(C_SVC), param)
+	model := mustTrain(t, prob, param)
+
+	var buf bytes.Buffer
+	if _, err := model.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadModelFrom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Report() != nil {
+		t.Errorf("report %+v of a model read back", *read.Report())
+	}
+
+	report := model.Report()
+	model.param.MaxIter = -1
+	if err := model.Train(prob); err == nil || model.Report() != report {
+		t.Errorf("report %+v after invalid parameters (%v)", model.Report(), err)
+	}
+
+	model.param.MaxIter = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := model.TrainContext(ctx, prob); err == nil || model.Report() != nil {
+		t.Errorf("report %+v after a stopped training (%v)", model.Report(), err)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Scales the features (and optionally the labels) of a problem set, like LIBSVM's svm-scale
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+/**
+ * Scales each feature linearly to [Lower,Upper] from the range seen in the training data, or to zero mean
+ * and unit variance if Standardize is set.  As with svm-scale, a feature that is constant in the training
+ * data (or was never seen) is dropped, and a zero feature may be scaled to a non-zero value.
+ */
+type Scaler struct {
+	Lower, Upper   float64 // range of the scaled features
+	ScaleY         bool    // should the labels be scaled too?
+	YLower, YUpper float64 // range of the scaled labels
+	Standardize    bool    // scale the features to zero mean and unit variance instead of to [Lower,Upper]
+
+	featureMin []float64 // indexed by feature index; the mean when standardizing
+	featureMax []float64 // indexed by feature index; the standard deviation when standardizing
+	yMin       float64
+	yMax       float64
+}
+
+/**
+ * Returns a scaler to the range [-1,1], the svm-scale default
+ */
+func NewScaler() *Scaler {
+	return &Scaler{Lower: -1, Upper: 1}
+}
+
+/**
+ * Learns the range (or the mean and standard deviation) of each feature, and the range of the labels if
+ * ScaleY is set, from the problem set
+ */
+func (s *Scaler) Fit(prob *Problem) error {
+	if !s.Standardize && s.Lower >= s.Upper {
+		return fmt.Errorf("inconsistent lower/upper specification")
+	}
+	if s.ScaleY && s.YLower >= s.YUpper {
+		return fmt.Errorf("inconsistent y lower/upper specification")
+	}
+
+	var maxIdx int = 0
+	for _, node := range prob.xSpace {
+		if node.index > maxIdx {
+			maxIdx = node.index
+		}
+	}
+
+	s.featureMin = make([]float64, maxIdx+1)
+	s.featureMax = make([]float64, maxIdx+1)
+	count := make([]int, maxIdx+1) // number of vectors with an explicit value for each feature
+
+	if s.Standardize {
+		sum := s.featureMin   // becomes the mean
+		sumSq := s.featureMax // becomes the standard deviation
+		for i := 0; i < prob.l; i++ {
+			for idx := prob.x[i]; prob.xSpace[idx].index != -1; idx++ {
+				node := prob.xSpace[idx]
+				sum[node.index] += node.value
+				sumSq[node.index] += node.value * node.value
+				count[node.index]++
+			}
+		}
+		for j := 1; j <= maxIdx; j++ {
+			mean := sum[j] / float64(prob.l)
+			variance := sumSq[j]/float64(prob.l) - mean*mean
+			s.featureMin[j] = mean
+			s.featureMax[j] = math.Sqrt(maxf(variance, 0))
+		}
+	} else {
+		for j := 0; j <= maxIdx; j++ {
+			s.featureMin[j] = math.MaxFloat64
+			s.featureMax[j] = -math.MaxFloat64
+		}
+		for i := 0; i < prob.l; i++ {
+			for idx := prob.x[i]; prob.xSpace[idx].index != -1; idx++ {
+				node := prob.xSpace[idx]
+				s.featureMin[node.index] = minf(s.featureMin[node.index], node.value)
+				s.featureMax[node.index] = maxf(s.featureMax[node.index], node.value)
+				count[node.index]++
+			}
+		}
+		for j := 0; j <= maxIdx; j++ {
+			if count[j] == 0 {
+				s.featureMin[j], s.featureMax[j] = 0, 0
+			} else if count[j] < prob.l { // some vectors hold an implicit zero
+				s.featureMin[j] = minf(s.featureMin[j], 0)
+				s.featureMax[j] = maxf(s.featureMax[j], 0)
+			}
+		}
+	}
+
+	if s.ScaleY {
+		s.yMin = math.MaxFloat64
+		s.yMax = -math.MaxFloat64
+		for i := 0; i < prob.l; i++ {
+			s.yMin = minf(s.yMin, prob.y[i])
+			s.yMax = maxf(s.yMax, prob.y[i])
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Returns the scaled value of feature index, and false if the feature is dropped
+ */
+func (s *Scaler) scaleValue(index int, value float64) (float64, bool) {
+	if index <= 0 || index >= len(s.featureMin) {
+		return 0, false
+	}
+
+	if s.Standardize {
+		if s.featureMax[index] == 0 {
+			return 0, false
+		}
+		return (value - s.featureMin[index]) / s.featureMax[index], true
+	}
+
+	fmin, fmax := s.featureMin[index], s.featureMax[index]
+	switch {
+	case fmin == fmax:
+		return 0, false
+	case value == fmin:
+		return s.Lower, true
+	case value == fmax:
+		return s.Upper, true
+	}
+	return s.Lower + (s.Upper-s.Lower)*(value-fmin)/(fmax-fmin), true
+}
+
+/**
+ * Scales the vector x (snodes ending with index -1, in any order), and returns the non-zero scaled values
+ */
+func (s *Scaler) scaleSnodes(x []snode) []snode {
+	values := make([]float64, len(s.featureMin)) // x scattered by feature index
+	for k := 0; x[k].index != -1; k++ {
+		if x[k].index < len(values) {
+			values[x[k].index] = x[k].value
+		}
+	}
+
+	var out []snode
+	for j := 1; j < len(values); j++ {
+		if scaled, ok := s.scaleValue(j, values[j]); ok && scaled != 0 {
+			out = append(out, snode{index: j, value: scaled})
+		}
+	}
+	return append(out, snode{index: -1})
+}
+
+/**
+ * Scales a label, if ScaleY is set
+ */
+func (s *Scaler) ScaleLabel(y float64) float64 {
+	if !s.ScaleY {
+		return y
+	}
+	switch y {
+	case s.yMin:
+		return s.YLower
+	case s.yMax:
+		return s.YUpper
+	}
+	return s.YLower + (s.YUpper-s.YLower)*(y-s.yMin)/(s.yMax-s.yMin)
+}
+
+/**
+ * Scales a test vector (map of dimension/value) for prediction
+ */
+func (s *Scaler) Scale(x map[int]float64) map[int]float64 {
+	return SnodeToMap(s.scaleSnodes(MapToSnode(x)))
+}
+
+/**
+ * Returns a new problem set with the scaled labels and vectors of prob
+ */
+func (s *Scaler) ScaleProblem(prob *Problem) *Problem {
+	scaled := &Problem{l: prob.l, maxIdx: prob.maxIdx}
+	scaled.x = make([]int, prob.l)
+	scaled.y = make([]float64, prob.l)
+	for i := 0; i < prob.l; i++ {
+		scaled.y[i] = s.ScaleLabel(prob.y[i])
+		scaled.x[i] = len(scaled.xSpace)
+		scaled.xSpace = append(scaled.xSpace, s.scaleSnodes(prob.xSpace[prob.x[i]:])...)
+	}
+	if prob.w != nil {
+		scaled.w = append([]float64(nil), prob.w...)
+	}
+	if prob.lines != nil {
+		scaled.lines = append([]int(nil), prob.lines...)
+	}
+	return scaled
+}
+
+/**
+ * Saves the scaling parameters to the specified file (svm-scale -s)
+ */
+func (s *Scaler) Dump(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("Fail to open file %s: %w", file, err)
+	}
+
+	if _, err = s.WriteTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Fail to write file %s: %w", file, err)
+	}
+
+	return f.Close()
+}
+
+/**
+ * Writes the scaling parameters to w in the svm-scale range file format, and returns the number of bytes
+ * written.  Standardizing scalers are written in a "z" section instead of the "x" section, which
+ * svm-scale does not understand.
+ */
+func (s *Scaler) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	output := bufio.NewWriter(cw)
+
+	if s.ScaleY {
+		fmt.Fprintf(output, "y\n%.17g %.17g\n%.17g %.17g\n", s.YLower, s.YUpper, s.yMin, s.yMax)
+	}
+
+	if s.Standardize {
+		output.WriteString("z\n")
+		for j := 1; j < len(s.featureMin); j++ {
+			if s.featureMax[j] != 0 {
+				fmt.Fprintf(output, "%d %.17g %.17g\n", j, s.featureMin[j], s.featureMax[j])
+			}
+		}
+	} else {
+		fmt.Fprintf(output, "x\n%.17g %.17g\n", s.Lower, s.Upper)
+		for j := 1; j < len(s.featureMin); j++ {
+			if s.featureMin[j] != s.featureMax[j] {
+				fmt.Fprintf(output, "%d %.17g %.17g\n", j, s.featureMin[j], s.featureMax[j])
+			}
+		}
+	}
+
+	err := output.Flush()
+	return cw.n, err
+}
+
+/**
+ * Restores the scaling parameters from the specified file (svm-scale -r)
+ */
+func NewScalerFromFile(file string) (*Scaler, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Fail to open file %s: %w", file, err)
+	}
+
+	defer f.Close() // close f on method return
+
+	s, err := ReadScalerFrom(f)
+	return s, withFileName(err, file)
+}
+
+/**
+ * Reads the scaling parameters from r in the svm-scale range file format
+ */
+func ReadScalerFrom(r io.Reader) (*Scaler, error) {
+	reader, err := newLineReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewScaler()
+
+	// reads the next line, which must hold n numbers
+	readNumbers := func(n int) ([]float64, error) {
+		line, err := reader.next()
+		if err == io.EOF {
+			return nil, newParseError(reader.line+1, 0, nil, "Unexpected end of file")
+		} else if err != nil {
+			return nil, err
+		}
+		tokens, columns := splitFields(line)
+		if len(tokens) != n {
+			return nil, newParseError(reader.line, 0, nil, "Expected %d numbers", n)
+		}
+		numbers := make([]float64, n)
+		for k, token := range tokens {
+			if numbers[k], err = strconv.ParseFloat(token, 64); err != nil {
+				return nil, newParseError(reader.line, columns[k], err, "Fail to parse number from token %v", token)
+			}
+		}
+		return numbers, nil
+	}
+
+	var mins, maxs []float64
+	for {
+		line, err := reader.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		tokens, columns := splitFields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch {
+		case tokens[0] == "y" && len(tokens) == 1:
+			s.ScaleY = true
+			limits, err := readNumbers(2)
+			if err != nil {
+				return nil, err
+			}
+			s.YLower, s.YUpper = limits[0], limits[1]
+			ranges, err := readNumbers(2)
+			if err != nil {
+				return nil, err
+			}
+			s.yMin, s.yMax = ranges[0], ranges[1]
+
+		case tokens[0] == "x" && len(tokens) == 1:
+			limits, err := readNumbers(2)
+			if err != nil {
+				return nil, err
+			}
+			s.Lower, s.Upper = limits[0], limits[1]
+
+		case tokens[0] == "z" && len(tokens) == 1:
+			s.Standardize = true
+
+		case len(tokens) == 3:
+			index, err := strconv.Atoi(tokens[0])
+			if err != nil || index <= 0 {
+				return nil, newParseError(reader.line, columns[0], err, "Fail to parse feature index from token %v", tokens[0])
+			}
+			var fmin, fmax float64
+			if fmin, err = strconv.ParseFloat(tokens[1], 64); err != nil {
+				return nil, newParseError(reader.line, columns[1], err, "Fail to parse number from token %v", tokens[1])
+			}
+			if fmax, err = strconv.ParseFloat(tokens[2], 64); err != nil {
+				return nil, newParseError(reader.line, columns[2], err, "Fail to parse number from token %v", tokens[2])
+			}
+			for len(mins) <= index {
+				mins = append(mins, 0)
+				maxs = append(maxs, 0)
+			}
+			mins[index], maxs[index] = fmin, fmax
+
+		default:
+			return nil, newParseError(reader.line, columns[0], nil, "unknown text in range file: [%s]", line)
+		}
+	}
+
+	s.featureMin = mins
+	s.featureMax = maxs
+	return s, nil
+}
@@ -0,0 +1,171 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the feature scaling
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/**
+ * A vector of a problem set that is not read strictly is scaled as its sorted map is
+ */
+func TestScaleUnsortedVector(t *testing.T) {
+	train := mustProblem(t, "1 1:0 2:0 3:0 5:0\n-1 1:2 2:4 3:6 5:8\n", nil)
+	test := mustProblem(t, "1 5:2 2:1 3:3 1:1\n1 3:3 1:1\n", nil)
+
+	s := NewScaler()
+	if err := s.Fit(train); err != nil {
+		t.Fatal(err)
+	}
+	scaled := s.ScaleProblem(test)
+
+	want := []map[int]float64{ // features 1 and 3 are scaled to 0, and omitted
+		{2: -0.5, 5: -0.5},
+		{2: -1, 5: -1},
+	}
+	var i int = 0
+	for scaled.Begin(); !scaled.Done(); scaled.Next() {
+		_, x := scaled.GetLine()
+		if !reflect.DeepEqual(x, want[i]) {
+			t.Errorf("instance %d scaled to %v, want %v", i, x, want[i])
+		}
+		i++
+	}
+}
+
+const scalerTrainText = "1 1:1 2:10 3:7\n2 1:3 3:7\n3 1:5 2:30 3:7\n"
+
+/**
+ * The features are scaled to [Lower,Upper] from their range including the implicit zeros, constant
+ * features are dropped, and the labels are scaled if ScaleY is set
+ */
+func TestScalerRange(t *testing.T) {
+	s := &Scaler{Lower: 0, Upper: 1, ScaleY: true, YLower: -1, YUpper: 1}
+	if err := s.Fit(mustProblem(t, scalerTrainText, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if x := s.Scale(map[int]float64{1: 3, 2: 15, 3: 100, 9: 1}); !reflect.DeepEqual(x, map[int]float64{1: 0.5, 2: 0.5}) {
+		t.Errorf("scaled to %v", x)
+	}
+	if x := s.Scale(map[int]float64{1: 7, 2: -30}); !reflect.DeepEqual(x, map[int]float64{1: 1.5, 2: -1}) {
+		t.Errorf("scaled to %v", x)
+	}
+	for y, want := range map[float64]float64{1: -1, 2: 0, 3: 1, 5: 3} {
+		if got := s.ScaleLabel(y); got != want {
+			t.Errorf("label %v scaled to %v, want %v", y, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "y\n-1 1\n1 3\nx\n0 1\n1 1 5\n2 0 30\n"; buf.String() != want {
+		t.Errorf("range file\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	if err := (&Scaler{Lower: 1, Upper: 1}).Fit(mustProblem(t, scalerTrainText, nil)); err == nil {
+		t.Errorf("Fit with lower == upper")
+	}
+	if err := (&Scaler{Lower: 0, Upper: 1, ScaleY: true, YLower: 1, YUpper: 0}).Fit(mustProblem(t, scalerTrainText, nil)); err == nil {
+		t.Errorf("Fit with y lower > y upper")
+	}
+}
+
+/**
+ * Standardizing scales the features to zero mean and unit variance, counting the implicit zeros
+ */
+func TestScalerStandardize(t *testing.T) {
+	s := &Scaler{Standardize: true}
+	if err := s.Fit(mustProblem(t, scalerTrainText, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	mean2 := 40.0 / 3
+	std2 := math.Sqrt((100+900)/3.0 - mean2*mean2)
+	x := s.Scale(map[int]float64{1: 5, 3: 7})
+	if len(x) != 2 || !near(x[1], 2/math.Sqrt(8.0/3), 1e-12) || !near(x[2], -mean2/std2, 1e-12) {
+		t.Errorf("standardized to %v", x)
+	}
+}
+
+/**
+ * A scaler written and read back, through a writer or a file, scales the same
+ */
+func TestScalerRoundTrip(t *testing.T) {
+	prob := mustProblem(t, blobsText(1, 50, 4, 3)+"2 7:3\n", nil)
+	for _, s := range []*Scaler{NewScaler(), {Lower: 0, Upper: 1, ScaleY: true, YLower: 0, YUpper: 10}, {Standardize: true}} {
+		if err := s.Fit(prob); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := s.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		text := buf.String()
+
+		file := filepath.Join(t.TempDir(), "range")
+		if err := s.Dump(file); err != nil {
+			t.Fatal(err)
+		}
+		fromFile, err := NewScalerFromFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadScalerFrom(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := s.ScaleProblem(prob)
+		for _, r := range []*Scaler{read, fromFile} {
+			if got := r.ScaleProblem(prob); !sameProblem(got, want) {
+				t.Errorf("range file\n%s\nread back scales to %+v, want %+v", text, *got, *want)
+			}
+		}
+	}
+}
+
+/**
+ * A malformed range file is a ParseError at the offending line
+ */
+func TestScalerParseErrors(t *testing.T) {
+	tests := []struct {
+		text         string
+		line, column int
+	}{
+		{"x\n-1 1\n1 0 a\n", 3, 5},
+		{"x\n-1\n", 2, 0},
+		{"x\n", 2, 0},
+		{"x\n-1 1\n0 0 1\n", 3, 1},
+		{"y\n0 1\n\nfoo\n", 3, 0},
+		{"x\n-1 1\nfoo bar\n", 3, 1},
+	}
+	for _, test := range tests {
+		if _, err := ReadScalerFrom(strings.NewReader(test.text)); !isParseError(err, test.line, test.column) {
+			t.Errorf("%q: %v, want a parse error at %d:%d", test.text, err, test.line, test.column)
+		}
+	}
+}
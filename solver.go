@@ -20,7 +20,7 @@
 package libSvm
 
 import (
-	"fmt"
+	"context"
 	"math"
 )
 
@@ -30,25 +30,35 @@ const (
 	FREE        = iota
 )
 
-type solver struct {
-	l            int     // problem size
-	q            matrixQ // Q matrix
+type solver[T cacheValue] struct {
+	l            int      // problem size
+	q            qRows[T] // Q matrix
 	p            []float64
 	gradient     []float64
+	gradientBar  []float64 // gradient contribution from the variables at the upper bound
 	alpha        []float64
 	alpha_status []int8
 	qd           []float64 // Q matrix diagonial values
 	penaltyCp    float64
 	penaltyCn    float64
-	y            []int8 // class, +1 or -1
+	weight       []float64 // instance weights scaling penaltyCp and penaltyCn (nil if every weight is 1)
+	y            []int8    // class, +1 or -1
 	eps          float64
-	workingSet   workingSetSelecter
-	parRunner    parallelRunner
-	quietMode    bool
-	numCPU       int
+	workingSet   workingSetSelecter[T]
+	pool         *workerPool // runs the loops over the variables in parallel
+	observer     TrainingObserver
+	shrinking    bool    // use the shrinking heuristics
+	maxIter      int     // maximum number of iterations, or 0 for the default
+	maxViolation float64 // maximal violation of the optimality conditions found by the last working set selection
+	unshrink     bool    // true once the active set has been reset close to convergence
+	activeSize   int     // variables [0,activeSize) are in the active set
+	activeSet    []int   // original index of each (possibly swapped) variable
+	alphaOut     []float64
+	restoreOrder bool   // put the Q matrix back in the original order of the variables when done, so it can be reused
+	statsBase    qStats // statistics of a reused Q matrix before this training
 }
 
-func (solver solver) isUpperBound(i int) bool {
+func (solver solver[T]) isUpperBound(i int) bool {
 	if solver.alpha_status[i] == UPPER_BOUND {
 		return true
 	} else {
@@ -56,7 +66,7 @@ func (solver solver) isUpperBound(i int) bool {
 	}
 }
 
-func (solver solver) isLowerBound(i int) bool {
+func (solver solver[T]) isLowerBound(i int) bool {
 	if solver.alpha_status[i] == LOWER_BOUND {
 		return true
 	} else {
@@ -64,15 +74,22 @@ func (solver solver) isLowerBound(i int) bool {
 	}
 }
 
-func (solver solver) getC(i int) float64 {
+func (solver solver[T]) getC(i int) float64 {
+	var C float64 = solver.penaltyCn
 	if solver.y[i] > 0 {
-		return solver.penaltyCp
-	} else {
-		return solver.penaltyCn
+		C = solver.penaltyCp
+	}
+	if solver.weight != nil {
+		C *= solver.weight[i]
 	}
+	return C
 }
 
-func (solver *solver) updateAlphaStatus(i int) {
+func (solver solver[T]) isFree(i int) bool {
+	return solver.alpha_status[i] == FREE
+}
+
+func (solver *solver[T]) updateAlphaStatus(i int) {
 	if solver.alpha[i] >= solver.getC(i) {
 		solver.alpha_status[i] = UPPER_BOUND
 	} else if solver.alpha[i] <= 0 {
@@ -82,52 +99,90 @@ func (solver *solver) updateAlphaStatus(i int) {
 	}
 }
 
-func (solver *solver) solve() solution {
+/**
+ * Runs the SMO iterations until convergence, or until the maximum number of iterations is reached.
+ * Returns a TrainingStoppedError if ctx is done before then.
+ */
+func (solver *solver[T]) solve(ctx context.Context) (solution, error) {
+	done := ctx.Done() // nil if ctx can never be cancelled
 
 	solver.alpha_status = make([]int8, solver.l)
 	for i := 0; i < solver.l; i++ {
 		solver.updateAlphaStatus(i)
 	}
 
+	// Initialize active set (for shrinking)
+	solver.activeSet = make([]int, solver.l)
+	for i := 0; i < solver.l; i++ {
+		solver.activeSet[i] = i
+	}
+	solver.activeSize = solver.l
+
 	// Initialize gradient
 	solver.gradient = make([]float64, solver.l)
+	solver.gradientBar = make([]float64, solver.l)
 	for i := 0; i < solver.l; i++ {
 		solver.gradient[i] = solver.p[i]
 	}
 
 	for i := 0; i < solver.l; i++ {
-		var alpha_i float64 = solver.alpha[i]
-		Q_i := solver.q.getQ(i, solver.l) // getQ() is parallelized in the respective matrixQ implementation
-		solver.initGradientInnerLoop(Q_i, alpha_i)
+		select {
+		case <-done:
+			return solution{}, &TrainingStoppedError{Err: ctx.Err()}
+		default:
+		}
+		if !solver.isLowerBound(i) {
+			var alpha_i float64 = solver.alpha[i]
+			Q_i := solver.q.getQ(i, solver.l) // getQ() is parallelized in the respective matrixQ implementation
+			solver.initGradientInnerLoop(Q_i, alpha_i)
+			if solver.isUpperBound(i) {
+				solver.updateGradientBar(Q_i, solver.getC(i))
+			}
+		}
 	}
 	// solver.initGradient() // Alternative parallelization strategy - no improvement
 
 	var iter int = 0
 	var max_iter int = 0
-	if solver.l > math.MaxInt32/100 {
-		max_iter = math.MaxInt32
+	if solver.maxIter > 0 {
+		max_iter = solver.maxIter
 	} else {
-		max_iter = 100 * solver.l
+		if solver.l > math.MaxInt32/100 {
+			max_iter = math.MaxInt32
+		} else {
+			max_iter = 100 * solver.l
+		}
+		max_iter = maxi(10000000, max_iter)
 	}
-	max_iter = maxi(10000000, max_iter)
 	var counter = mini(solver.l, 1000) + 1
 
 	for iter < max_iter {
+		select {
+		case <-done:
+			return solution{}, &TrainingStoppedError{Err: ctx.Err()}
+		default:
+		}
+
 		if counter = counter - 1; counter == 0 {
 			counter = mini(solver.l, 1000)
-			if !solver.quietMode {
-				fmt.Print(".")
+			if solver.shrinking {
+				solver.workingSet.doShrinking(solver)
 			}
+			solver.observer.Iteration(iter, solver.maxViolation)
 		}
 
 		var i int = 0
 		var j int = 0
 		var rc int = 0
 		if i, j, rc = solver.workingSet.workingSetSelect(solver); rc != 0 {
-			if !solver.quietMode {
-				fmt.Print("*")
+			// reconstruct the whole gradient, and check again with the full active set
+			solver.reconstructGradient()
+			solver.activeSize = solver.l
+			solver.observer.Unshrink(iter)
+			if i, j, rc = solver.workingSet.workingSetSelect(solver); rc != 0 {
+				break
 			}
-			break
+			counter = 1 // do shrinking in the next iteration
 		}
 
 		iter++
@@ -138,8 +193,8 @@ func (solver *solver) solve() solution {
 		oldAlpha_i := solver.alpha[i]
 		oldAlpha_j := solver.alpha[j]
 
-		Q_i := solver.q.getQ(i, solver.l) // row i of Q matrix
-		Q_j := solver.q.getQ(j, solver.l) // row j of Q matrix
+		Q_i := solver.q.getQ(i, solver.activeSize) // row i of Q matrix
+		Q_j := solver.q.getQ(j, solver.activeSize) // row j of Q matrix
 
 		if solver.y[i] != solver.y[j] {
 
@@ -218,12 +273,42 @@ func (solver *solver) solve() solution {
 		deltaAlpha_j := solver.alpha[j] - oldAlpha_j
 		solver.updateGradient(Q_i, Q_j, deltaAlpha_i, deltaAlpha_j)
 
+		// update alpha_status and gradientBar
+		ui := solver.isUpperBound(i)
+		uj := solver.isUpperBound(j)
 		solver.updateAlphaStatus(i)
 		solver.updateAlphaStatus(j)
+		if ui != solver.isUpperBound(i) {
+			Q_i = solver.q.getQ(i, solver.l)
+			if ui {
+				solver.updateGradientBar(Q_i, -C_i)
+			} else {
+				solver.updateGradientBar(Q_i, C_i)
+			}
+		}
+		if uj != solver.isUpperBound(j) {
+			Q_j = solver.q.getQ(j, solver.l)
+			if uj {
+				solver.updateGradientBar(Q_j, -C_j)
+			} else {
+				solver.updateGradientBar(Q_j, C_j)
+			}
+		}
 	}
 
 	var si solution
 
+	if iter >= max_iter {
+		if solver.activeSize < solver.l {
+			// reconstruct the whole gradient to calculate objective value
+			solver.reconstructGradient()
+			solver.activeSize = solver.l
+			solver.observer.Unshrink(iter)
+		}
+		si.maxIterReached = true
+	}
+
+	si.iter = iter
 	si.rho, si.r = solver.workingSet.calculateRho(solver)
 
 	var v float64 = 0 // calculate objective value
@@ -235,17 +320,105 @@ func (solver *solver) solve() solution {
 	si.upper_bound_p = solver.penaltyCp
 	si.upper_bound_n = solver.penaltyCn
 
-	si.alpha = solver.alpha
+	for i := 0; i < solver.l; i++ { // put back the solution in the original order
+		solver.alphaOut[solver.activeSet[i]] = solver.alpha[i]
+	}
+	si.alpha = solver.alphaOut
 
-	if !solver.quietMode {
-		fmt.Printf("\noptimization finished, #iter = %d\n", iter)
+	if solver.restoreOrder {
+		for i := 0; i < solver.l; i++ {
+			for solver.activeSet[i] != i {
+				solver.swapIndex(i, solver.activeSet[i])
+			}
+		}
 	}
-	// solver.q.showCacheStats() // show cache statistics
 
-	return si
+	si.stats = solver.q.getStats().since(solver.statsBase)
+
+	return si, nil
 }
 
-func (solver *solver) initGradientInnerLoop(Q_i []cacheDataType, alpha_i float64) {
+/**
+ * Swaps the variables i and j
+ */
+func (solver *solver[T]) swapIndex(i, j int) {
+	solver.q.swapIndex(i, j) // also swaps qd, since solver.qd is the Q matrix's diagonal
+	solver.y[i], solver.y[j] = solver.y[j], solver.y[i]
+	if solver.weight != nil {
+		solver.weight[i], solver.weight[j] = solver.weight[j], solver.weight[i]
+	}
+	solver.gradient[i], solver.gradient[j] = solver.gradient[j], solver.gradient[i]
+	solver.alpha_status[i], solver.alpha_status[j] = solver.alpha_status[j], solver.alpha_status[i]
+	solver.alpha[i], solver.alpha[j] = solver.alpha[j], solver.alpha[i]
+	solver.p[i], solver.p[j] = solver.p[j], solver.p[i]
+	solver.activeSet[i], solver.activeSet[j] = solver.activeSet[j], solver.activeSet[i]
+	solver.gradientBar[i], solver.gradientBar[j] = solver.gradientBar[j], solver.gradientBar[i]
+}
+
+/**
+ * Shrinks the active set by moving the variables for which shrunk(i) is true to the end
+ */
+func (solver *solver[T]) shrinkActiveSet(shrunk func(i int) bool) {
+	for i := 0; i < solver.activeSize; i++ {
+		if shrunk(i) {
+			solver.activeSize--
+			for solver.activeSize > i {
+				if !shrunk(solver.activeSize) {
+					solver.swapIndex(i, solver.activeSize)
+					break
+				}
+				solver.activeSize--
+			}
+		}
+	}
+}
+
+/**
+ * Reconstructs the gradient of the inactive variables from gradientBar and the free variables
+ */
+func (solver *solver[T]) reconstructGradient() {
+	if solver.activeSize == solver.l {
+		return
+	}
+
+	for j := solver.activeSize; j < solver.l; j++ {
+		solver.gradient[j] = solver.gradientBar[j] + solver.p[j]
+	}
+
+	var nr_free int = 0
+	for j := 0; j < solver.activeSize; j++ {
+		if solver.isFree(j) {
+			nr_free++
+		}
+	}
+
+	if 2*nr_free < solver.activeSize {
+		solver.observer.Warning("using -h 0 may be faster")
+	}
+
+	if nr_free*solver.l > 2*solver.activeSize*(solver.l-solver.activeSize) {
+		for i := solver.activeSize; i < solver.l; i++ {
+			Q_i := solver.q.getQ(i, solver.activeSize)
+			for j := 0; j < solver.activeSize; j++ {
+				if solver.isFree(j) {
+					solver.gradient[i] += solver.alpha[j] * float64(Q_i[j])
+				}
+			}
+		}
+	} else {
+		for i := 0; i < solver.activeSize; i++ {
+			if solver.isFree(i) {
+				Q_i := solver.q.getQ(i, solver.l)
+				alpha_i := solver.alpha[i]
+				for j := solver.activeSize; j < solver.l; j++ {
+					solver.gradient[j] += alpha_i * float64(Q_i[j])
+				}
+			}
+		}
+	}
+}
+
+func (solver *solver[T]) initGradientInnerLoop(Q_i []T, alpha_i float64) {
 
 	run := func(tid, start, end int) {
 		// for j := 0; j < solver.l; j++
@@ -254,11 +427,10 @@ func (solver *solver) initGradientInnerLoop(Q_i []cacheDataType, alpha_i float64
 		}
 	}
 
-	solver.parRunner.run(run)
-	solver.parRunner.waitAll()
+	solver.pool.runRange(0, solver.l, run)
 }
 
-func (solver *solver) initGradient() {
+func (solver *solver[T]) initGradient() {
 	run := func(tid, start, end int) {
 		//for j := 0; j < solver.l; j++ {
 		for j := start; j < end; j++ {
@@ -268,11 +440,10 @@ func (solver *solver) initGradient() {
 		}
 	}
 
-	solver.parRunner.run(run)
-	solver.parRunner.waitAll()
+	solver.pool.runRange(0, solver.l, run)
 }
 
-func (solver *solver) updateGradient(Q_i, Q_j []cacheDataType, deltaAlpha_i, deltaAlpha_j float64) {
+func (solver *solver[T]) updateGradient(Q_i, Q_j []T, deltaAlpha_i, deltaAlpha_j float64) {
 
 	run := func(tid, start, end int) {
 		for k := start; k < end; k++ {
@@ -281,21 +452,60 @@ func (solver *solver) updateGradient(Q_i, Q_j []cacheDataType, deltaAlpha_i, del
 		}
 	}
 
-	solver.parRunner.run(run)  // run the closure in parallel
-	solver.parRunner.waitAll() // wait for all the parallel runs to complete
+	solver.pool.runRange(0, solver.activeSize, run) // run the closure in parallel over the active set
+}
+
+func (solver *solver[T]) updateGradientBar(Q_i []T, C_i float64) {
+
+	run := func(tid, start, end int) {
+		for k := start; k < end; k++ {
+			solver.gradientBar[k] += C_i * float64(Q_i[k])
+		}
+	}
+
+	solver.pool.runRange(0, solver.l, run)
 }
 
-func newSolver(l int, q matrixQ, p []float64, y []int8, alpha []float64, penaltyCp, penaltyCn, eps float64, nu bool, quietMode bool, numCPU int) solver {
+/**
+ * The solver of a Q matrix, whatever the precision of its cache
+ */
+type qpSolver interface {
+	solve(ctx context.Context) (solution, error)
+	reuse(restoreOrder bool, statsBase qStats) // see subproblemStart.attach
+}
+
+func (solver *solver[T]) reuse(restoreOrder bool, statsBase qStats) {
+	solver.restoreOrder = restoreOrder
+	solver.statsBase = statsBase
+}
 
-	solver := solver{l: l, q: q, p: p, y: y, alpha: alpha,
-		penaltyCp: penaltyCp, penaltyCn: penaltyCn, eps: eps, quietMode: quietMode, numCPU: numCPU}
+func newSolver(l int, q matrixQ, p []float64, y []int8, alpha []float64, penaltyCp, penaltyCn float64, weight []float64, eps float64, nu bool, observer TrainingObserver, pool *workerPool, shrinking bool, maxIter int) qpSolver {
+	switch q := q.(type) {
+	case qRows[float64]:
+		return newSolverOf(l, q, p, y, alpha, penaltyCp, penaltyCn, weight, eps, nu, observer, pool, shrinking, maxIter)
+	default:
+		return newSolverOf(l, q.(qRows[float32]), p, y, alpha, penaltyCp, penaltyCn, weight, eps, nu, observer, pool, shrinking, maxIter)
+	}
+}
+
+func newSolverOf[T cacheValue](l int, q qRows[T], p []float64, y []int8, alpha []float64, penaltyCp, penaltyCn float64, weight []float64, eps float64, nu bool, observer TrainingObserver, pool *workerPool, shrinking bool, maxIter int) *solver[T] {
+
+	// The solver works on its own copies of p, y, weight and alpha, since shrinking reorders them.
+	// The solution is put back into alpha in the original order.
+	solver := &solver[T]{l: l, q: q, p: make([]float64, l), y: make([]int8, l), alpha: make([]float64, l), alphaOut: alpha,
+		penaltyCp: penaltyCp, penaltyCn: penaltyCn, eps: eps, observer: observer, pool: pool, shrinking: shrinking, maxIter: maxIter}
+	copy(solver.p, p)
+	copy(solver.y, y)
+	copy(solver.alpha, alpha)
+	if weight != nil {
+		solver.weight = append([]float64(nil), weight...)
+	}
 	if nu {
-		solver.workingSet = selectWorkingSetNU{}
+		solver.workingSet = selectWorkingSetNU[T]{}
 	} else {
-		solver.workingSet = selectWorkingSet{}
+		solver.workingSet = selectWorkingSet[T]{}
 	}
 	solver.qd = q.getQD()
-	solver.parRunner = newParallelRunner(solver.l, numCPU)
 
 	return solver
 }
@@ -0,0 +1,59 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the SMO solver
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"testing"
+)
+
+/**
+ * Shrinking gives the solution of the solver without shrinking, up to its stopping tolerance, for every
+ * svm type, with both working set selections
+ */
+func TestShrinking(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		var reports [2]*TrainReport
+		for k, shrinking := range []bool{false, true} {
+			param := quietParameter(svmType)
+			param.Shrinking = shrinking
+			param.C = 10
+			param.Eps = 1e-5
+			text := blobsText(1, 400, 4, 3)
+			if svmType == EPSILON_SVR || svmType == NU_SVR {
+				text = regressionText(1, 400, 4)
+			}
+			reports[k] = mustTrain(t, mustProblem(t, text, param), param).Report()
+		}
+
+		off, on := reports[0].Subproblems, reports[1].Subproblems
+		if len(off) != len(on) {
+			t.Fatalf("svm type %d: %d and %d subproblems", svmType, len(off), len(on))
+		}
+		for p := range off {
+			if !near(off[p].Obj, on[p].Obj, 1e-4) || !near(off[p].Rho, on[p].Rho, 1e-3) {
+				t.Errorf("svm type %d, subproblem %d: obj %v rho %v with shrinking, want obj %v rho %v",
+					svmType, p, on[p].Obj, on[p].Rho, off[p].Obj, off[p].Rho)
+			}
+			if on[p].NSV != off[p].NSV || on[p].NBSV != off[p].NBSV {
+				t.Errorf("svm type %d, subproblem %d: %d SVs (%d bounded) with shrinking, want %d (%d)",
+					svmType, p, on[p].NSV, on[p].NBSV, off[p].NSV, off[p].NBSV)
+			}
+		}
+	}
+}
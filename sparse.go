@@ -0,0 +1,147 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Sparse vectors that are predicted without converting them from maps
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"fmt"
+)
+
+/**
+ * A test vector of dimensions in ascending order and their values.  Unlike a map of dimension/value,
+ * it is in the form the model evaluates, so predicting it does not allocate and sort a copy.  The zero
+ * SparseVector is the empty vector.
+ */
+type SparseVector struct {
+	nodes []snode // ends with index -1
+}
+
+var emptySnodes = []snode{{index: -1}}
+
+func (v SparseVector) snodes() []snode {
+	if v.nodes == nil {
+		return emptySnodes
+	}
+	return v.nodes
+}
+
+/**
+ * Returns the vector of the parallel slices of dimensions and values.  The dimensions must be
+ * non-negative and in strictly ascending order.
+ */
+func NewSparseVector(indices []int, values []float64) (SparseVector, error) {
+	if len(indices) != len(values) {
+		return SparseVector{}, fmt.Errorf("%d indices but %d values", len(indices), len(values))
+	}
+
+	nodes := make([]snode, len(indices)+1)
+	for k, index := range indices {
+		if index < 0 || (k > 0 && index <= indices[k-1]) {
+			return SparseVector{}, fmt.Errorf("invalid index %d: indices must be non-negative and in ascending order", index)
+		}
+		nodes[k] = snode{index: index, value: values[k]}
+	}
+	nodes[len(indices)] = snode{index: -1}
+
+	return SparseVector{nodes: nodes}, nil
+}
+
+/**
+ * Returns the vector of a map of dimension/value
+ */
+func NewSparseVectorFromMap(x map[int]float64) SparseVector {
+	return SparseVector{nodes: MapToSnode(x)}
+}
+
+/**
+ * Returns the vector of a dense vector.  Element x[k] is dimension k+1, and zero elements are omitted.
+ */
+func NewSparseVectorFromDense(x []float64) SparseVector {
+	var nodes []snode
+	for k, value := range x {
+		if value != 0 {
+			nodes = append(nodes, snode{index: k + 1, value: value})
+		}
+	}
+	return SparseVector{nodes: append(nodes, snode{index: -1})}
+}
+
+/**
+ * Returns the number of (non-zero) dimensions
+ */
+func (v SparseVector) Len() int {
+	return len(v.snodes()) - 1
+}
+
+/**
+ * Returns the k-th dimension and its value, for 0 <= k < Len()
+ */
+func (v SparseVector) At(k int) (index int, value float64) {
+	return v.nodes[k].index, v.nodes[k].value
+}
+
+/**
+ * Returns the vector as a map of dimension/value
+ */
+func (v SparseVector) Map() map[int]float64 {
+	return SnodeToMap(v.snodes())
+}
+
+/**
+ * Same as Predict, for a sparse vector
+ */
+func (model Model) PredictSparse(x SparseVector) float64 {
+	predict, _ := model.predictValues(x.snodes(), nil)
+	return predict
+}
+
+/**
+ * Same as PredictValues, for a sparse vector
+ */
+func (model Model) PredictValuesSparse(x SparseVector) (returnValue float64, decisionValues []float64) {
+	return model.predictValues(x.snodes(), nil)
+}
+
+/**
+ * Same as PredictProbability, for a sparse vector
+ */
+func (model Model) PredictProbabilitySparse(x SparseVector) (returnValue float64, probabilityEstimate []float64) {
+	return model.predictProbability(x.snodes(), nil)
+}
+
+/**
+ * Return one label and vector from the problem set.  Unlike GetLine, the vector shares the
+ * storage of the problem set, and is not copied.
+ */
+func (problem *Problem) GetSparseLine() (y float64, x SparseVector) {
+	y = problem.y[problem.i]
+	idx := problem.x[problem.i]
+	end := idx
+	for problem.xSpace[end].index != -1 {
+		end++
+	}
+	x = SparseVector{nodes: problem.xSpace[idx : end+1]}
+	return // y, x
+}
+
+/**
+ * Adds a label and its sparse vector
+ */
+func (b *ProblemBuilder) AddVector(label float64, x SparseVector) {
+	b.add(label, x.snodes()[:x.Len()])
+}
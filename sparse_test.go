@@ -0,0 +1,138 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the sparse vectors
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewSparseVector(t *testing.T) {
+	v, err := NewSparseVector([]int{1, 4, 9}, []float64{0.5, 0, -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Len() != 3 || !reflect.DeepEqual(v.Map(), map[int]float64{1: 0.5, 4: 0, 9: -2}) {
+		t.Errorf("vector of %d dimensions %v", v.Len(), v.Map())
+	}
+	if index, value := v.At(2); index != 9 || value != -2 {
+		t.Errorf("At(2) is %d:%v, want 9:-2", index, value)
+	}
+
+	for _, test := range []struct {
+		name    string
+		indices []int
+		values  []float64
+	}{
+		{"length mismatch", []int{1, 2}, []float64{1}},
+		{"negative index", []int{-1, 2}, []float64{1, 1}},
+		{"unsorted indices", []int{3, 2}, []float64{1, 1}},
+		{"duplicate indices", []int{2, 2}, []float64{1, 1}},
+	} {
+		if v, err := NewSparseVector(test.indices, test.values); err == nil || v.Len() != 0 {
+			t.Errorf("%s: vector %v, error %v", test.name, v.Map(), err)
+		}
+	}
+}
+
+/**
+ * The vectors of a map and of a dense slice are sorted, the dense one omitting its zeros, and the zero
+ * SparseVector is empty
+ */
+func TestSparseVectorFrom(t *testing.T) {
+	fromMap := NewSparseVectorFromMap(map[int]float64{7: 1, 2: 3, 5: -1})
+	fromDense := NewSparseVectorFromDense([]float64{0, 3, 0, 0, -1, 0, 1, 0})
+	want, _ := NewSparseVector([]int{2, 5, 7}, []float64{3, -1, 1})
+	if !reflect.DeepEqual(fromMap, want) || !reflect.DeepEqual(fromDense, want) {
+		t.Errorf("from map %v and from dense %v, want %v", fromMap.nodes, fromDense.nodes, want.nodes)
+	}
+
+	for _, v := range []SparseVector{{}, NewSparseVectorFromDense(nil), NewSparseVectorFromMap(nil)} {
+		if v.Len() != 0 || len(v.Map()) != 0 {
+			t.Errorf("empty vector %v of %d dimensions", v.Map(), v.Len())
+		}
+	}
+}
+
+/**
+ * Predicting a sparse vector gives the prediction of its map, for every svm type, including the empty
+ * vector
+ */
+func TestPredictSparse(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Probability = svmType != ONE_CLASS
+		prob := mustProblem(t, problemText(svmType), param)
+		model := mustTrain(t, prob, param)
+
+		_, xs := problemVectors(prob)
+		xs = append(xs, map[int]float64{})
+		for _, x := range xs {
+			v := NewSparseVectorFromMap(x)
+			if len(x) == 0 {
+				v = SparseVector{}
+			}
+			if got, want := model.PredictSparse(v), model.Predict(x); got != want {
+				t.Errorf("svm type %d: predicted %v, want %v", svmType, got, want)
+			}
+			got, gotValues := model.PredictValuesSparse(v)
+			want, wantValues := model.PredictValues(x)
+			if got != want || !reflect.DeepEqual(gotValues, wantValues) {
+				t.Errorf("svm type %d: predicted %v %v, want %v %v", svmType, got, gotValues, want, wantValues)
+			}
+			if param.Probability {
+				got, gotEstimates := model.PredictProbabilitySparse(v)
+				want, wantEstimates := model.PredictProbability(x)
+				if got != want || !reflect.DeepEqual(gotEstimates, wantEstimates) {
+					t.Errorf("svm type %d: predicted %v %v, want %v %v", svmType, got, gotEstimates, want, wantEstimates)
+				}
+			}
+		}
+	}
+}
+
+/**
+ * GetSparseLine yields the vectors of GetLine in the storage of the problem set, and a problem set built
+ * from them with AddVector is the same
+ */
+func TestGetSparseLine(t *testing.T) {
+	prob := mustProblem(t, "1 1:0.5 3:-1\n-1 2:2\n1\n-1 1:1 2:1 3:1 7:0.25\n", nil)
+	b := NewProblemBuilder()
+	i := 0
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		y, v := prob.GetSparseLine()
+		wantY, wantX := prob.GetLine()
+		if y != wantY || !reflect.DeepEqual(v.Map(), wantX) {
+			t.Errorf("line %d: %v %v, want %v %v", i, y, v.Map(), wantY, wantX)
+		}
+		if &v.nodes[0] != &prob.xSpace[prob.x[i]] || v.nodes[v.Len()].index != -1 {
+			t.Errorf("line %d: vector %v is not the storage of the problem set", i, v.nodes)
+		}
+		b.AddVector(y, v)
+		i++
+	}
+
+	built, err := b.Build(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameProblem(built, prob) {
+		t.Errorf("built %+v, want %+v", *built, *prob)
+	}
+}
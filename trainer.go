@@ -19,8 +19,12 @@
 package libSvm
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type trainError struct {
@@ -29,44 +33,79 @@ type trainError struct {
 }
 
 func (e *trainError) Error() string {
-	return fmt.Sprintf("%d -- %s\n", e.val, e.msg)
+	return fmt.Sprintf("%d -- %s", e.val, e.msg)
+}
+
+/**
+ * Returned when training is stopped before it completes, because the context was cancelled,
+ * or its deadline or Parameter.MaxDuration passed.  The model is left untrained.
+ */
+type TrainingStoppedError struct {
+	Err error // context.Canceled or context.DeadlineExceeded
+}
+
+func (e *TrainingStoppedError) Error() string {
+	return fmt.Sprintf("training stopped: %v", e.Err)
+}
+
+func (e *TrainingStoppedError) Unwrap() error {
+	return e.Err
 }
 
 type solution struct {
-	obj           float64
-	rho           float64
-	upper_bound_p float64
-	upper_bound_n float64
-	alpha         []float64
-	r             float64
+	obj            float64
+	rho            float64
+	upper_bound_p  float64
+	upper_bound_n  float64
+	alpha          []float64
+	r              float64
+	iter           int  // number of solver iterations
+	maxIterReached bool // solver stopped at the maximum number of iterations
+	stats          qStats
 }
 
 type decision struct {
 	alpha []float64
 	rho   float64
+	info  SubproblemInfo
 }
 
-func train_one(prob *Problem, param *Parameter, Cp, Cn float64) (decision, error) {
+/**
+ * Trains the decision function of the problem set.  If start is not nil, the solver starts from its
+ * coefficients instead of from scratch, and reuses or keeps its Q matrix.
+ */
+func train_one(ctx context.Context, prob *Problem, param *Parameter, Cp, Cn float64, start *subproblemStart) (decision, error) {
+
+	startTime := time.Now()
 
 	var si solution
+	var err error
 	switch param.SvmType {
 	case C_SVC:
-		si = solveCSVC(prob, param, Cp, Cn)
+		if param.Solver == SMO {
+			si, err = solveCSVC(ctx, prob, param, Cp, Cn, start)
+		} else {
+			si, err = solveLinearSVC(ctx, prob, param, Cp, Cn, start)
+		}
 	case NU_SVC:
-		si = solveNuSVC(prob, param)
+		si, err = solveNuSVC(ctx, prob, param, start)
 	case ONE_CLASS:
-		si = solveOneClass(prob, param)
+		si, err = solveOneClass(ctx, prob, param, start)
 	case EPSILON_SVR:
-		si = solveEpsilonSVR(prob, param)
+		if param.Solver == SMO {
+			si, err = solveEpsilonSVR(ctx, prob, param, start)
+		} else {
+			si, err = solveLinearSVR(ctx, prob, param, start)
+		}
 	case NU_SVR:
-		si = solveNuSVR(prob, param)
+		si, err = solveNuSVR(ctx, prob, param, start)
 	default:
 		return decision{}, &trainError{val: param.SvmType, msg: "svm type not supported"}
 	}
-
-	if !param.QuietMode {
-		fmt.Printf("obj = %f, rho = %f\n", si.obj, si.rho)
+	if err != nil {
+		return decision{}, err
 	}
+
 	alpha := si.alpha
 
 	var nSV int = 0
@@ -75,25 +114,40 @@ func train_one(prob *Problem, param *Parameter, Cp, Cn float64) (decision, error
 		if math.Abs(alpha[i]) > 0 {
 			nSV++
 			if prob.y[i] > 0 {
-				if math.Abs(alpha[i]) >= si.upper_bound_p {
+				if math.Abs(alpha[i]) >= si.upper_bound_p*prob.weight(i) {
 					nBSV++
 				}
 			} else {
-				if math.Abs(alpha[i]) >= si.upper_bound_n {
+				if math.Abs(alpha[i]) >= si.upper_bound_n*prob.weight(i) {
 					nBSV++
 				}
 			}
 		}
 	}
 
-	if !param.QuietMode {
-		fmt.Printf("nSV = %d, nBSV = %d\n", nSV, nBSV)
+	info := SubproblemInfo{SvmType: param.SvmType, Iterations: si.iter, MaxIterReached: si.maxIterReached,
+		Obj: si.obj, Rho: si.rho, NSV: nSV, NBSV: nBSV, Cp: si.upper_bound_p, Cn: si.upper_bound_n,
+		Duration: time.Since(startTime), KernelEvaluations: si.stats.kernelEvals, CacheHits: si.stats.hits, CacheMisses: si.stats.misses,
+		CacheEvictions: si.stats.evictions, SharedCacheHits: si.stats.sharedHits}
+
+	switch param.SvmType {
+	case C_SVC, EPSILON_SVR:
+		var sum_alpha float64 = 0
+		for i := 0; i < prob.l; i++ {
+			sum_alpha += math.Abs(alpha[i])
+		}
+		info.Nu = sum_alpha / (si.upper_bound_p * float64(prob.l))
+	case NU_SVC:
+		info.C = si.upper_bound_p
+	case NU_SVR:
+		info.Epsilon = -si.r
 	}
 
-	return decision{alpha: alpha, rho: si.rho}, nil
+	return decision{alpha: alpha, rho: si.rho, info: info}, nil
 }
 
-func solveCSVC(prob *Problem, param *Parameter, Cp, Cn float64) solution {
+func solveCSVC(ctx context.Context, prob *Problem, param *Parameter, Cp, Cn float64, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
 	var l int = prob.l
 
 	alpha := make([]float64, l)
@@ -110,26 +164,41 @@ func solveCSVC(prob *Problem, param *Parameter, Cp, Cn float64) solution {
 		}
 	}
 
-	s := newSolver(l, newSVCQ(prob, param, y), minus_one, y, alpha, Cp, Cn, param.Eps, false /*not nu*/, param.QuietMode, param.NumCPU)
-	si := s.solve() // generate solution
+	if seed != nil { // warm start
+		for i := 0; i < l; i++ {
+			alpha[i] = math.Abs(seed[i])
+		}
+		bound := func(i int) float64 {
+			if y[i] > 0 {
+				return Cp * prob.weight(i)
+			}
+			return Cn * prob.weight(i)
+		}
+		clipAlpha(alpha, bound)
+		balanceAlpha(alpha, y, bound)
+	}
 
-	var sum_alpha float64 = 0
-	for i := 0; i < l; i++ {
-		sum_alpha = sum_alpha + si.alpha[i]
-		si.alpha[i] = si.alpha[i] * float64(y[i])
+	q, err := start.matrix(func() (matrixQ, error) { return newSVCQ(prob, param, y) })
+	if err != nil {
+		return solution{}, err
 	}
 
-	if Cp == Cn {
-		if !param.QuietMode {
-			t := Cp * float64(l)
-			fmt.Printf("nu = %f\n", sum_alpha/t)
-		}
+	s := newSolver(l, q, minus_one, y, alpha, Cp, Cn, prob.w, param.Eps, false /*not nu*/, param.observer(), param.pool, param.Shrinking, param.MaxIter)
+	start.attach(s, q)
+	si, err := s.solve(ctx) // generate solution
+	if err != nil {
+		return si, err
+	}
+
+	for i := 0; i < l; i++ {
+		si.alpha[i] = si.alpha[i] * float64(y[i])
 	}
 
-	return si // return solution
+	return si, nil // return solution
 }
 
-func solveNuSVC(prob *Problem, param *Parameter) solution {
+func solveNuSVC(ctx context.Context, prob *Problem, param *Parameter, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
 	var l int = prob.l
 	var nu float64 = param.Nu
 
@@ -145,31 +214,45 @@ func solveNuSVC(prob *Problem, param *Parameter) solution {
 		}
 	}
 
-	sum_pos := nu * float64(l) / 2
+	sum_pos := nu * prob.totalWeight() / 2
 	sum_neg := sum_pos
 
 	for i := 0; i < l; i++ {
 		if y[i] == 1 {
-			alpha[i] = minf(1, sum_pos)
+			alpha[i] = minf(prob.weight(i), sum_pos)
 			sum_pos -= alpha[i]
 		} else {
-			alpha[i] = minf(1, sum_neg)
+			alpha[i] = minf(prob.weight(i), sum_neg)
 			sum_neg -= alpha[i]
 		}
 	}
 
+	if seed != nil { // warm start
+		for i := 0; i < l; i++ {
+			alpha[i] = math.Abs(seed[i])
+		}
+		fitAlphaSum(alpha, y, 1, nu*prob.totalWeight()/2, prob.weight)
+		fitAlphaSum(alpha, y, -1, nu*prob.totalWeight()/2, prob.weight)
+	}
+
 	for i := 0; i < l; i++ {
 		zeros[i] = 0
 	}
 
-	s := newSolver(l, newSVCQ(prob, param, y), zeros, y, alpha, 1, 1, param.Eps, true /*nu*/, param.QuietMode, param.NumCPU)
-	si := s.solve()
+	q, err := start.matrix(func() (matrixQ, error) { return newSVCQ(prob, param, y) })
+	if err != nil {
+		return solution{}, err
+	}
 
-	r := si.r
-	if !param.QuietMode {
-		fmt.Printf("C = %v\n", 1.0/r)
+	s := newSolver(l, q, zeros, y, alpha, 1, 1, prob.w, param.Eps, true /*nu*/, param.observer(), param.pool, param.Shrinking, param.MaxIter)
+	start.attach(s, q)
+	si, err := s.solve(ctx)
+	if err != nil {
+		return si, err
 	}
 
+	r := si.r
+
 	for i := 0; i < l; i++ {
 		si.alpha[i] *= (float64(y[i]) / r)
 	}
@@ -179,25 +262,21 @@ func solveNuSVC(prob *Problem, param *Parameter) solution {
 	si.upper_bound_p = 1 / r
 	si.upper_bound_n = 1 / r
 
-	return si
+	return si, nil
 }
 
-func solveOneClass(prob *Problem, param *Parameter) solution {
+func solveOneClass(ctx context.Context, prob *Problem, param *Parameter, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
 	var l int = prob.l
 
 	alpha := make([]float64, l)
 	zeros := make([]float64, l)
 	ones := make([]int8, l)
 
-	var n int = int(param.Nu) * prob.l
-	for i := 0; i < n; i++ {
-		alpha[i] = 1
-	}
-	if n < l {
-		alpha[n] = param.Nu*float64(l) - float64(n)
-	}
-	for i := n + 1; i < l; i++ {
-		alpha[i] = 0
+	var nu_l float64 = param.Nu * prob.totalWeight()
+	for i := 0; i < l; i++ {
+		alpha[i] = minf(prob.weight(i), nu_l)
+		nu_l -= alpha[i]
 	}
 
 	for i := 0; i < l; i++ {
@@ -205,13 +284,30 @@ func solveOneClass(prob *Problem, param *Parameter) solution {
 		ones[i] = 1
 	}
 
-	s := newSolver(l, newOneClassQ(prob, param), zeros, ones, alpha, 1, 1, param.Eps, false /*not nu*/, param.QuietMode, param.NumCPU)
-	si := s.solve()
+	if seed != nil { // warm start
+		for i := 0; i < l; i++ {
+			alpha[i] = maxf(seed[i], 0)
+		}
+		fitAlphaSum(alpha, ones, 1, param.Nu*prob.totalWeight(), prob.weight)
+	}
+
+	q, err := start.matrix(func() (matrixQ, error) { return newOneClassQ(prob, param) })
+	if err != nil {
+		return solution{}, err
+	}
 
-	return si
+	s := newSolver(l, q, zeros, ones, alpha, 1, 1, prob.w, param.Eps, false /*not nu*/, param.observer(), param.pool, param.Shrinking, param.MaxIter)
+	start.attach(s, q)
+	si, err := s.solve(ctx)
+	if err != nil {
+		return si, err
+	}
+
+	return si, nil
 }
 
-func solveEpsilonSVR(prob *Problem, param *Parameter) solution {
+func solveEpsilonSVR(ctx context.Context, prob *Problem, param *Parameter, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
 	var l int = prob.l
 
 	alpha := make([]float64, 2*l)
@@ -228,25 +324,38 @@ func solveEpsilonSVR(prob *Problem, param *Parameter) solution {
 		y[i+l] = -1
 	}
 
-	s := newSolver(2*l, newSVRQ(prob, param), linear_term, y, alpha, param.C, param.C, param.Eps, false /*not nu*/, param.QuietMode, param.NumCPU)
-	si := s.solve()
+	if seed != nil { // warm start
+		for i := 0; i < l; i++ {
+			alpha[i] = maxf(seed[i], 0)
+			alpha[i+l] = maxf(-seed[i], 0)
+		}
+		bound := func(i int) float64 { return param.C * prob.weight(i%l) }
+		clipAlpha(alpha, bound)
+		balanceAlpha(alpha, y, bound)
+	}
+
+	q, err := start.matrix(func() (matrixQ, error) { return newSVRQ(prob, param) })
+	if err != nil {
+		return solution{}, err
+	}
+
+	s := newSolver(2*l, q, linear_term, y, alpha, param.C, param.C, svrWeights(prob), param.Eps, false /*not nu*/, param.observer(), param.pool, param.Shrinking, param.MaxIter)
+	start.attach(s, q)
+	si, err := s.solve(ctx)
+	if err != nil {
+		return si, err
+	}
 
-	var sum_alpha float64 = 0
 	for i := 0; i < l; i++ {
 		si.alpha[i] = si.alpha[i] - si.alpha[i+l]
-		sum_alpha += math.Abs(si.alpha[i])
 	}
 	si.alpha = si.alpha[:l]
 
-	var nu float64 = sum_alpha / (param.C * float64(l))
-	if !param.QuietMode {
-		fmt.Printf("nu = %v\n", nu)
-	}
-
-	return si
+	return si, nil
 }
 
-func solveNuSVR(prob *Problem, param *Parameter) solution {
+func solveNuSVR(ctx context.Context, prob *Problem, param *Parameter, start *subproblemStart) (solution, error) {
+	seed := start.seeds()
 	var l int = prob.l
 	var C float64 = param.C
 
@@ -254,10 +363,10 @@ func solveNuSVR(prob *Problem, param *Parameter) solution {
 	linear_term := make([]float64, 2*l)
 	y := make([]int8, 2*l)
 
-	var sum float64 = C * param.Nu * float64(l) / 2.0
+	var sum float64 = C * param.Nu * prob.totalWeight() / 2.0
 
 	for i := 0; i < l; i++ {
-		alpha[i] = minf(sum, C)
+		alpha[i] = minf(sum, C*prob.weight(i))
 		alpha[i+l] = alpha[i]
 
 		sum -= alpha[i]
@@ -269,11 +378,26 @@ func solveNuSVR(prob *Problem, param *Parameter) solution {
 		y[i+l] = -1
 	}
 
-	s := newSolver(2*l, newSVRQ(prob, param), linear_term, y, alpha, param.C, param.C, param.Eps, true /*nu*/, param.QuietMode, param.NumCPU)
-	si := s.solve()
+	if seed != nil { // warm start
+		for i := 0; i < l; i++ {
+			alpha[i] = maxf(seed[i], 0)
+			alpha[i+l] = maxf(-seed[i], 0)
+		}
+		bound := func(i int) float64 { return C * prob.weight(i%l) }
+		fitAlphaSum(alpha, y, 1, C*param.Nu*prob.totalWeight()/2, bound)
+		fitAlphaSum(alpha, y, -1, C*param.Nu*prob.totalWeight()/2, bound)
+	}
+
+	q, err := start.matrix(func() (matrixQ, error) { return newSVRQ(prob, param) })
+	if err != nil {
+		return solution{}, err
+	}
 
-	if !param.QuietMode {
-		fmt.Printf("epsilon = %f\n", -si.r)
+	s := newSolver(2*l, q, linear_term, y, alpha, param.C, param.C, svrWeights(prob), param.Eps, true /*nu*/, param.observer(), param.pool, param.Shrinking, param.MaxIter)
+	start.attach(s, q)
+	si, err := s.solve(ctx)
+	if err != nil {
+		return si, err
 	}
 
 	for i := 0; i < l; i++ {
@@ -281,5 +405,86 @@ func solveNuSVR(prob *Problem, param *Parameter) solution {
 	}
 	si.alpha = si.alpha[:l]
 
-	return si
+	return si, nil
+}
+
+/**
+ * Returns the instance weights of the 2l variables of the SVR formulations, or nil if there are none
+ */
+func svrWeights(prob *Problem) []float64 {
+	if prob.w == nil {
+		return nil
+	}
+	weight := make([]float64, 2*prob.l)
+	copy(weight, prob.w)
+	copy(weight[prob.l:], prob.w)
+	return weight
+}
+
+/**
+ * Trains the n subproblems with train, one at a time, or on up to param.SubproblemWorkers goroutines.
+ * Concurrent subproblems share the kernel cache budget param.CacheSize, and report to the observer one
+ * at a time.  Once a subproblem fails, the ones that have not started are skipped and the running ones
+ * are stopped.  The error of the first subproblem that failed other than by being stopped is returned,
+ * so the outcome does not depend on the order in which the subproblems finish.
+ */
+func runSubproblems(ctx context.Context, param *Parameter, n int, train func(ctx context.Context, param *Parameter, p int) error) error {
+	workers := mini(param.SubproblemWorkers, n)
+	if workers <= 1 {
+		for p := 0; p < n; p++ {
+			if err := ctx.Err(); err != nil {
+				return &TrainingStoppedError{Err: err}
+			}
+			if err := train(ctx, param, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	subParam := *param
+	subParam.CacheSize = maxi(1, param.CacheSize/workers)
+	subParam.Observer = &lockedObserver{observer: param.observer()}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	var next int64 = 0
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				p := int(atomic.AddInt64(&next, 1) - 1)
+				if p >= n {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					errs[p] = &TrainingStoppedError{Err: err}
+					continue
+				}
+				if err := train(ctx, &subParam, p); err != nil {
+					errs[p] = err
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var first error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if _, stopped := err.(*TrainingStoppedError); !stopped {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
 }
@@ -0,0 +1,122 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of training the one-vs-one subproblems concurrently
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+/**
+ * The subproblems trained concurrently give the model trained one at a time, byte for byte, and the
+ * observer sees every subproblem once
+ */
+func TestSubproblemWorkers(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC} {
+		param := quietParameter(svmType)
+		param.Nu = 0.2 // the probability calibration is left out, as its folds are random
+		prob := mustProblem(t, blobsText(1, 180, 4, 6), param)
+		text := modelText(t, mustTrain(t, prob, param))
+
+		for _, workers := range []int{2, 4, 20} {
+			param.SubproblemWorkers = workers
+			observer := &recordingObserver{}
+			param.Observer = observer
+			model := mustTrain(t, prob, param)
+			if got := modelText(t, model); got != text {
+				t.Fatalf("svm type %d, %d workers: model\n%s\nwant\n%s", svmType, workers, got, text)
+			}
+
+			var pairs []int
+			for _, info := range observer.subproblems {
+				pairs = append(pairs, info.Labels[0]*10+info.Labels[1])
+			}
+			sort.Ints(pairs)
+			var want []int
+			for _, info := range model.Report().Subproblems {
+				want = append(want, info.Labels[0]*10+info.Labels[1])
+			}
+			if !reflect.DeepEqual(pairs, want) || len(want) != 15 {
+				t.Errorf("svm type %d, %d workers: subproblems %v, want %v", svmType, workers, pairs, want)
+			}
+			if len(observer.totalSV) != 1 || observer.totalSV[0] != model.l {
+				t.Errorf("svm type %d, %d workers: TrainingDone %v", svmType, workers, observer.totalSV)
+			}
+		}
+	}
+}
+
+/**
+ * runSubproblems trains each subproblem once with its share of the cache, and returns the error of the
+ * first subproblem that failed other than by being stopped, whatever the order they finish in
+ */
+func TestRunSubproblems(t *testing.T) {
+	failed := errors.New("failed")
+	for _, workers := range []int{0, 1, 3, 8} {
+		param := quietParameter(C_SVC)
+		param.SubproblemWorkers = workers
+		param.CacheSize = 90
+
+		calls := make([]int32, 10)
+		err := runSubproblems(context.Background(), param, len(calls), func(ctx context.Context, subParam *Parameter, p int) error {
+			atomic.AddInt32(&calls[p], 1)
+			if workers > 1 && (subParam.CacheSize != 90/mini(workers, 10) || subParam.Observer == nil) {
+				t.Errorf("%d workers: subproblem %d has a cache of %d MB", workers, p, subParam.CacheSize)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%d workers: %v", workers, err)
+		}
+		for p, n := range calls {
+			if n != 1 {
+				t.Errorf("%d workers: subproblem %d trained %d times", workers, p, n)
+			}
+		}
+
+		err = runSubproblems(context.Background(), param, 10, func(ctx context.Context, subParam *Parameter, p int) error {
+			switch p {
+			case 4:
+				return trainingFailed(failed)
+			case 7:
+				return errors.New("later")
+			}
+			if err := ctx.Err(); err != nil {
+				return &TrainingStoppedError{Err: err}
+			}
+			return nil
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("%d workers: %v, want the error of subproblem 4", workers, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = runSubproblems(ctx, param, 10, func(ctx context.Context, subParam *Parameter, p int) error {
+			return nil
+		})
+		if !isStopped(err, context.Canceled) {
+			t.Errorf("%d workers, cancelled: %v", workers, err)
+		}
+	}
+}
@@ -20,10 +20,13 @@ package libSvm
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
-	"os"
+	"io"
+	"math"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 const TAU float64 = 1e-12
@@ -67,6 +70,10 @@ func maxf(a, b float64) float64 {
 	}
 }
 
+func isFinite(a float64) bool {
+	return !math.IsNaN(a) && !math.IsInf(a, 0)
+}
+
 func MapToSnode(m map[int]float64) []snode {
 
 	keys := make([]int, len(m))
@@ -119,6 +126,88 @@ func readline(r *bufio.Reader) (string, error) {
 	return string(ln), err
 }
 
+/**
+ * Reads lines with readline, and counts them for error messages
+ */
+type lineReader struct {
+	reader *bufio.Reader
+	line   int // number of the last line read, starting from 1
+}
+
+/**
+ * Returns a lineReader for r, which is decompressed if it starts with the gzip magic number
+ */
+func newLineReader(r io.Reader) (*lineReader, error) {
+	reader := bufio.NewReader(r)
+	if magic, _ := reader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return &lineReader{reader: bufio.NewReader(zr)}, nil
+	}
+	return &lineReader{reader: reader}, nil
+}
+
+func (lr *lineReader) next() (string, error) {
+	line, err := readline(lr.reader)
+	if err == nil {
+		lr.line++
+	}
+	return line, err
+}
+
+/**
+ * Counts the bytes read from r
+ */
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+/**
+ * Counts the bytes written to w
+ */
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+/**
+ * Splits the line into tokens separated by white space, and returns the column (starting from 1) of each token
+ */
+func splitFields(line string) (tokens []string, columns []int) {
+	start := -1
+	for i, c := range line {
+		if unicode.IsSpace(c) {
+			if start >= 0 {
+				tokens = append(tokens, line[start:i])
+				columns = append(columns, start+1)
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, line[start:])
+		columns = append(columns, start+1)
+	}
+	return
+}
+
 // Mostly for Debugging
 func getModelFileName(file string) string {
 	var model_file []string
@@ -158,14 +247,12 @@ func printSpace(x []int, x_space []snode) {
 		}
 		fmt.Printf("\n")
 	}
-	os.Exit(0)
 }
 
 func dump(g []float64) {
 	for i, v := range g {
 		fmt.Printf("[%d]=%g\n", i, v)
 	}
-	os.Exit(0)
 }
 
 /**
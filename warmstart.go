@@ -0,0 +1,298 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Warm start of the solvers from the coefficients of a previous model
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"math"
+)
+
+/**
+ * The SV coefficients of a previous model, looked up by the index of their instance in the problem set
+ */
+type warmStart struct {
+	prev    *Model
+	sv      map[int]int // instance index (from 0) -> SV of prev
+	svClass []int       // class of each SV of prev (classification only)
+	class   map[int]int // label -> class of prev (classification only)
+	scale   float64     // factor applied to the coefficients of prev
+}
+
+/**
+ * Returns the warm start from prev for training param, or nil if the coefficients of prev cannot seed it:
+ * prev is nil, it has no SV indices (e.g. it was read from a file), or it solves a different kind of problem.
+ */
+func newWarmStart(prev *Model, param *Parameter) *warmStart {
+	if prev == nil || prev.param == nil || prev.l == 0 || len(prev.svIndices) != prev.l {
+		return nil
+	}
+	family := func(svmType int) int {
+		switch svmType {
+		case C_SVC, NU_SVC:
+			return C_SVC
+		case EPSILON_SVR, NU_SVR:
+			return EPSILON_SVR
+		}
+		return svmType
+	}
+	if family(prev.param.SvmType) != family(param.SvmType) {
+		return nil
+	}
+
+	ws := &warmStart{prev: prev, sv: make(map[int]int, prev.l), scale: 1}
+	for k, index := range prev.svIndices {
+		ws.sv[index-1] = k
+	}
+
+	if family(param.SvmType) == C_SVC {
+		ws.class = make(map[int]int, prev.nrClass)
+		ws.svClass = make([]int, 0, prev.l)
+		for c := 0; c < prev.nrClass; c++ {
+			ws.class[prev.label[c]] = c
+			for k := 0; k < prev.nSV[c]; k++ {
+				ws.svClass = append(ws.svClass, c)
+			}
+		}
+	}
+
+	return ws
+}
+
+/**
+ * Returns the coefficient of instance n in the decision function of prev (regression and one-class SVM)
+ */
+func (ws *warmStart) coef(n int) float64 {
+	if k, ok := ws.sv[n]; ok {
+		return ws.scale * ws.prev.svCoef[0][k]
+	}
+	return 0
+}
+
+/**
+ * Returns the alpha of instance n, of label a, in the decision function of prev between the labels a and b
+ */
+func (ws *warmStart) pairAlpha(n, a, b int) float64 {
+	k, ok := ws.sv[n]
+	if !ok {
+		return 0
+	}
+	c, okA := ws.class[a]
+	d, okB := ws.class[b]
+	if !okA || !okB || ws.svClass[k] != c {
+		return 0
+	}
+
+	// the coefficients of the SVs of class c in the decision function with class d (see svDecisionValues)
+	if c < d {
+		return ws.scale * math.Abs(ws.prev.svCoef[d-1][k])
+	}
+	return ws.scale * math.Abs(ws.prev.svCoef[d][k])
+}
+
+/**
+ * What the training of a subproblem starts from: the coefficients of a previous solution, and the Q matrix
+ * of an earlier training of the same subproblem, whose kernel cache is reused.  A nil *subproblemStart
+ * starts from scratch.
+ */
+type subproblemStart struct {
+	seed   []float64 // coefficients to start from, in the form of decision.alpha (nil to start from scratch)
+	q      matrixQ   // Q matrix in the original order of the variables (nil to build it)
+	keep   bool      // keep the Q matrix in q for the next training
+	reused bool      // q was reused by this training
+}
+
+func (start *subproblemStart) seeds() []float64 {
+	if start == nil {
+		return nil
+	}
+	return start.seed
+}
+
+/**
+ * Returns the Q matrix to reuse, or the one built by build
+ */
+func (start *subproblemStart) matrix(build func() (matrixQ, error)) (matrixQ, error) {
+	if start != nil && start.q != nil {
+		start.reused = true
+		return start.q, nil
+	}
+	q, err := build()
+	if err == nil && start != nil && start.keep {
+		start.q = q
+	}
+	return q, err
+}
+
+/**
+ * Sets up the solver of the Q matrix q to leave it reusable, and to report the statistics of its own training
+ */
+func (start *subproblemStart) attach(s qpSolver, q matrixQ) {
+	if start == nil {
+		return
+	}
+	var base qStats
+	if start.reused {
+		base = q.getStats()
+	}
+	s.reuse(start.keep, base)
+}
+
+/**
+ * The Q matrices of the subproblems of a problem set, kept from one training to the next
+ */
+type keptMatrices struct {
+	q []matrixQ // Q matrix of each subproblem, in the order they are trained
+}
+
+/**
+ * Returns the start of subproblem p from the coefficients seed (which may be nil) and the kept Q matrix
+ * of p, or nil to start from scratch.  Distinct subproblems may be started concurrently.
+ */
+func (kept *keptMatrices) start(p int, seed []float64) *subproblemStart {
+	if kept == nil {
+		if seed == nil {
+			return nil
+		}
+		return &subproblemStart{seed: seed}
+	}
+	return &subproblemStart{seed: seed, q: kept.q[p], keep: true}
+}
+
+/**
+ * Keeps the Q matrix that subproblem p was trained with
+ */
+func (kept *keptMatrices) done(p int, start *subproblemStart) {
+	if kept != nil {
+		kept.q[p] = start.q
+	}
+}
+
+/**
+ * Clips each alpha to [0,C(i)]
+ */
+func clipAlpha(alpha []float64, C func(i int) float64) {
+	for i := range alpha {
+		alpha[i] = minf(maxf(alpha[i], 0), C(i))
+	}
+}
+
+/**
+ * Repairs the equality constraint sum(y_i*alpha_i) = 0 of C-SVC and epsilon-SVR by lowering the alphas of
+ * the side with the larger sum.  The free alphas are lowered first, so that the alphas at their bound C(i),
+ * which the solver would otherwise have to move back, stay there if possible.
+ */
+func balanceAlpha(alpha []float64, y []int8, C func(i int) float64) {
+	var sumP, sumN float64 = 0, 0
+	for i := range alpha {
+		if y[i] > 0 {
+			sumP += alpha[i]
+		} else {
+			sumN += alpha[i]
+		}
+	}
+
+	var side int8 = 1
+	excess := sumP - sumN
+	if excess < 0 {
+		side, excess = -1, -excess
+	}
+
+	for _, free := range []bool{true, false} {
+		for i := 0; i < len(alpha) && excess > 0; i++ {
+			if y[i] != side || alpha[i] <= 0 || (free && alpha[i] >= C(i)) {
+				continue
+			}
+			take := minf(alpha[i], excess)
+			alpha[i] -= take
+			excess -= take
+		}
+	}
+}
+
+/**
+ * Repairs the equality constraint of the nu formulations and one-class SVM, that the alphas with
+ * y_i == side sum to target.  They are scaled to the target and clipped to [0,C(i)], and if clipping
+ * left them short of it, they are raised in order up to their bounds, as the default initial alphas are.
+ * The seeds must not be negative.
+ */
+func fitAlphaSum(alpha []float64, y []int8, side int8, target float64, C func(i int) float64) {
+	var sum float64 = 0
+	for i := range alpha {
+		if y[i] == side {
+			sum += alpha[i]
+		}
+	}
+
+	var remain float64 = target
+	for i := range alpha {
+		if y[i] == side {
+			if sum > 0 {
+				alpha[i] *= target / sum
+				if alpha[i] >= C(i)*(1-1e-12) { // including the alphas that were at their bound up to rounding
+					alpha[i] = C(i)
+				}
+			}
+			remain -= alpha[i]
+		}
+	}
+
+	for i := 0; i < len(alpha) && remain > 0; i++ {
+		if y[i] != side {
+			continue
+		}
+		if add := C(i) - alpha[i]; add <= remain {
+			alpha[i] = C(i)
+			remain -= add
+		} else {
+			alpha[i] += remain
+			remain = 0
+		}
+	}
+}
+
+/**
+ * Trains the model from the problem set, starting the solvers from the coefficients of the previous
+ * model prev instead of from scratch.  Same as TrainFromContext with a context that is never cancelled.
+ */
+func (model *Model) TrainFrom(prev *Model, prob *Problem) error {
+	return model.TrainFromContext(context.Background(), prev, prob)
+}
+
+/**
+ * Same as TrainContext, but each subproblem starts from the alphas of the SVs of prev, which is cheaper
+ * than a full solve when the problem set or the parameters changed a little since prev was trained.
+ * The SVs are matched to the instances of prob by their index in the problem set prev was trained
+ * from (see SVIndices), so the instances of prob must be in the same order, although some may have been
+ * appended.  The alphas are clipped to the new bounds, and the equality constraint of the dual problem is
+ * restored, before the solver computes their gradient.  The model is the same as one trained from scratch,
+ * up to the tolerance Parameter.Eps.  prev is ignored if it cannot seed the training: if it is nil, was
+ * read from a file (which does not keep the SV indices), or is not of the same kind (classification,
+ * regression or one-class SVM).
+ */
+func (model *Model) TrainFromContext(ctx context.Context, prev *Model, prob *Problem) error {
+	if err := model.param.Validate(prob); err != nil {
+		return err
+	}
+	if model.param.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, model.param.MaxDuration)
+		defer cancel()
+	}
+	return model.trainFrom(ctx, prob, newWarmStart(prev, model.param), nil)
+}
@@ -0,0 +1,183 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the training warm started from a previous model
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+/**
+ * A model trained again, from scratch or from itself, holds the solution of the last training only
+ */
+func TestRetrainReplacesSolution(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		prob := mustProblem(t, problemText(svmType), param)
+
+		model := mustTrain(t, prob, param)
+		param.C = 4
+		param.Nu = 0.3
+		fresh := mustTrain(t, prob, param)
+
+		if err := model.Train(prob); err != nil {
+			t.Fatalf("svm type %d: %v", svmType, err)
+		}
+		if !nearSlices(model.rho, fresh.rho, 0) || model.l != fresh.l {
+			t.Errorf("svm type %d: retrained rho %v with %d SVs, want %v with %d SVs", svmType, model.rho, model.l, fresh.rho, fresh.l)
+		}
+
+		param.C = 1
+		param.Nu = 0.5
+		fresh = mustTrain(t, prob, param)
+		if err := model.TrainFrom(model, prob); err != nil {
+			t.Fatalf("svm type %d: %v", svmType, err)
+		}
+		if !nearSlices(model.rho, fresh.rho, 1e-2) {
+			t.Errorf("svm type %d: rho warm started from itself %v, want %v", svmType, model.rho, fresh.rho)
+		}
+	}
+}
+
+func TestClipAlpha(t *testing.T) {
+	alpha := []float64{-1, 0.5, 3}
+	clipAlpha(alpha, func(i int) float64 { return 1 })
+	if !nearSlices(alpha, []float64{0, 0.5, 1}, 0) {
+		t.Errorf("clipped %v, want [0 0.5 1]", alpha)
+	}
+}
+
+/**
+ * The side with the larger sum is lowered, its free alphas first
+ */
+func TestBalanceAlpha(t *testing.T) {
+	C := func(i int) float64 { return 1 }
+	alpha := []float64{1, 0.5, 0.3, 0.2}
+	balanceAlpha(alpha, []int8{1, 1, -1, -1}, C)
+	if !nearSlices(alpha, []float64{0.5, 0, 0.3, 0.2}, 1e-15) {
+		t.Errorf("balanced %v, want [0.5 0 0.3 0.2]", alpha)
+	}
+
+	alpha = []float64{0.2, 1, 0.7}
+	balanceAlpha(alpha, []int8{1, -1, -1}, C)
+	if !nearSlices(alpha, []float64{0.2, 0.2, 0}, 1e-15) {
+		t.Errorf("balanced %v, want [0.2 0.2 0]", alpha)
+	}
+}
+
+/**
+ * The alphas of the side are scaled to the target, clipped, and raised in order to make up for the
+ * clipping, and the others are left alone
+ */
+func TestFitAlphaSum(t *testing.T) {
+	C := func(i int) float64 { return 1 }
+	y := []int8{1, 1, -1, 1}
+	alpha := []float64{0.2, 0.6, 0.4, 0.9}
+	fitAlphaSum(alpha, y, 1, 2.5, C)
+	scale := 2.5 / 1.7
+	want := []float64{2.5 - 0.6*scale - 1, 0.6 * scale, 0.4, 1}
+	if !nearSlices(alpha, want, 1e-15) {
+		t.Errorf("fitted %v, want %v", alpha, want)
+	}
+
+	alpha = []float64{0, 0, 0.4, 0}
+	fitAlphaSum(alpha, y, 1, 1.5, C)
+	if !nearSlices(alpha, []float64{1, 0.5, 0.4, 0}, 0) {
+		t.Errorf("fitted %v, want [1 0.5 0.4 0]", alpha)
+	}
+}
+
+/**
+ * A model warm started from a model of other parameters, and of fewer instances, is the model trained
+ * from scratch, for every svm type
+ */
+func TestTrainFrom(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Eps = 1e-6
+		lines := strings.SplitAfter(problemText(svmType), "\n")
+		prev := mustTrain(t, mustProblem(t, strings.Join(lines[:100], ""), param), param)
+
+		param.C = 0.25 // below the alphas of prev at their bound of C = 1
+		param.Nu = 0.3
+		prob := mustProblem(t, strings.Join(lines, ""), param)
+		want := mustTrain(t, prob, param)
+		model := NewModel(param)
+		if err := model.TrainFrom(prev, prob); err != nil {
+			t.Fatalf("svm type %d: %v", svmType, err)
+		}
+
+		for p, info := range model.Report().Subproblems {
+			wantInfo := want.Report().Subproblems[p]
+			if !near(info.Obj, wantInfo.Obj, 1e-4*math.Abs(wantInfo.Obj)) || !near(info.Rho, wantInfo.Rho, 1e-3) {
+				t.Errorf("svm type %d, subproblem %d: obj %v rho %v, want %v %v", svmType, p, info.Obj, info.Rho, wantInfo.Obj, wantInfo.Rho)
+			}
+		}
+		for prob.Begin(); !prob.Done(); prob.Next() {
+			_, x := prob.GetLine()
+			_, got := model.PredictValues(x)
+			_, wantValues := want.PredictValues(x)
+			if !nearSlices(got, wantValues, 1e-3) {
+				t.Fatalf("svm type %d: decision values %v, want %v", svmType, got, wantValues)
+			}
+		}
+
+		var iterations, again int = 0, 0
+		for _, info := range model.Report().Subproblems {
+			iterations += info.Iterations
+		}
+		if err := model.TrainFrom(model, prob); err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range model.Report().Subproblems {
+			again += info.Iterations
+		}
+		if again >= iterations {
+			t.Errorf("svm type %d: %d iterations from the same parameters, and %d from scratch", svmType, again, iterations)
+		}
+	}
+}
+
+/**
+ * A model that cannot seed the training, because it is missing, was read from a file or is of another
+ * kind, is ignored
+ */
+func TestTrainFromIgnored(t *testing.T) {
+	param := quietParameter(EPSILON_SVR)
+	prob := mustProblem(t, problemText(EPSILON_SVR), param)
+	want := mustTrain(t, prob, param)
+	text := modelText(t, want)
+	read, err := ReadModelFrom(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	classParam := quietParameter(C_SVC)
+	classifier := mustTrain(t, mustProblem(t, problemText(C_SVC), classParam), classParam)
+
+	for name, prev := range map[string]*Model{"nil": nil, "read": read, "classifier": classifier} {
+		model := NewModel(param)
+		if err := model.TrainFrom(prev, prob); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := modelText(t, model); got != text {
+			t.Errorf("%s: model\n%s\nwant\n%s", name, got, text)
+		}
+	}
+}
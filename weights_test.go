@@ -0,0 +1,165 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the instance weights
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/**
+ * The weight column is optional on each line, and a problem set without one has no weights
+ */
+func TestWeightColumn(t *testing.T) {
+	prob := mustProblem(t, "1 0.5 1:0.3 2:1\n-1 1:1\n1 3 2:-1\n-1 2.5\n", nil)
+	if !reflect.DeepEqual(prob.Weights(), []float64{0.5, 1, 3, 2.5}) {
+		t.Errorf("weights %v", prob.Weights())
+	}
+	if want := mustProblem(t, "1 1:0.3 2:1\n-1 1:1\n1 2:-1\n-1\n", nil); !reflect.DeepEqual(prob.xSpace, want.xSpace) ||
+		!reflect.DeepEqual(prob.y, want.y) || want.Weights() != nil {
+		t.Errorf("read %+v, want %+v", *prob, *want)
+	}
+
+	var i int = 0
+	for prob.Begin(); !prob.Done(); prob.Next() {
+		if prob.GetWeight() != prob.w[i] {
+			t.Errorf("instance %d: weight %v, want %v", i, prob.GetWeight(), prob.w[i])
+		}
+		i++
+	}
+	prob.Weights()[0] = 7
+	if prob.w[0] != 0.5 {
+		t.Errorf("Weights returned the weights of the problem set")
+	}
+}
+
+func TestSetWeights(t *testing.T) {
+	prob := mustProblem(t, "1 1:1\n-1 1:2\n", nil)
+	for _, w := range [][]float64{{1}, {1, 1, 1}, {1, 0}, {-1, 1}, {1, math.NaN()}, {math.Inf(1), 1}} {
+		if err := prob.SetWeights(w); err == nil || prob.w != nil {
+			t.Errorf("weights %v: %v, set %v", w, err, prob.w)
+		}
+	}
+
+	w := []float64{2, 0.25}
+	if err := prob.SetWeights(w); err != nil || !reflect.DeepEqual(prob.w, w) || prob.totalWeight() != 2.25 {
+		t.Fatalf("weights %v, total %v: %v", prob.w, prob.totalWeight(), err)
+	}
+	w[0] = 3
+	if prob.w[0] != 2 {
+		t.Errorf("SetWeights kept the slice of the caller")
+	}
+	if err := prob.SetWeights(nil); err != nil || prob.w != nil || prob.GetWeight() != 1 || prob.totalWeight() != 2 {
+		t.Errorf("weights %v after SetWeights(nil): %v", prob.w, err)
+	}
+}
+
+/**
+ * An instance of weight 2 trains the model of the problem set where it is duplicated, for every svm type
+ */
+func TestWeightDuplicates(t *testing.T) {
+	for _, svmType := range []int{C_SVC, NU_SVC, ONE_CLASS, EPSILON_SVR, NU_SVR} {
+		param := quietParameter(svmType)
+		param.Eps = 1e-6
+		lines := strings.SplitAfter(problemText(svmType), "\n")
+		lines = lines[:len(lines)-1]
+
+		weights := make([]float64, len(lines))
+		duplicated := append([]string(nil), lines...)
+		for i := range weights {
+			weights[i] = 1
+			if i%5 == 0 {
+				weights[i] = 2
+				duplicated = append(duplicated, lines[i])
+			}
+		}
+		weighted := mustProblem(t, strings.Join(lines, ""), param)
+		if err := weighted.SetWeights(weights); err != nil {
+			t.Fatal(err)
+		}
+		model := mustTrain(t, weighted, param)
+		want := mustTrain(t, mustProblem(t, strings.Join(duplicated, ""), param), param)
+
+		for p, info := range model.Report().Subproblems {
+			wantInfo := want.Report().Subproblems[p]
+			if !near(info.Obj, wantInfo.Obj, 1e-4*math.Abs(wantInfo.Obj)) || !near(info.Rho, wantInfo.Rho, 1e-3) {
+				t.Errorf("svm type %d, subproblem %d: obj %v rho %v, want %v %v", svmType, p, info.Obj, info.Rho, wantInfo.Obj, wantInfo.Rho)
+			}
+		}
+		for weighted.Begin(); !weighted.Done(); weighted.Next() {
+			_, x := weighted.GetLine()
+			_, got := model.PredictValues(x)
+			_, wantValues := want.PredictValues(x)
+			if !nearSlices(got, wantValues, 1e-3) {
+				t.Fatalf("svm type %d: decision values %v, want %v", svmType, got, wantValues)
+			}
+		}
+	}
+}
+
+/**
+ * The leave-one-out cross validation of a weighted problem set predicts each instance with the model
+ * trained on the others and their weights
+ */
+func TestWeightCrossValidation(t *testing.T) {
+	for _, svmType := range []int{C_SVC, EPSILON_SVR} {
+		param := quietParameter(svmType)
+		param.Eps = 1e-6
+		text := blobsText(1, 30, 4, 2)
+		if svmType == EPSILON_SVR {
+			text = regressionText(1, 30, 4)
+		}
+		prob := mustProblem(t, text, param)
+		weights := make([]float64, prob.l)
+		for i := range weights {
+			weights[i] = float64(1 + i%4)
+		}
+		if err := prob.SetWeights(weights); err != nil {
+			t.Fatal(err)
+		}
+		target, err := CrossValidation(prob, param, prob.l)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ys, xs := problemVectors(prob)
+		for i := range ys {
+			b := NewProblemBuilder()
+			var w []float64
+			for j := range ys {
+				if j != i {
+					b.Add(ys[j], xs[j])
+					w = append(w, weights[j])
+				}
+			}
+			others, err := b.Build(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := others.SetWeights(w); err != nil {
+				t.Fatal(err)
+			}
+			if want := mustTrain(t, others, param).Predict(xs[i]); !near(target[i], want, 1e-3) {
+				t.Errorf("svm type %d, instance %d: predicted %v, want %v", svmType, i, target[i], want)
+			}
+		}
+	}
+}
@@ -23,22 +23,23 @@ import (
 	"math"
 )
 
-type workingSetSelecter interface {
-	workingSetSelect(solver *solver) (int, int, int)
-	calculateRho(solver *solver) (float64, float64)
+type workingSetSelecter[T cacheValue] interface {
+	workingSetSelect(solver *solver[T]) (int, int, int)
+	calculateRho(solver *solver[T]) (float64, float64)
+	doShrinking(solver *solver[T])
 }
 
-type selectWorkingSet struct{} // for classical solver
+type selectWorkingSet[T cacheValue] struct{} // for classical solver
 
-type selectWorkingSetNU struct{} // for NU solver
+type selectWorkingSetNU[T cacheValue] struct{} // for NU solver
 
-func (s selectWorkingSet) workingSetSelect(solver *solver) (int, int, int) {
+func (s selectWorkingSet[T]) workingSetSelect(solver *solver[T]) (int, int, int) {
 	var gmax float64 = -math.MaxFloat64
 	var gmax2 float64 = -math.MaxFloat64
 	var gmax_idx int = -1
 	var gmin_idx int = -1
 
-	for i := 0; i < solver.l; i++ {
+	for i := 0; i < solver.activeSize; i++ {
 		if solver.y[i] == 1 {
 			if !solver.isUpperBound(i) {
 				if -solver.gradient[i] >= gmax {
@@ -66,25 +67,29 @@ func (s selectWorkingSet) workingSetSelect(solver *solver) (int, int, int) {
 		}
 	}
 
+	solver.maxViolation = gmax + gmax2
 	if gmax+gmax2 < solver.eps {
 		return -1, -1, 1
 	}
 
 	i := gmax_idx
 
-	Qi := solver.q.getQ(i, solver.l)
+	Qi := solver.q.getQ(i, solver.activeSize)
 
 	gmin_idx = s.findGminIdx(i, gmax, Qi, solver)
+	if gmin_idx == -1 { // no variable can be improved, e.g. the gradient has NaN values
+		return -1, -1, 1
+	}
 
 	//fmt.Printf("gmax_idx=%d, gmin_idx=%d\n", gmax_idx, gmin_idx)
 	return gmax_idx, gmin_idx, 0
 }
 
-func (s *selectWorkingSet) findGminIdx(i int, gmax float64, Qi []cacheDataType, solver *solver) (gmin_idx int) {
+func (s *selectWorkingSet[T]) findGminIdx(i int, gmax float64, Qi []T, solver *solver[T]) (gmin_idx int) {
 
-	objDiffMin := make([]float64, solver.parRunner.numCPU)
-	idx := make([]int, solver.parRunner.numCPU)
-	for k := 0; k < solver.parRunner.numCPU; k++ {
+	objDiffMin := make([]float64, solver.pool.size())
+	idx := make([]int, solver.pool.size())
+	for k := 0; k < solver.pool.size(); k++ {
 		objDiffMin[k] = math.MaxFloat64
 		idx[k] = -1
 	}
@@ -130,12 +135,11 @@ func (s *selectWorkingSet) findGminIdx(i int, gmax float64, Qi []cacheDataType,
 		}
 	}
 
-	solver.parRunner.run(run)
-	solver.parRunner.waitAll()
+	solver.pool.runRange(0, solver.activeSize, run)
 
 	var objMin float64 = objDiffMin[0]
 	gmin_idx = idx[0]
-	for k := 1; k < solver.parRunner.numCPU; k++ {
+	for k := 1; k < solver.pool.size(); k++ {
 		if objDiffMin[k] <= objMin {
 			objMin = objDiffMin[k]
 			gmin_idx = idx[k]
@@ -145,13 +149,70 @@ func (s *selectWorkingSet) findGminIdx(i int, gmax float64, Qi []cacheDataType,
 	return
 }
 
-func (s selectWorkingSet) calculateRho(solver *solver) (float64, float64) {
+func (s selectWorkingSet[T]) beShrunk(solver *solver[T], i int, gmax1, gmax2 float64) bool {
+	if solver.isUpperBound(i) {
+		if solver.y[i] == 1 {
+			return -solver.gradient[i] > gmax1
+		} else {
+			return -solver.gradient[i] > gmax2
+		}
+	} else if solver.isLowerBound(i) {
+		if solver.y[i] == 1 {
+			return solver.gradient[i] > gmax2
+		} else {
+			return solver.gradient[i] > gmax1
+		}
+	}
+	return false
+}
+
+func (s selectWorkingSet[T]) doShrinking(solver *solver[T]) {
+	var gmax1 float64 = -math.MaxFloat64 // max { -y_i * grad(f)_i | i in I_up(\alpha) }
+	var gmax2 float64 = -math.MaxFloat64 // max { y_i * grad(f)_i | i in I_low(\alpha) }
+
+	// find maximal violating pair first
+	for i := 0; i < solver.activeSize; i++ {
+		if solver.y[i] == 1 {
+			if !solver.isUpperBound(i) {
+				if -solver.gradient[i] >= gmax1 {
+					gmax1 = -solver.gradient[i]
+				}
+			}
+			if !solver.isLowerBound(i) {
+				if solver.gradient[i] >= gmax2 {
+					gmax2 = solver.gradient[i]
+				}
+			}
+		} else {
+			if !solver.isUpperBound(i) {
+				if -solver.gradient[i] >= gmax2 {
+					gmax2 = -solver.gradient[i]
+				}
+			}
+			if !solver.isLowerBound(i) {
+				if solver.gradient[i] >= gmax1 {
+					gmax1 = solver.gradient[i]
+				}
+			}
+		}
+	}
+
+	if !solver.unshrink && gmax1+gmax2 <= solver.eps*10 {
+		solver.unshrink = true
+		solver.reconstructGradient()
+		solver.activeSize = solver.l
+	}
+
+	solver.shrinkActiveSet(func(i int) bool { return s.beShrunk(solver, i, gmax1, gmax2) })
+}
+
+func (s selectWorkingSet[T]) calculateRho(solver *solver[T]) (float64, float64) {
 	var ub float64 = math.MaxFloat64
 	var lb float64 = -math.MaxFloat64
 	var sum_free float64 = 0
 	var nr_free int = 0
 	var r float64 = 0
-	for i := 0; i < solver.l; i++ {
+	for i := 0; i < solver.activeSize; i++ {
 		yG := float64(solver.y[i]) * solver.gradient[i]
 		if solver.isUpperBound(i) {
 			if solver.y[i] == -1 {
@@ -180,7 +241,7 @@ func (s selectWorkingSet) calculateRho(solver *solver) (float64, float64) {
 	return r, 0
 }
 
-func (s selectWorkingSetNU) workingSetSelect(solver *solver) (int, int, int) {
+func (s selectWorkingSetNU[T]) workingSetSelect(solver *solver[T]) (int, int, int) {
 	var gmaxp float64 = -math.MaxFloat64
 	var gmaxp2 float64 = -math.MaxFloat64
 	var gmaxp_idx int = -1
@@ -191,7 +252,7 @@ func (s selectWorkingSetNU) workingSetSelect(solver *solver) (int, int, int) {
 
 	var gmin_idx int = -1
 
-	for i := 0; i < solver.l; i++ {
+	for i := 0; i < solver.activeSize; i++ {
 		if solver.y[i] == 1 {
 			if !solver.isUpperBound(i) {
 				if -solver.gradient[i] >= gmaxp {
@@ -219,23 +280,27 @@ func (s selectWorkingSetNU) workingSetSelect(solver *solver) (int, int, int) {
 		}
 	}
 
-	if maxf(gmaxp+gmaxp2, gmaxn+gmaxn2) < solver.eps {
+	solver.maxViolation = maxf(gmaxp+gmaxp2, gmaxn+gmaxn2)
+	if solver.maxViolation < solver.eps {
 		return -1, -1, 1 // done!
 	}
 
 	ip := gmaxp_idx
 	in := gmaxn_idx
 
-	var Qip []cacheDataType
+	var Qip []T
 	if ip != -1 {
-		Qip = solver.q.getQ(ip, solver.l)
+		Qip = solver.q.getQ(ip, solver.activeSize)
 	}
-	var Qin []cacheDataType
+	var Qin []T
 	if in != -1 {
-		Qin = solver.q.getQ(in, solver.l)
+		Qin = solver.q.getQ(in, solver.activeSize)
 	}
 
 	gmin_idx = s.findGminIdx(ip, in, gmaxp, gmaxn, Qip, Qin, solver)
+	if gmin_idx == -1 { // no variable can be improved, e.g. the gradient has NaN values
+		return -1, -1, 1
+	}
 
 	var out_j int = gmin_idx
 	var out_i int
@@ -248,11 +313,11 @@ func (s selectWorkingSetNU) workingSetSelect(solver *solver) (int, int, int) {
 	return out_i, out_j, 0
 }
 
-func (s selectWorkingSetNU) findGminIdx(ip, in int, gmaxp, gmaxn float64, Qip, Qin []cacheDataType, solver *solver) (gmin_idx int) {
+func (s selectWorkingSetNU[T]) findGminIdx(ip, in int, gmaxp, gmaxn float64, Qip, Qin []T, solver *solver[T]) (gmin_idx int) {
 
-	objDiffMin := make([]float64, solver.parRunner.numCPU)
-	idx := make([]int, solver.parRunner.numCPU)
-	for k := 0; k < solver.parRunner.numCPU; k++ {
+	objDiffMin := make([]float64, solver.pool.size())
+	idx := make([]int, solver.pool.size())
+	for k := 0; k < solver.pool.size(); k++ {
 		objDiffMin[k] = math.MaxFloat64
 		idx[k] = -1
 	}
@@ -300,12 +365,11 @@ func (s selectWorkingSetNU) findGminIdx(ip, in int, gmaxp, gmaxn float64, Qip, Q
 		}
 	}
 
-	solver.parRunner.run(run)
-	solver.parRunner.waitAll()
+	solver.pool.runRange(0, solver.activeSize, run)
 
 	var objMin float64 = objDiffMin[0]
 	gmin_idx = idx[0]
-	for k := 1; k < solver.parRunner.numCPU; k++ {
+	for k := 1; k < solver.pool.size(); k++ {
 		if objDiffMin[k] <= objMin {
 			objMin = objDiffMin[k]
 			gmin_idx = idx[k]
@@ -315,7 +379,61 @@ func (s selectWorkingSetNU) findGminIdx(ip, in int, gmaxp, gmaxn float64, Qip, Q
 	return
 }
 
-func (s selectWorkingSetNU) calculateRho(solver *solver) (float64, float64) {
+func (s selectWorkingSetNU[T]) beShrunk(solver *solver[T], i int, gmax1, gmax2, gmax3, gmax4 float64) bool {
+	if solver.isUpperBound(i) {
+		if solver.y[i] == 1 {
+			return -solver.gradient[i] > gmax1
+		} else {
+			return -solver.gradient[i] > gmax4
+		}
+	} else if solver.isLowerBound(i) {
+		if solver.y[i] == 1 {
+			return solver.gradient[i] > gmax2
+		} else {
+			return solver.gradient[i] > gmax3
+		}
+	}
+	return false
+}
+
+func (s selectWorkingSetNU[T]) doShrinking(solver *solver[T]) {
+	var gmax1 float64 = -math.MaxFloat64 // max { -y_i * grad(f)_i | y_i = +1, i in I_up(\alpha) }
+	var gmax2 float64 = -math.MaxFloat64 // max { y_i * grad(f)_i | y_i = +1, i in I_low(\alpha) }
+	var gmax3 float64 = -math.MaxFloat64 // max { -y_i * grad(f)_i | y_i = -1, i in I_up(\alpha) }
+	var gmax4 float64 = -math.MaxFloat64 // max { y_i * grad(f)_i | y_i = -1, i in I_low(\alpha) }
+
+	// find maximal violating pair first
+	for i := 0; i < solver.activeSize; i++ {
+		if !solver.isUpperBound(i) {
+			if solver.y[i] == 1 {
+				if -solver.gradient[i] > gmax1 {
+					gmax1 = -solver.gradient[i]
+				}
+			} else if -solver.gradient[i] > gmax4 {
+				gmax4 = -solver.gradient[i]
+			}
+		}
+		if !solver.isLowerBound(i) {
+			if solver.y[i] == 1 {
+				if solver.gradient[i] > gmax2 {
+					gmax2 = solver.gradient[i]
+				}
+			} else if solver.gradient[i] > gmax3 {
+				gmax3 = solver.gradient[i]
+			}
+		}
+	}
+
+	if !solver.unshrink && maxf(gmax1+gmax2, gmax3+gmax4) <= solver.eps*10 {
+		solver.unshrink = true
+		solver.reconstructGradient()
+		solver.activeSize = solver.l
+	}
+
+	solver.shrinkActiveSet(func(i int) bool { return s.beShrunk(solver, i, gmax1, gmax2, gmax3, gmax4) })
+}
+
+func (s selectWorkingSetNU[T]) calculateRho(solver *solver[T]) (float64, float64) {
 	var nr_free1 int = 0
 	var nr_free2 int = 0
 	var ub1 float64 = math.MaxFloat64
@@ -325,7 +443,7 @@ func (s selectWorkingSetNU) calculateRho(solver *solver) (float64, float64) {
 	var sum_free1 float64 = 0
 	var sum_free2 float64 = 0
 
-	for i := 0; i < solver.l; i++ {
+	for i := 0; i < solver.activeSize; i++ {
 		if solver.y[i] == 1 {
 			if solver.isUpperBound(i) {
 				lb1 = maxf(lb1, solver.gradient[i])
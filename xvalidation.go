@@ -19,7 +19,7 @@
 package libSvm
 
 import (
-	"fmt"
+	"context"
 	"math/rand"
 	"time"
 )
@@ -31,14 +31,87 @@ import (
    labels (of all prob's instances) in the validation process are
    stored in the slice called target.
 */
-func CrossValidation(prob *Problem, param *Parameter, nrFold int) (target []float64) {
+func CrossValidation(prob *Problem, param *Parameter, nrFold int) (target []float64, err error) {
+	return CrossValidationContext(context.Background(), prob, param, nrFold)
+}
+
+/**
+ * Same as CrossValidation, but stops early with a TrainingStoppedError when ctx is cancelled or its deadline
+ * passes, or when Parameter.MaxDuration has elapsed for the whole cross validation
+ */
+func CrossValidationContext(ctx context.Context, prob *Problem, param *Parameter, nrFold int) (target []float64, err error) {
+	if nrFold < 2 {
+		return nil, invalidParameter("n-fold cross validation: n must >= 2")
+	}
+	if err := param.Validate(prob); err != nil {
+		return nil, err
+	}
+	if param.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, param.MaxDuration)
+		defer cancel()
+	}
+
+	if target, err = crossValidation(ctx, prob, param, nrFold, nil, nil); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+/**
+ * Same as CrossValidation, but returns the evaluation of the predictions, which includes the decision
+ * values of binary classification and one-class SVM, and the probability estimates if param.Probability
+ * is set for C-SVC and nu-SVC
+ */
+func EvaluateCrossValidation(prob *Problem, param *Parameter, nrFold int) (*Evaluation, error) {
+	return EvaluateCrossValidationContext(context.Background(), prob, param, nrFold)
+}
+
+/**
+ * Same as EvaluateCrossValidation, but stops early as CrossValidationContext does
+ */
+func EvaluateCrossValidationContext(ctx context.Context, prob *Problem, param *Parameter, nrFold int) (*Evaluation, error) {
+	if nrFold < 2 {
+		return nil, invalidParameter("n-fold cross validation: n must >= 2")
+	}
+	if err := param.Validate(prob); err != nil {
+		return nil, err
+	}
+	if param.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, param.MaxDuration)
+		defer cancel()
+	}
+
+	var labels []int
+	if param.SvmType == C_SVC || param.SvmType == NU_SVC {
+		_, labels, _, _, _ = groupClasses(prob) // the labels in the order a model of the whole problem has them
+	}
+
+	eval := NewEvaluation(param.SvmType, labels)
+	if _, err := crossValidation(ctx, prob, param, nrFold, nil, eval); err != nil {
+		return nil, err
+	}
+	return eval, nil
+}
+
+/**
+ * Same as CrossValidation, but stops when ctx is done, calls progress (if not nil) before training each fold,
+ * and adds the predictions to eval (if not nil)
+ */
+func crossValidation(ctx context.Context, prob *Problem, param *Parameter, nrFold int, progress func(fold int), eval *Evaluation) (target []float64, err error) {
 	var l int = prob.l
 
 	target = make([]float64, l) // slice to return
 
+	param = withKernelCache(prob, param) // the folds share the kernel values of the instances they have in common
+	var closePool func()
+	param, closePool = withWorkerPool(param)
+	defer closePool()
+
 	if nrFold > l {
 		nrFold = l
-		fmt.Printf("WARNING: # folds > # data. Will use # folds = # data instead (i.e., leave-one-out cross validation)\n")
+		param.observer().Warning("# folds > # data. Will use # folds = # data instead (i.e., leave-one-out cross validation)")
 	}
 
 	foldStart := make([]int, nrFold+1)
@@ -119,24 +192,45 @@ func CrossValidation(prob *Problem, param *Parameter, nrFold int) (target []floa
 		subProb.l = l - (end - begin)
 		subProb.x = make([]int, subProb.l)
 		subProb.y = make([]float64, subProb.l)
+		if prob.w != nil {
+			subProb.w = make([]float64, subProb.l)
+		}
 
 		var k int = 0
 		for j := 0; j < begin; j++ {
 			subProb.x[k] = prob.x[perm[j]]
 			subProb.y[k] = prob.y[perm[j]]
+			if prob.w != nil {
+				subProb.w[k] = prob.w[perm[j]]
+			}
 			k++
 		}
 		for j := end; j < l; j++ {
 			subProb.x[k] = prob.x[perm[j]]
 			subProb.y[k] = prob.y[perm[j]]
+			if prob.w != nil {
+				subProb.w[k] = prob.w[perm[j]]
+			}
 			k++
 		}
 
+		if progress != nil {
+			progress(i)
+		}
+
 		subModel := NewModel(param)
-		subModel.Train(&subProb)
+		if err = subModel.train(ctx, &subProb); err != nil {
+			return // target, err
+		}
 
-		if param.Probability &&
-			(param.SvmType == C_SVC || param.SvmType == NU_SVC) {
+		probability := param.Probability && (param.SvmType == C_SVC || param.SvmType == NU_SVC)
+		if eval != nil {
+			for j := begin; j < end; j++ {
+				idx := prob.x[perm[j]]
+				x := SnodeToMap(prob.xSpace[idx:])
+				target[perm[j]] = eval.addModelPrediction(subModel, x, prob.y[perm[j]], probability)
+			}
+		} else if probability {
 			for j := begin; j < end; j++ {
 				idx := prob.x[perm[j]]
 				x := SnodeToMap(prob.xSpace[idx:])
@@ -151,5 +245,5 @@ func CrossValidation(prob *Problem, param *Parameter, nrFold int) (target []floa
 		}
 	}
 
-	return
+	return // target, err
 }
@@ -0,0 +1,58 @@
+/*
+** Copyright 2014 Edward Walker
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http ://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**
+** Description: Tests of the cross validation
+** @author: Ed Walker
+ */
+package libSvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/**
+ * The cross validation stops with a TrainingStoppedError when its context is cancelled, or when
+ * Parameter.MaxDuration has elapsed
+ */
+func TestCrossValidationStops(t *testing.T) {
+	param := quietParameter(C_SVC)
+	prob := mustProblem(t, blobsText(1, 300, 4, 3), param)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var stopped *TrainingStoppedError
+	if _, err := CrossValidationContext(ctx, prob, param, 5); !errors.As(err, &stopped) || !errors.Is(err, context.Canceled) {
+		t.Errorf("CrossValidationContext cancelled: %v", err)
+	}
+	if _, err := EvaluateCrossValidationContext(ctx, prob, param, 5); !errors.As(err, &stopped) || !errors.Is(err, context.Canceled) {
+		t.Errorf("EvaluateCrossValidationContext cancelled: %v", err)
+	}
+
+	param.MaxDuration = time.Nanosecond
+	if _, err := CrossValidation(prob, param, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CrossValidation with MaxDuration: %v", err)
+	}
+	if _, err := EvaluateCrossValidation(prob, param, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EvaluateCrossValidation with MaxDuration: %v", err)
+	}
+
+	param.MaxDuration = time.Hour
+	if target, err := CrossValidation(prob, param, 5); err != nil || len(target) != prob.l {
+		t.Errorf("CrossValidation: %d targets, %v", len(target), err)
+	}
+}